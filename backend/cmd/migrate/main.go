@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/migrate"
+	"fitness-buddy/migrations"
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: migrate [-database DSN] [-v] <command>
+
+Commands:
+  up         apply all pending migrations
+  down N     revert the N most recently applied migrations
+  status     list every migration and whether it has been applied
+  plan       show what "up" would apply without running it
+  plan-down N
+             show what "down N" would revert without running it
+
+The DSN defaults to $DATABASE_URL, then fitness_buddy.db.
+`
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, relying on environment variables")
+	}
+
+	dbUrl := os.Getenv("DATABASE_URL")
+	if dbUrl == "" {
+		dbUrl = "fitness_buddy.db"
+	}
+
+	flag.StringVar(&dbUrl, "database", dbUrl, "database DSN (sqlite path or postgres:// URL)")
+	verbose := flag.Bool("v", false, "print the SQL for plan and plan-down")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := database.New(dbUrl)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	m := migrate.New(db, migrations.FS)
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied  %s\n", mig)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("nothing to apply")
+		}
+
+	case "down":
+		reverted, err := m.Down(ctx, countArg())
+		for _, mig := range reverted {
+			fmt.Printf("reverted %s\n", mig)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, st := range statuses {
+			appliedAt := "-"
+			if st.AppliedAt != nil {
+				appliedAt = st.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			reversible := ""
+			if st.Reversible {
+				reversible = "reversible"
+			}
+			fmt.Printf("%03d  %-32s %-9s %-19s %s\n", st.Version, st.Name, st.State, appliedAt, reversible)
+		}
+
+	case "plan":
+		todo, err := m.Plan(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(todo) == 0 {
+			fmt.Println("nothing to apply")
+		}
+		for _, mig := range todo {
+			fmt.Printf("would apply  %s\n", mig)
+			if *verbose {
+				fmt.Printf("%s\n\n", m.SQL(mig, false))
+			}
+		}
+
+	case "plan-down":
+		todo, err := m.PlanDown(ctx, countArg())
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, mig := range todo {
+			fmt.Printf("would revert %s\n", mig)
+			if *verbose {
+				fmt.Printf("%s\n\n", m.SQL(mig, true))
+			}
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func countArg() int {
+	if flag.NArg() < 2 {
+		log.Fatal("missing number of migrations")
+	}
+	n, err := strconv.Atoi(flag.Arg(1))
+	if err != nil || n < 1 {
+		log.Fatalf("invalid number of migrations: %q", flag.Arg(1))
+	}
+	return n
+}
@@ -7,10 +7,12 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"strings"
+	"time"
 
+	"fitness-buddy/internal/account"
 	"fitness-buddy/internal/api"
 	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/migrate"
 	"fitness-buddy/migrations"
 	"github.com/joho/godotenv"
 )
@@ -36,47 +38,14 @@ func main() {
 
 	// Run migrations
 	log.Println("Running migrations...")
-	entries, err := migrations.FS.ReadDir(".")
+	applied, err := migrate.New(db, migrations.FS).Up(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to read migrations directory: %v", err)
+		log.Fatalf("Migrations failed: %v", err)
 	}
+	log.Printf("Migrations complete (%d applied)", len(applied))
 
-	for _, entry := range entries {
-		if !entry.IsDir() && entry.Name() != "migrations.go" {
-			log.Printf("Applying %s...", entry.Name())
-			content, err := migrations.FS.ReadFile(entry.Name())
-			if err != nil {
-				log.Fatalf("Failed to read migration file %s: %v", entry.Name(), err)
-			}
-            
-            sqlContent := string(content)
-            
-            // Dialect Translation for SQLite
-            if !strings.HasPrefix(dbUrl, "postgres") && !strings.HasPrefix(dbUrl, "postgresql") {
-                sqlContent = strings.ReplaceAll(sqlContent, "SERIAL PRIMARY KEY", "INTEGER PRIMARY KEY AUTOINCREMENT")
-                sqlContent = strings.ReplaceAll(sqlContent, "TIMESTAMPTZ", "DATETIME")
-                sqlContent = strings.ReplaceAll(sqlContent, "CURRENT_TIMESTAMP", "CURRENT_TIMESTAMP")
-                sqlContent = strings.ReplaceAll(sqlContent, "ON CONFLICT DO NOTHING", "") // SQLite has different syntax but we use OR IGNORE usually. 
-                // Let's handle seeding specifically
-                sqlContent = strings.ReplaceAll(sqlContent, "INSERT INTO", "INSERT OR IGNORE INTO")
-            }
-
-			if _, err := db.Pool.ExecContext(context.Background(), sqlContent); err != nil {
-				log.Printf("Migration warning for %s: %v", entry.Name(), err)
-			}
-		}
-	}
-
-	// Seed default user if not exists
-	var userCount int
-	db.Pool.QueryRow("SELECT COUNT(*) FROM users").Scan(&userCount)
-	if userCount == 0 {
-		log.Println("Seeding default user...")
-		_, err := db.Pool.Exec("INSERT INTO users (name, height_cm, sex) VALUES ('User', 175, 'M')")
-		if err != nil {
-			log.Printf("Failed to seed user: %v", err)
-		}
-	}
+	// Purge accounts whose deletion grace period has run out
+	go account.NewDeleter(db).Run(context.Background(), time.Hour)
 
 	// Prepare frontend filesystem
 	fSys, err := fs.Sub(frontendFS, "dist")
@@ -0,0 +1,147 @@
+// Package account deletes user accounts. Each domain package purges its own
+// rows; this package runs them together and checks nothing was left behind.
+package account
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/domain/apitoken"
+	"fitness-buddy/internal/domain/body"
+	"fitness-buddy/internal/domain/coaching"
+	"fitness-buddy/internal/domain/identity"
+	"fitness-buddy/internal/domain/nutrition"
+	"fitness-buddy/internal/domain/resistance"
+	"fitness-buddy/internal/domain/running"
+	"fitness-buddy/internal/domain/session"
+)
+
+const defaultDemoAccountTTL = 24 * time.Hour
+
+// UserPurger is implemented by every repository that stores per-user rows.
+type UserPurger interface {
+	PurgeUser(ctx context.Context, tx *sql.Tx, userID int) error
+}
+
+type Deleter struct {
+	db       *database.DB
+	identity *identity.Repository
+	// purgers run in order; identity is last because it deletes the users
+	// row that everything else references.
+	purgers []UserPurger
+}
+
+func NewDeleter(db *database.DB) *Deleter {
+	identityRepo := identity.NewRepository(db)
+	return &Deleter{
+		db:       db,
+		identity: identityRepo,
+		purgers: []UserPurger{
+			coaching.NewRepository(db),
+			resistance.NewRepository(db),
+			running.NewRepository(db),
+			nutrition.NewRepository(db),
+			body.NewRepository(db),
+			session.NewRepository(db),
+			apitoken.NewRepository(db),
+			identityRepo,
+		},
+	}
+}
+
+// Purge deletes the user and everything they own in one transaction. Before
+// committing it checks every table with a user_id column, so a table added
+// later without a purger makes deletion fail instead of leaving data behind.
+func (d *Deleter) Purge(ctx context.Context, userID int) error {
+	tx, err := d.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range d.purgers {
+		if err := p.PurgeUser(ctx, tx, userID); err != nil {
+			return fmt.Errorf("purge user %d (%T): %w", userID, p, err)
+		}
+	}
+	if err := d.verifyPurged(ctx, tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *Deleter) verifyPurged(ctx context.Context, tx *sql.Tx, userID int) error {
+	tables, err := d.db.TablesWithColumn(ctx, tx, "user_id")
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		var n int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE user_id = $1`, userID).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("purge user %d: %d rows left in %s", userID, n, table)
+		}
+	}
+	return nil
+}
+
+// PurgeDue deletes every account whose grace period has run out, and demo
+// accounts older than DEMO_ACCOUNT_TTL_HOURS, and returns how many were
+// deleted. One failure doesn't stop the others.
+func (d *Deleter) PurgeDue(ctx context.Context, now time.Time) (int, error) {
+	ids, err := d.identity.ListDueDeletions(ctx, now, demoAccountTTL())
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	var firstErr error
+	for _, id := range ids {
+		if err := d.Purge(ctx, id); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		purged++
+	}
+	return purged, firstErr
+}
+
+// demoAccountTTL is how long a demo account lives, from
+// DEMO_ACCOUNT_TTL_HOURS.
+func demoAccountTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("DEMO_ACCOUNT_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		return defaultDemoAccountTTL
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Run calls PurgeDue every interval until ctx is done.
+func (d *Deleter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := d.PurgeDue(ctx, time.Now())
+		if err != nil {
+			log.Printf("Account purge failed: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Purged %d deleted or expired demo account(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/testdb"
+)
+
+// seeder inserts rows for tests, failing the test on the first error.
+type seeder struct {
+	t  *testing.T
+	db *database.DB
+}
+
+func (s seeder) id(query string, args ...any) int {
+	s.t.Helper()
+	var id int
+	if err := s.db.Pool.QueryRow(query+" RETURNING id", args...).Scan(&id); err != nil {
+		s.t.Fatalf("%s: %v", query, err)
+	}
+	return id
+}
+
+func (s seeder) exec(query string, args ...any) {
+	s.t.Helper()
+	if _, err := s.db.Pool.Exec(query, args...); err != nil {
+		s.t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// seedUser gives a new user rows in every domain: logins, sessions, API
+// tokens, mailed tokens, workouts with sets and records, routines, runs,
+// shoes, meals, water, body metrics, goals, coaching grants and comments,
+// and library entries of their own. coachID, if not zero, is made the new
+// user's coach.
+func seedUser(s seeder, email string, coachID int) int {
+	now := time.Now().UTC()
+	userID := s.id(`INSERT INTO users (name, email) VALUES ($1, $2)`, "Test", email)
+	s.exec(`INSERT INTO user_identities (user_id, provider, subject, created_at) VALUES ($1, 'email', $2, $3)`, userID, email, now)
+	s.exec(`INSERT INTO sessions (id, user_id, expires_at) VALUES ($1, $2, $3)`, email, userID, now.Add(time.Hour))
+	s.exec(`INSERT INTO refresh_tokens (token_hash, session_id) VALUES ($1, $2)`, "refresh-"+email, email)
+	s.exec(`INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes) VALUES ($1, 'cli', $2, 'fb_', 'read')`, userID, "api-"+email)
+	s.exec(`INSERT INTO email_tokens (token_hash, user_id, purpose, email, expires_at) VALUES ($1, $2, 'verify_email', $3, $4)`, "mail-"+email, userID, email, now.Add(time.Hour))
+
+	exerciseID := s.id(`INSERT INTO exercises (name, category, created_by) VALUES ($1, 'Strength', $2)`, "Custom lift "+email, userID)
+	sessionID := s.id(`INSERT INTO workout_sessions (user_id, start_time) VALUES ($1, $2)`, userID, now)
+	setID := s.id(`INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, performed_at) VALUES ($1, $2, 1, 100, 5, $3)`, sessionID, exerciseID, now)
+	s.exec(`INSERT INTO personal_records (set_id, kind, value_kg, achieved_at) VALUES ($1, 'max_weight', 100, $2)`, setID, now)
+	routineID := s.id(`INSERT INTO routines (user_id, name) VALUES ($1, 'Push')`, userID)
+	s.exec(`INSERT INTO routine_exercises (routine_id, exercise_id, exercise_order) VALUES ($1, $2, 1)`, routineID, exerciseID)
+
+	shoeID := s.id(`INSERT INTO shoes (user_id, brand, model) VALUES ($1, 'Brand', 'Model')`, userID)
+	runID := s.id(`INSERT INTO runs (user_id, start_time, duration_seconds, distance_meters, shoe_id) VALUES ($1, $2, 1800, 5000, $3)`, userID, now, shoeID)
+
+	mealID := s.id(`INSERT INTO meals (user_id, name, eaten_at) VALUES ($1, 'Lunch', $2)`, userID, now)
+	s.exec(`INSERT INTO food_entries (meal_id, name, calories) VALUES ($1, 'Rice', 300)`, mealID)
+	s.exec(`INSERT INTO food_library (name, calories_per_100g, created_by) VALUES ($1, 130, $2)`, "Custom food "+email, userID)
+	s.exec(`INSERT INTO water_logs (user_id, amount_ml) VALUES ($1, 500)`, userID)
+	s.exec(`INSERT INTO nutrition_goals (user_id, effective_from, calories) VALUES ($1, '2026-01-01', 2000)`, userID)
+	s.exec(`INSERT INTO body_metrics (user_id, recorded_at, weight_kg) VALUES ($1, $2, 80)`, userID, now)
+
+	if coachID != 0 {
+		s.exec(`INSERT INTO coach_grants (user_id, coach_id, scopes, invite_hash, invite_expires_at, accepted_at) VALUES ($1, $2, 'resistance', $3, $4, $4)`, userID, coachID, "invite-"+email, now)
+		// The coach comments on the athlete's logs; the athlete on their own.
+		s.exec(`INSERT INTO comments (user_id, session_id, body) VALUES ($1, $2, 'Nice')`, coachID, sessionID)
+	}
+	s.exec(`INSERT INTO comments (user_id, run_id, body) VALUES ($1, $2, 'Felt good')`, userID, runID)
+	s.exec(`INSERT INTO comments (user_id, meal_id, body) VALUES ($1, $2, 'Tasty')`, userID, mealID)
+	return userID
+}
+
+func countRows(t *testing.T, db *database.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.Pool.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestPurgeRemovesEveryUserRow(t *testing.T) {
+	db := testdb.SQLite(t)
+	ctx := context.Background()
+	s := seeder{t, db}
+
+	// The two coach each other, so the deleted user holds grants on both
+	// sides and has comments on the other user's logs.
+	doomed := seedUser(s, "doomed@example.com", 0)
+	kept := seedUser(s, "kept@example.com", doomed)
+	s.exec(`INSERT INTO coach_grants (user_id, coach_id, scopes, invite_hash, invite_expires_at, accepted_at) VALUES ($1, $2, 'running', 'invite-back', $3, $3)`, doomed, kept, time.Now().UTC())
+
+	tables, err := db.TablesWithColumn(ctx, db.Pool, "user_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) == 0 {
+		t.Fatal("no tables with a user_id column")
+	}
+	before := map[string]int{}
+	for _, table := range tables {
+		// Every table has to be seeded, or the test would pass for a new
+		// table nobody purges.
+		if n := countRows(t, db, `SELECT COUNT(*) FROM `+table+` WHERE user_id = $1`, doomed); n == 0 {
+			t.Errorf("seed has no %s rows for the deleted user; extend seedUser", table)
+		}
+		before[table] = countRows(t, db, `SELECT COUNT(*) FROM `+table+` WHERE user_id = $1`, kept)
+	}
+
+	if err := NewDeleter(db).Purge(ctx, doomed); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+
+	for _, table := range tables {
+		if n := countRows(t, db, `SELECT COUNT(*) FROM `+table+` WHERE user_id = $1`, doomed); n != 0 {
+			t.Errorf("%s: %d rows left for the deleted user", table, n)
+		}
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM users WHERE id = $1`, doomed); n != 0 {
+		t.Error("users row left behind")
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM coach_grants WHERE coach_id = $1`, doomed); n != 0 {
+		t.Errorf("%d coach grants left for the deleted coach", n)
+	}
+	for _, table := range []string{"exercises", "food_library"} {
+		if n := countRows(t, db, `SELECT COUNT(*) FROM `+table+` WHERE created_by = $1`, doomed); n != 0 {
+			t.Errorf("%s: %d entries still credited to the deleted user", table, n)
+		}
+	}
+
+	// The other user keeps everything except what the deleted user wrote
+	// and the grant to them.
+	for _, table := range tables {
+		want := before[table]
+		if table == "coach_grants" {
+			want = 0
+		}
+		if n := countRows(t, db, `SELECT COUNT(*) FROM `+table+` WHERE user_id = $1`, kept); n != want {
+			t.Errorf("%s: the other user has %d rows, want %d", table, n, want)
+		}
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM workout_sets ws JOIN workout_sessions s ON s.id = ws.session_id WHERE s.user_id = $1`, kept); n != 1 {
+		t.Errorf("the other user has %d sets, want 1", n)
+	}
+}
+
+func TestPurgeDue(t *testing.T) {
+	db := testdb.SQLite(t)
+	ctx := context.Background()
+	s := seeder{t, db}
+	now := time.Now().UTC()
+
+	due := seedUser(s, "due@example.com", 0)
+	s.exec(`UPDATE users SET delete_after = $1 WHERE id = $2`, now.Add(-time.Minute), due)
+	pending := seedUser(s, "pending@example.com", 0)
+	s.exec(`UPDATE users SET delete_after = $1 WHERE id = $2`, now.Add(time.Hour), pending)
+	oldDemo := s.id(`INSERT INTO users (name, is_demo, created_at) VALUES ('Demo', $1, $2)`, true, now.Add(-48*time.Hour))
+	newDemo := s.id(`INSERT INTO users (name, is_demo, created_at) VALUES ('Demo', $1, $2)`, true, now.Add(-time.Hour))
+
+	n, err := NewDeleter(db).PurgeDue(ctx, now)
+	if err != nil {
+		t.Fatalf("PurgeDue: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("purged %d accounts, want 2", n)
+	}
+	for id, want := range map[int]int{due: 0, pending: 1, oldDemo: 0, newDemo: 1} {
+		if got := countRows(t, db, `SELECT COUNT(*) FROM users WHERE id = $1`, id); got != want {
+			t.Errorf("user %d: %d rows, want %d", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/database"
+)
+
+const (
+	// reauthWindow is how recent a login has to be to delete the account
+	// without giving a password.
+	reauthWindow = 10 * time.Minute
+
+	defaultDeletionGrace = 14 * 24 * time.Hour
+)
+
+// deletionGrace is how long a deleted account can still be restored, from
+// ACCOUNT_DELETION_GRACE_DAYS. Zero deletes immediately.
+func deletionGrace() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || days < 0 {
+		return defaultDeletionGrace
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// reauthenticated reports whether the caller has just proven who they are:
+// either with the account password or by having signed in moments ago, which
+// is the only option for Google and phone accounts.
+func (h *AuthHandler) reauthenticated(r *http.Request, userID int, password string) (bool, error) {
+	if password != "" {
+		hash, err := h.identityRepo.GetPasswordHash(r.Context(), userID)
+		if err != nil {
+			return false, err
+		}
+		return hash != "" && auth.CheckPassword(password, hash) == nil, nil
+	}
+
+	s, err := h.sessions.Get(r.Context(), userID, auth.GetSessionID(r.Context()))
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return time.Since(s.CreatedAt) <= reauthWindow, nil
+}
+
+// HandleDeleteAccount schedules the caller's account for deletion after the
+// grace period, or purges it right away when there is none. Either way every
+// session and API token is revoked immediately; signing in again during the
+// grace period and calling HandleRestoreAccount undoes it.
+func (h *AuthHandler) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ok, err := h.reauthenticated(r, userID, req.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Confirm your password, or sign in again, to delete your account", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.tokens.RevokeAll(r.Context(), userID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.sessions.RevokeAll(r.Context(), userID, ""); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	grace := deletionGrace()
+	if grace == 0 {
+		if err := h.deleter.Purge(r.Context(), userID); err != nil {
+			http.Error(w, "Failed to delete account: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		clearAuthCookies(w, r)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"deleted": true,
+		})
+		return
+	}
+
+	deleteAfter := time.Now().UTC().Add(grace)
+	if err := h.identityRepo.ScheduleDeletion(r.Context(), userID, deleteAfter); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	clearAuthCookies(w, r)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"delete_after": deleteAfter,
+	})
+}
+
+// HandleRestoreAccount cancels a pending deletion.
+func (h *AuthHandler) HandleRestoreAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.identityRepo.CancelDeletion(r.Context(), userID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Account is not scheduled for deletion", http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user, err := h.identityRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(user)
+}
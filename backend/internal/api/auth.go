@@ -1,17 +1,21 @@
 package api
 
 import (
-	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
-	"time"
 
+	"fitness-buddy/internal/account"
 	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/domain/apitoken"
 	"fitness-buddy/internal/domain/identity"
+	"fitness-buddy/internal/domain/session"
+	"fitness-buddy/internal/mail"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/oauth2"
@@ -44,37 +48,100 @@ func getGoogleOauthConfig() *oauth2.Config {
 	}
 }
 
+const googleUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
+
 type AuthHandler struct {
 	identityRepo *identity.Repository
+	sessions     *session.Repository
+	tokens       *apitoken.Repository
+	deleter      *account.Deleter
 	oauthConfig  *oauth2.Config
+	userInfoURL  string
+	// allowedRedirects holds the origins a login may send the browser back
+	// to after the callback.
+	allowedRedirects []string
+	// firebase is nil when FIREBASE_PROJECT_ID is unset, which disables
+	// phone login.
+	firebase *auth.FirebaseVerifier
+	// mailer delivers verification and password reset links.
+	mailer mail.Mailer
 }
 
-func NewAuthHandler(identityRepo *identity.Repository) *AuthHandler {
+func NewAuthHandler(identityRepo *identity.Repository, sessions *session.Repository, tokens *apitoken.Repository, deleter *account.Deleter) *AuthHandler {
 	return &AuthHandler{
-		identityRepo: identityRepo,
-		oauthConfig:  getGoogleOauthConfig(),
+		identityRepo:     identityRepo,
+		sessions:         sessions,
+		tokens:           tokens,
+		deleter:          deleter,
+		oauthConfig:      getGoogleOauthConfig(),
+		userInfoURL:      googleUserInfoURL,
+		allowedRedirects: allowedRedirectOrigins(),
+		firebase:         getFirebaseVerifier(),
+		mailer:           getMailer(),
 	}
 }
 
+func getFirebaseVerifier() *auth.FirebaseVerifier {
+	projectID := os.Getenv("FIREBASE_PROJECT_ID")
+	if projectID == "" {
+		return nil
+	}
+	return auth.NewFirebaseVerifier(projectID, auth.NewJWKSKeySource(auth.FirebaseJWKSURL))
+}
+
+// HandleGoogleLogin starts the authorization code flow with a fresh random
+// state and a PKCE verifier, both remembered in a signed cookie. An optional
+// ?redirect= is honoured only for allowlisted targets.
 func (h *AuthHandler) HandleGoogleLogin(w http.ResponseWriter, r *http.Request) {
-	url := h.oauthConfig.AuthCodeURL("state-token")
+	state, err := randomToken(32)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = setOAuthState(w, r, oauthState{
+		State:    state,
+		Verifier: verifier,
+		Redirect: safeRedirect(r.URL.Query().Get("redirect"), h.allowedRedirects),
+	})
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	url := h.oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
 func (h *AuthHandler) HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
+	st, err := consumeOAuthState(w, r)
+	if err != nil {
+		http.Error(w, "Invalid login attempt: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errParam := r.FormValue("error"); errParam != "" {
+		http.Error(w, "Login was not completed: "+errParam, http.StatusUnauthorized)
+		return
+	}
+
 	code := r.FormValue("code")
-	token, err := h.oauthConfig.Exchange(context.Background(), code)
+	token, err := h.oauthConfig.Exchange(r.Context(), code, oauth2.VerifierOption(st.Verifier))
 	if err != nil {
 		http.Error(w, "Failed to exchange token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	resp, err := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + token.AccessToken)
+	resp, err := h.oauthConfig.Client(r.Context(), token).Get(h.userInfoURL)
 	if err != nil {
 		http.Error(w, "Failed to get user info: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		http.Error(w, fmt.Sprintf("Failed to get user info: status %d", resp.StatusCode), http.StatusBadGateway)
+		return
+	}
 
 	var googleUser struct {
 		ID    string `json:"id"`
@@ -86,53 +153,41 @@ func (h *AuthHandler) HandleGoogleCallback(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := h.identityRepo.GetOrCreateUserByGoogleID(context.Background(), googleUser.ID, googleUser.Email, googleUser.Name)
-	if err != nil {
-		http.Error(w, "Failed to get or create user: "+err.Error(), http.StatusInternalServerError)
+	if st.LinkUserID != 0 {
+		err := h.linkOrMerge(r.Context(), st.LinkUserID, identity.ProviderGoogle, googleUser.ID, googleUser.Email, st.Merge)
+		if err != nil {
+			http.Redirect(w, r, withQuery(st.Redirect, "link_error", linkErrorCode(err)), http.StatusTemporaryRedirect)
+			return
+		}
+		http.Redirect(w, r, withQuery(st.Redirect, "linked", identity.ProviderGoogle), http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Create JWT
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
-	})
-
-	tokenString, err := jwtToken.SignedString(getJWTSecret())
+	user, err := h.identityRepo.GetOrCreateUserByGoogleID(r.Context(), googleUser.ID, googleUser.Email, googleUser.Name)
 	if err != nil {
-		http.Error(w, "Failed to sign token: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to get or create user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Set cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    tokenString,
-		Path:     "/",
-		HttpOnly: true,
-		Expires:  time.Now().Add(time.Hour * 24 * 7),
-	})
-
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "/"
+	if err := h.startSession(w, r, user.ID); err != nil {
+		http.Error(w, "Failed to start session: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-	http.Redirect(w, r, frontendURL, http.StatusTemporaryRedirect)
+
+	http.Redirect(w, r, st.Redirect, http.StatusTemporaryRedirect)
 }
 
+// HandleLogout revokes the session behind the refresh cookie, so neither
+// token can be used again, and clears both cookies.
 func (h *AuthHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   -1,
-	})
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "/"
+	if cookie, err := r.Cookie(refreshCookie); err == nil {
+		if err := h.sessions.RevokeByToken(r.Context(), cookie.Value); err != nil {
+			http.Error(w, "Failed to end session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
-	http.Redirect(w, r, frontendURL, http.StatusTemporaryRedirect)
+	clearAuthCookies(w, r)
+	http.Redirect(w, r, frontendURL(), http.StatusTemporaryRedirect)
 }
 
 // HandlePhoneAuth handles phone authentication via Firebase
@@ -146,99 +201,184 @@ func (h *AuthHandler) HandlePhoneAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.IDToken == "" || req.PhoneNumber == "" {
-		http.Error(w, "Missing idToken or phoneNumber", http.StatusBadRequest)
+	subject, phone, ok := h.verifyPhoneToken(w, r, req.IDToken, req.PhoneNumber)
+	if !ok {
 		return
 	}
 
-	// Verify Firebase ID token
-	firebaseUID, err := verifyFirebaseToken(req.IDToken)
+	// Get or create user
+	user, err := h.identityRepo.GetOrCreateUserByPhone(r.Context(), phone, subject, "")
 	if err != nil {
-		http.Error(w, "Invalid Firebase token: "+err.Error(), http.StatusUnauthorized)
+		http.Error(w, "Failed to get or create user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Get or create user
-	user, err := h.identityRepo.GetOrCreateUserByPhone(context.Background(), req.PhoneNumber, firebaseUID, "")
-	if err != nil {
-		http.Error(w, "Failed to get or create user: "+err.Error(), http.StatusInternalServerError)
+	if err := h.startSession(w, r, user.ID); err != nil {
+		http.Error(w, "Failed to start session: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Create JWT
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user":    user,
 	})
+}
 
-	tokenString, err := jwtToken.SignedString(getJWTSecret())
+// verifyPhoneToken checks a Firebase ID token and that it vouches for the
+// phone number the client claims. It answers the request itself on failure.
+func (h *AuthHandler) verifyPhoneToken(w http.ResponseWriter, r *http.Request, idToken, phoneNumber string) (subject, phone string, ok bool) {
+	if idToken == "" || phoneNumber == "" {
+		http.Error(w, "Missing idToken or phoneNumber", http.StatusBadRequest)
+		return "", "", false
+	}
+
+	if h.firebase == nil {
+		http.Error(w, "Phone login is not configured", http.StatusServiceUnavailable)
+		return "", "", false
+	}
+
+	// Verify Firebase ID token
+	claims, err := h.firebase.Verify(r.Context(), idToken)
 	if err != nil {
-		http.Error(w, "Failed to sign token: "+err.Error(), http.StatusInternalServerError)
-		return
+		http.Error(w, "Invalid Firebase token: "+err.Error(), http.StatusUnauthorized)
+		return "", "", false
 	}
 
-	// Set cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    tokenString,
-		Path:     "/",
-		HttpOnly: true,
-		Expires:  time.Now().Add(time.Hour * 24 * 7),
-	})
+	// The phone number is only trusted as far as the token vouches for it
+	phone = auth.NormalizePhone(claims.PhoneNumber)
+	if phone == "" || phone != auth.NormalizePhone(phoneNumber) {
+		http.Error(w, "Phone number does not match token", http.StatusUnauthorized)
+		return "", "", false
+	}
+	return claims.Subject, phone, true
+}
+
+// demoLoginsPerHour caps how many demo sign-ins one IP address gets, as each
+// can create an account.
+const demoLoginsPerHour = 10
+
+// HandleDemoLogin signs the visitor into a demo account: the one the browser
+// is still signed in to, or a brand-new one. Demo accounts are purged after
+// DEMO_ACCOUNT_TTL_HOURS. It is only routed when DEMO_MODE is enabled.
+func (h *AuthHandler) HandleDemoLogin(w http.ResponseWriter, r *http.Request) {
+	user, err := h.currentDemoUser(w, r)
+	if err != nil {
+		http.Error(w, "Failed to resume demo session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		user, err = h.identityRepo.CreateDemoUser(r.Context())
+		if err != nil {
+			http.Error(w, "Failed to create demo user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := h.startSession(w, r, user.ID); err != nil {
+			http.Error(w, "Failed to start session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
 		"user":    user,
 	})
 }
 
-// verifyFirebaseToken verifies a Firebase ID token and returns the user's Firebase UID
-func verifyFirebaseToken(idToken string) (string, error) {
-	// For simplicity, we'll verify the token by calling Google's token info endpoint
-	// In production, you should use the Firebase Admin SDK
-	resp, err := http.Get("https://www.googleapis.com/oauth2/v3/tokeninfo?id_token=" + idToken)
+// currentDemoUser resumes the demo account the request's refresh cookie is
+// signed in to, rotating the cookie as HandleRefresh would. It returns nil
+// if there is no such session or it belongs to a real account.
+func (h *AuthHandler) currentDemoUser(w http.ResponseWriter, r *http.Request) (*identity.User, error) {
+	cookie, err := r.Cookie(refreshCookie)
 	if err != nil {
-		return "", err
+		return nil, nil
+	}
+	s, err := h.sessions.Lookup(r.Context(), cookie.Value)
+	if errors.Is(err, session.ErrInvalidToken) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := h.identityRepo.GetUserByID(r.Context(), s.UserID)
+	if err == sql.ErrNoRows || (err == nil && !user.IsDemo) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("invalid token")
+	s, refresh, err := h.sessions.Rotate(r.Context(), cookie.Value, r.UserAgent(), clientIP(r), sessionTTL)
+	if errors.Is(err, session.ErrInvalidToken) || errors.Is(err, session.ErrTokenReused) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := setAuthCookies(w, r, s, refresh); err != nil {
+		return nil, err
 	}
+	return user, nil
+}
 
-	var tokenInfo struct {
-		Sub string `json:"sub"` // Firebase UID
-		Aud string `json:"aud"` // Should match your Firebase project
+// HandleDemoReset wipes the caller's demo data. Real accounts are refused.
+func (h *AuthHandler) HandleDemoReset(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&tokenInfo); err != nil {
-		return "", err
+
+	user, err := h.identityRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if !user.IsDemo {
+		http.Error(w, "Only demo accounts can be reset", http.StatusForbidden)
+		return
 	}
 
-	if tokenInfo.Sub == "" {
-		return "", fmt.Errorf("invalid token: no sub claim")
+	if err := h.identityRepo.ResetUserData(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to reset demo data: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
+	w.WriteHeader(http.StatusOK)
+}
 
-	return tokenInfo.Sub, nil
+func demoModeEnabled() bool {
+	return os.Getenv("DEMO_MODE") == "true"
 }
 
-func JWTMiddleware(next http.Handler) http.Handler {
+// JWTMiddleware authenticates /api requests with either a personal access
+// token in the Authorization header or the access token cookie. For cookies,
+// besides the signature and expiry it checks that the token's session has not
+// been revoked, which is what makes logout take effect immediately.
+func (h *AuthHandler) JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get("Authorization"); header != "" {
+			h.authenticateBearer(w, r, header, next)
+			return
+		}
+
 		// Skip JWT check for auth routes
-		if strings.HasPrefix(r.URL.Path, "/api/auth/google") || strings.HasPrefix(r.URL.Path, "/api/auth/phone") {
+		if strings.HasPrefix(r.URL.Path, "/api/auth/google") || strings.HasPrefix(r.URL.Path, "/api/auth/phone") ||
+			strings.HasPrefix(r.URL.Path, "/api/auth/password/") ||
+			r.URL.Path == "/api/auth/demo" || r.URL.Path == "/api/auth/refresh" || r.URL.Path == "/api/auth/logout" {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		cookie, err := r.Cookie("auth_token")
-		if err != nil {
-			// No cookie, let it through but without userID if it's not a protected route
-			// Or just return 401 if it's under /api
+		unauthorized := func() {
 			if strings.HasPrefix(r.URL.Path, "/api/") {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 			next.ServeHTTP(w, r)
+		}
+
+		cookie, err := r.Cookie(accessCookie)
+		if err != nil {
+			unauthorized()
 			return
 		}
 
@@ -247,38 +387,62 @@ func JWTMiddleware(next http.Handler) http.Handler {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
 			return getJWTSecret(), nil
-		})
-
+		}, jwt.WithExpirationRequired())
 		if err != nil || !token.Valid {
-			if strings.HasPrefix(r.URL.Path, "/api/") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-			next.ServeHTTP(w, r)
+			unauthorized()
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			if strings.HasPrefix(r.URL.Path, "/api/") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-			next.ServeHTTP(w, r)
+			unauthorized()
 			return
 		}
 
 		userIDFloat, ok := claims["user_id"].(float64)
-		if !ok {
-			if strings.HasPrefix(r.URL.Path, "/api/") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-			next.ServeHTTP(w, r)
+		sessionID, _ := claims["sid"].(string)
+		if !ok || sessionID == "" {
+			unauthorized()
 			return
 		}
 		userID := int(userIDFloat)
+
+		live, err := h.sessions.Touch(r.Context(), userID, sessionID)
+		if err != nil {
+			http.Error(w, "Failed to check session", http.StatusInternalServerError)
+			return
+		}
+		if !live {
+			unauthorized()
+			return
+		}
+
 		ctx := auth.WithUserID(r.Context(), userID)
+		ctx = auth.WithSessionID(ctx, sessionID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// authenticateBearer handles "Authorization: Bearer <token>". A request that
+// presents a header is judged on it alone; there is no fallback to cookies.
+func (h *AuthHandler) authenticateBearer(w http.ResponseWriter, r *http.Request, header string, next http.Handler) {
+	scheme, secret, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || secret == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := h.tokens.Authenticate(r.Context(), strings.TrimSpace(secret))
+	if errors.Is(err, apitoken.ErrInvalidToken) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to check token", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := auth.WithUserID(r.Context(), token.UserID)
+	ctx = auth.WithScopes(ctx, token.Scopes)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/domain/identity"
+	"fitness-buddy/internal/domain/resistance"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/oauth2"
+)
+
+// linkOrMerge attaches a login the caller has just proven they control. If
+// it belongs to another account, that account is merged into the caller's
+// when merge is set, and ErrIdentityTaken is returned otherwise.
+func (h *AuthHandler) linkOrMerge(ctx context.Context, userID int, provider, subject, display string, merge bool) error {
+	err := h.identityRepo.LinkIdentity(ctx, userID, provider, subject, display)
+	if !errors.Is(err, identity.ErrIdentityTaken) || !merge {
+		return err
+	}
+	owner, err := h.identityRepo.GetUserByIdentity(ctx, provider, subject)
+	if err != nil {
+		return err
+	}
+	return h.mergeUsers(ctx, userID, owner.ID)
+}
+
+// mergeUsers folds duplicateID into primaryID. The two workout histories are
+// now one, so personal records are worked out again before the merge
+// commits.
+func (h *AuthHandler) mergeUsers(ctx context.Context, primaryID, duplicateID int) error {
+	return h.identityRepo.MergeUsers(ctx, primaryID, duplicateID, func(tx *sql.Tx) error {
+		return resistance.RecomputeAllRecords(ctx, tx, primaryID)
+	})
+}
+
+// linkErrorCode is the short reason passed back to the frontend when a
+// Google link, which ends in a redirect, fails.
+func linkErrorCode(err error) string {
+	if errors.Is(err, identity.ErrIdentityTaken) {
+		return "taken"
+	}
+	return "failed"
+}
+
+func writeLinkError(w http.ResponseWriter, err error) {
+	if errors.Is(err, identity.ErrIdentityTaken) {
+		http.Error(w, "This login belongs to another account. Link it again with merge set to combine the two accounts.", http.StatusConflict)
+		return
+	}
+	http.Error(w, "Failed to link login: "+err.Error(), http.StatusInternalServerError)
+}
+
+func (h *AuthHandler) writeIdentities(w http.ResponseWriter, r *http.Request, userID int) {
+	identities, err := h.identityRepo.ListIdentities(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(identities)
+}
+
+// HandleListIdentities lists the ways the caller can sign in.
+func (h *AuthHandler) HandleListIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	h.writeIdentities(w, r, userID)
+}
+
+// HandleUnlinkIdentity removes a login from the caller's account.
+func (h *AuthHandler) HandleUnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid identity ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.identityRepo.UnlinkIdentity(r.Context(), userID, id)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		http.Error(w, "Identity not found", http.StatusNotFound)
+		return
+	case errors.Is(err, identity.ErrLastIdentity):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeIdentities(w, r, userID)
+}
+
+// HandleLinkGoogle starts attaching a Google account to the caller's. It
+// answers with the URL of Google's consent screen for the frontend to open;
+// the callback then attaches the Google account instead of logging in with
+// it. "merge": true folds in an existing account that uses that Google login.
+// Only a POST can start this, so a link or image on another site cannot walk
+// a signed-in user into a merge.
+func (h *AuthHandler) HandleLinkGoogle(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Redirect string `json:"redirect"`
+		Merge    bool   `json:"merge"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = setOAuthState(w, r, oauthState{
+		State:      state,
+		Verifier:   verifier,
+		Redirect:   safeRedirect(req.Redirect, h.allowedRedirects),
+		LinkUserID: userID,
+		Merge:      req.Merge,
+	})
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	url := h.oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+	json.NewEncoder(w).Encode(map[string]string{"auth_url": url})
+}
+
+// HandleLinkPhone attaches a Firebase-verified phone number.
+func (h *AuthHandler) HandleLinkPhone(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		IDToken     string `json:"idToken"`
+		PhoneNumber string `json:"phoneNumber"`
+		Merge       bool   `json:"merge"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	subject, phone, ok := h.verifyPhoneToken(w, r, req.IDToken, req.PhoneNumber)
+	if !ok {
+		return
+	}
+	if err := h.linkOrMerge(r.Context(), userID, identity.ProviderPhone, subject, phone, req.Merge); err != nil {
+		writeLinkError(w, err)
+		return
+	}
+	h.writeIdentities(w, r, userID)
+}
+
+// HandleLinkEmail adds email + password sign-in to the caller's account, or
+// sets a new address and password if it already has them. Changing either
+// takes the current password or a fresh login, as deleting the account does.
+// To merge an existing password account the caller has to give that
+// account's password.
+func (h *AuthHandler) HandleLinkEmail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Email           string `json:"email"`
+		Password        string `json:"password"`
+		CurrentPassword string `json:"current_password"`
+		Merge           bool   `json:"merge"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.identityRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	current, err := h.identityRepo.GetPasswordHash(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if current != "" || user.Email != nil {
+		ok, err := h.reauthenticated(r, userID, req.CurrentPassword)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Confirm your current password, or sign in again, to change your email or password", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	owner, err := h.identityRepo.GetUserByIdentity(r.Context(), identity.ProviderEmail, email)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if owner != nil && owner.ID != userID {
+		if !req.Merge {
+			writeLinkError(w, identity.ErrIdentityTaken)
+			return
+		}
+		hash, err := h.identityRepo.GetPasswordHash(r.Context(), owner.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if hash == "" || auth.CheckPassword(req.Password, hash) != nil {
+			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+			return
+		}
+		if err := h.mergeUsers(r.Context(), userID, owner.ID); err != nil {
+			http.Error(w, "Failed to merge accounts: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.writeIdentities(w, r, userID)
+		return
+	}
+
+	// A new or unverified address is mailed a verification link.
+	verified := user.EmailVerifiedAt != nil && user.Email != nil && strings.EqualFold(*user.Email, email)
+	if !verified && !h.requireMailer(w) {
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	err = h.identityRepo.LinkEmail(r.Context(), userID, email, hash)
+	if errors.Is(err, identity.ErrEmailTaken) {
+		http.Error(w, "That address is on file for another account; sign in there and link this one instead", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeLinkError(w, err)
+		return
+	}
+
+	user, err = h.identityRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user.EmailVerifiedAt == nil {
+		if err := h.sendVerification(r.Context(), r, user, email); err != nil {
+			http.Error(w, "Failed to send verification email: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	h.writeIdentities(w, r, userID)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+// oauthState is what the login handler remembers for the callback. It rides
+// in a short-lived cookie signed with the JWT secret, so nothing has to be
+// stored server side between the two requests.
+type oauthState struct {
+	State    string `json:"s"`
+	Verifier string `json:"v"`
+	Redirect string `json:"r"`
+	Expires  int64  `json:"e"`
+	// LinkUserID is set when a signed-in user is adding Google to their
+	// account rather than logging in, and Merge when they agreed to fold in
+	// an account that already uses that Google login.
+	LinkUserID int  `json:"l,omitempty"`
+	Merge      bool `json:"m,omitempty"`
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func signPayload(payload []byte) string {
+	mac := hmac.New(sha256.New, getJWTSecret())
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifyPayload(value string) ([]byte, error) {
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, errors.New("malformed value")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, getJWTSecret())
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return nil, errors.New("bad signature")
+	}
+	return payload, nil
+}
+
+func setOAuthState(w http.ResponseWriter, r *http.Request, st oauthState) error {
+	st.Expires = time.Now().Add(oauthStateTTL).Unix()
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    signPayload(payload),
+		Path:     "/api/auth/google",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		// Lax, not Strict: the callback is a top-level navigation coming
+		// back from the provider's domain.
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// consumeOAuthState reads and clears the state cookie, and checks it against
+// the state the provider echoed back.
+func consumeOAuthState(w http.ResponseWriter, r *http.Request) (*oauthState, error) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/api/auth/google",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil {
+		return nil, errors.New("missing state cookie")
+	}
+	payload, err := verifyPayload(cookie.Value)
+	if err != nil {
+		return nil, errors.New("invalid state cookie")
+	}
+	var st oauthState
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return nil, errors.New("invalid state cookie")
+	}
+	if time.Now().Unix() > st.Expires {
+		return nil, errors.New("login attempt expired")
+	}
+	if st.State == "" || subtle.ConstantTimeCompare([]byte(st.State), []byte(r.FormValue("state"))) != 1 {
+		return nil, errors.New("state mismatch")
+	}
+	return &st, nil
+}
+
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func frontendURL() string {
+	if u := os.Getenv("FRONTEND_URL"); u != "" {
+		return u
+	}
+	return "/"
+}
+
+// allowedRedirectOrigins is FRONTEND_URL plus any extra origins listed in
+// ALLOWED_REDIRECTS (comma separated).
+func allowedRedirectOrigins() []string {
+	origins := []string{}
+	for _, raw := range append([]string{os.Getenv("FRONTEND_URL")}, strings.Split(os.Getenv("ALLOWED_REDIRECTS"), ",")...) {
+		if origin := originOf(strings.TrimSpace(raw)); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+func originOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// safeRedirect returns target if it is a same-site path or points at an
+// allowlisted origin, and the default frontend URL otherwise.
+func safeRedirect(target string, allowed []string) string {
+	if target == "" {
+		return frontendURL()
+	}
+	// Local paths only; "//host" and "/\host" are protocol-relative
+	// redirects to another site in most browsers.
+	if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") && !strings.HasPrefix(target, "/\\") {
+		return target
+	}
+	if origin := originOf(target); origin != "" {
+		for _, a := range allowed {
+			if origin == a {
+				return target
+			}
+		}
+	}
+	return frontendURL()
+}
+
+// withQuery adds a query parameter to a redirect target.
+func withQuery(target, key, value string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"fitness-buddy/internal/account"
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/domain/apitoken"
+	"fitness-buddy/internal/domain/identity"
+	"fitness-buddy/internal/domain/session"
+	"fitness-buddy/internal/testdb"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeGoogle stands in for Google's token and userinfo endpoints. Like the
+// real thing, it remembers the PKCE challenge each code was issued for and
+// refuses to redeem the code without the matching verifier.
+type fakeGoogle struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu         sync.Mutex
+	challenges map[string]string
+	exchanges  int
+}
+
+func newFakeGoogle(t *testing.T) *fakeGoogle {
+	f := &fakeGoogle{t: t, challenges: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", f.token)
+	mux.HandleFunc("GET /userinfo", f.userInfo)
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// authorize plays the user approving the login at authURL and returns the
+// code and state Google would send back to the callback.
+func (f *fakeGoogle) authorize(authURL string) (code, state string) {
+	f.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		f.t.Fatalf("login URL has no S256 PKCE challenge: %s", authURL)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code = "code-" + strconv.Itoa(len(f.challenges)+1)
+	f.challenges[code] = q.Get("code_challenge")
+	return code, q.Get("state")
+}
+
+func (f *fakeGoogle) token(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exchanges++
+	challenge, ok := f.challenges[r.FormValue("code")]
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_grant"}`))
+		return
+	}
+	delete(f.challenges, r.FormValue("code"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"access_token": "google-access", "token_type": "Bearer", "expires_in": 3600}`))
+}
+
+func (f *fakeGoogle) exchangeCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.exchanges
+}
+
+func (f *fakeGoogle) userInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer google-access" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Write([]byte(`{"id": "google-1", "email": "ada@example.com", "name": "Ada"}`))
+}
+
+func newOAuthTestHandler(t *testing.T) (*AuthHandler, *fakeGoogle, *identity.Repository) {
+	t.Helper()
+	t.Setenv("FRONTEND_URL", "https://app.example.com")
+	t.Setenv("ALLOWED_REDIRECTS", "https://m.example.com")
+	db := testdb.SQLite(t)
+	users := identity.NewRepository(db)
+	h := NewAuthHandler(users, session.NewRepository(db), apitoken.NewRepository(db), account.NewDeleter(db))
+
+	google := newFakeGoogle(t)
+	h.oauthConfig = &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://api.example.com/api/auth/google/callback",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   google.srv.URL + "/auth",
+			TokenURL:  google.srv.URL + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+	h.userInfoURL = google.srv.URL + "/userinfo"
+	return h, google, users
+}
+
+// startLogin returns the provider URL the login sent the browser to and the
+// state cookie it set.
+func startLogin(t *testing.T, h *AuthHandler, redirect string) (string, *http.Cookie) {
+	t.Helper()
+	target := "/api/auth/google/login"
+	if redirect != "" {
+		target += "?redirect=" + url.QueryEscape(redirect)
+	}
+	rec := httptest.NewRecorder()
+	h.HandleGoogleLogin(rec, httptest.NewRequest("GET", target, nil))
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("login: status %d", rec.Code)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oauthStateCookie {
+			return rec.Header().Get("Location"), c
+		}
+	}
+	t.Fatal("login set no state cookie")
+	return "", nil
+}
+
+func callback(h *AuthHandler, code, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	q := url.Values{"code": {code}, "state": {state}}
+	req := httptest.NewRequest("GET", "/api/auth/google/callback?"+q.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	h.HandleGoogleCallback(rec, req)
+	return rec
+}
+
+func hasCookie(rec *httptest.ResponseRecorder, name string) bool {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == name && c.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGoogleCallbackSignsIn(t *testing.T) {
+	h, google, users := newOAuthTestHandler(t)
+
+	authURL, cookie := startLogin(t, h, "https://m.example.com/done")
+	code, state := google.authorize(authURL)
+	rec := callback(h, code, state, cookie)
+
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Location"); got != "https://m.example.com/done" {
+		t.Errorf("redirected to %q, want the allowlisted target", got)
+	}
+	if !hasCookie(rec, accessCookie) || !hasCookie(rec, refreshCookie) {
+		t.Error("no session cookies set")
+	}
+	user, err := users.GetUserByIdentity(t.Context(), identity.ProviderGoogle, "google-1")
+	if err != nil {
+		t.Fatalf("no user for the Google login: %v", err)
+	}
+	if user.Email == nil || *user.Email != "ada@example.com" {
+		t.Errorf("email = %v, want ada@example.com", user.Email)
+	}
+
+	// The state cookie is spent: replaying the callback fails.
+	if rec := callback(h, code, state, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("replay without the cookie: status %d, want 400", rec.Code)
+	}
+}
+
+func TestGoogleCallbackRejectsBadState(t *testing.T) {
+	h, google, _ := newOAuthTestHandler(t)
+	authURL, cookie := startLogin(t, h, "")
+	code, state := google.authorize(authURL)
+
+	expired, err := json.Marshal(oauthState{State: state, Verifier: "v", Redirect: "/", Expires: time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := *cookie
+	tampered.Value = cookie.Value[:len(cookie.Value)-2] + "AA"
+
+	tests := []struct {
+		name   string
+		state  string
+		cookie *http.Cookie
+	}{
+		{name: "state mismatch", state: state + "x", cookie: cookie},
+		{name: "no state", state: "", cookie: cookie},
+		{name: "no cookie", state: state},
+		{name: "tampered cookie", state: state, cookie: &tampered},
+		{name: "expired", state: state, cookie: &http.Cookie{Name: oauthStateCookie, Value: signPayload(expired)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := callback(h, code, tt.state, tt.cookie)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status %d, want 400", rec.Code)
+			}
+			if hasCookie(rec, accessCookie) {
+				t.Error("session cookie set")
+			}
+		})
+	}
+	if n := google.exchangeCount(); n != 0 {
+		t.Errorf("code exchanged %d times, want 0", n)
+	}
+}
+
+// TestGoogleCallbackSendsOwnVerifier completes one login with the state
+// cookie of another. The state checks out, but the verifier in that cookie
+// doesn't match the challenge the code was issued for.
+func TestGoogleCallbackSendsOwnVerifier(t *testing.T) {
+	h, google, _ := newOAuthTestHandler(t)
+	authURL, _ := startLogin(t, h, "")
+	otherURL, otherCookie := startLogin(t, h, "")
+	code, _ := google.authorize(authURL)
+	_, otherState := google.authorize(otherURL)
+
+	rec := callback(h, code, otherState, otherCookie)
+	if rec.Code < 400 {
+		t.Fatalf("status %d, want an error", rec.Code)
+	}
+	if hasCookie(rec, accessCookie) {
+		t.Error("session cookie set")
+	}
+	if n := google.exchangeCount(); n != 1 {
+		t.Errorf("code exchanged %d times, want 1", n)
+	}
+}
+
+func TestGoogleLoginRedirectAllowlist(t *testing.T) {
+	h, google, _ := newOAuthTestHandler(t)
+	tests := []struct {
+		redirect string
+		want     string
+	}{
+		{redirect: "", want: "https://app.example.com"},
+		{redirect: "/settings?tab=logins", want: "/settings?tab=logins"},
+		{redirect: "https://app.example.com/today", want: "https://app.example.com/today"},
+		{redirect: "HTTPS://APP.EXAMPLE.COM/today", want: "HTTPS://APP.EXAMPLE.COM/today"},
+		{redirect: "https://m.example.com/done", want: "https://m.example.com/done"},
+		{redirect: "http://app.example.com/today", want: "https://app.example.com"},
+		{redirect: "https://app.example.com.evil.example/", want: "https://app.example.com"},
+		{redirect: "https://evil.example/?to=https://app.example.com", want: "https://app.example.com"},
+		{redirect: "//evil.example/", want: "https://app.example.com"},
+		{redirect: "/\\evil.example/", want: "https://app.example.com"},
+		{redirect: "javascript:alert(1)", want: "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.redirect, func(t *testing.T) {
+			authURL, cookie := startLogin(t, h, tt.redirect)
+			code, state := google.authorize(authURL)
+			rec := callback(h, code, state, cookie)
+			if rec.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("status %d (%s)", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("redirected to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// startLink starts linking Google to userID's account the way the frontend
+// does, with a POST, and returns the consent URL and the state cookie.
+func startLink(t *testing.T, h *AuthHandler, userID int, body string) (string, *http.Cookie) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/user/identities/google/link", strings.NewReader(body))
+	req = req.WithContext(auth.WithUserID(req.Context(), userID))
+	rec := httptest.NewRecorder()
+	h.HandleLinkGoogle(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("link: status %d (%s)", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		AuthURL string `json:"auth_url"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oauthStateCookie {
+			return resp.AuthURL, c
+		}
+	}
+	t.Fatal("link set no state cookie")
+	return "", nil
+}
+
+func TestLinkGoogleMerges(t *testing.T) {
+	h, google, users := newOAuthTestHandler(t)
+	owner, err := users.GetOrCreateUserByGoogleID(t.Context(), "google-1", "ada@example.com", "Ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caller, err := users.CreateUser(t.Context(), "Ada again", nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without merge the link is refused and both accounts stay.
+	authURL, cookie := startLink(t, h, caller.ID, `{"redirect": "/profile"}`)
+	code, state := google.authorize(authURL)
+	rec := callback(h, code, state, cookie)
+	if got := rec.Header().Get("Location"); !strings.Contains(got, "link_error=taken") {
+		t.Fatalf("link without merge redirected to %q, want a taken error", got)
+	}
+	if u, err := users.GetUserByIdentity(t.Context(), identity.ProviderGoogle, "google-1"); err != nil || u.ID != owner.ID {
+		t.Fatalf("Google login moved without merge: %v, %v", u, err)
+	}
+
+	authURL, cookie = startLink(t, h, caller.ID, `{"redirect": "/profile", "merge": true}`)
+	code, state = google.authorize(authURL)
+	rec = callback(h, code, state, cookie)
+	if rec.Code != http.StatusTemporaryRedirect || rec.Header().Get("Location") != "/profile?linked=google" {
+		t.Fatalf("merge: status %d, location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	u, err := users.GetUserByIdentity(t.Context(), identity.ProviderGoogle, "google-1")
+	if err != nil || u.ID != caller.ID {
+		t.Fatalf("Google login is on %v (%v), want the caller", u, err)
+	}
+	if _, err := users.GetUserByID(t.Context(), owner.ID); err == nil {
+		t.Error("merged account still exists")
+	}
+}
+
+// TestLinkGoogleGetCannotMerge follows a link someone could plant on another
+// site. A GET must not start the flow, or the callback would merge the
+// signed-in user's account with whichever Google account completes it.
+func TestLinkGoogleGetCannotMerge(t *testing.T) {
+	db := testdb.SQLite(t)
+	router := NewRouter(db, fstest.MapFS{})
+	user := insertID(t, db, `INSERT INTO users (name, email) VALUES ('Ada', 'ada@example.com')`)
+	c := signIn(t, db, router, user)
+
+	rec := c.do("GET", "/api/user/identities/google/link?merge=true&redirect=/profile", "")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status %d, want 405", rec.Code)
+	}
+	if hasCookie(rec, oauthStateCookie) {
+		t.Error("a GET set the OAuth state cookie")
+	}
+}
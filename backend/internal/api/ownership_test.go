@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/domain/session"
+	"fitness-buddy/internal/testdb"
+)
+
+// testClient makes requests to the full router signed in as one user.
+type testClient struct {
+	t      *testing.T
+	router http.Handler
+	access string
+}
+
+func signIn(t *testing.T, db *database.DB, router http.Handler, userID int) testClient {
+	t.Helper()
+	s, _, err := session.NewRepository(db).Create(context.Background(), userID, "test", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	access, err := issueToken(userID, s.ID, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	return testClient{t, router, access}
+}
+
+func (c testClient) do(method, path, body string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: accessCookie, Value: c.access})
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func insertID(t *testing.T, db *database.DB, query string, args ...any) int {
+	t.Helper()
+	var id int
+	if err := db.Pool.QueryRow(query+" RETURNING id", args...).Scan(&id); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return id
+}
+
+// TestMutationsAreScopedToOwner has one user try every mutating route on
+// another user's rows. Each attempt must answer 404 and change nothing; the
+// owner then makes the same requests to show the routes and IDs were right.
+func TestMutationsAreScopedToOwner(t *testing.T) {
+	db := testdb.SQLite(t)
+	router := NewRouter(db, fstest.MapFS{})
+	now := time.Now().UTC()
+
+	alice := insertID(t, db, `INSERT INTO users (name, email) VALUES ('Alice', 'alice@example.com')`)
+	bob := insertID(t, db, `INSERT INTO users (name, email) VALUES ('Bob', 'bob@example.com')`)
+	exercise := insertID(t, db, `INSERT INTO exercises (name, category) VALUES ('Test lift', 'Strength')`)
+	workout := insertID(t, db, `INSERT INTO workout_sessions (user_id, start_time) VALUES ($1, $2)`, bob, now)
+	set := insertID(t, db, `INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, performed_at) VALUES ($1, $2, 1, 100, 5, $3)`, workout, exercise, now)
+	routine := insertID(t, db, `INSERT INTO routines (user_id, name) VALUES ($1, 'Push')`, bob)
+	insertID(t, db, `INSERT INTO routine_exercises (routine_id, exercise_id, exercise_order) VALUES ($1, $2, 1)`, routine, exercise)
+	run := insertID(t, db, `INSERT INTO runs (user_id, start_time, duration_seconds, distance_meters) VALUES ($1, $2, 1800, 5000)`, bob, now)
+	meal := insertID(t, db, `INSERT INTO meals (user_id, name, eaten_at) VALUES ($1, 'Lunch', $2)`, bob, now)
+	entry := insertID(t, db, `INSERT INTO food_entries (meal_id, name, calories) VALUES ($1, 'Rice', 300)`, meal)
+	goal := insertID(t, db, `INSERT INTO nutrition_goals (user_id, effective_from, calories) VALUES ($1, '2026-01-01', 2000)`, bob)
+
+	id := strconv.Itoa
+	// Updates come before the deletes that would take their rows away.
+	requests := []struct{ method, path, body string }{
+		{"PUT", "/api/sets/" + id(set), `{"weight_kg": 50, "reps": 8}`},
+		{"POST", "/api/sessions/" + id(workout) + "/sets", `{"exercise_id": ` + id(exercise) + `, "weight_kg": 50, "reps": 8}`},
+		{"POST", "/api/sessions/" + id(workout) + "/finish", ``},
+		{"POST", "/api/sessions/" + id(workout) + "/comments", `{"body": "Nice"}`},
+		{"POST", "/api/routines/" + id(routine) + "/start", `{}`},
+		{"POST", "/api/runs/" + id(run) + "/comments", `{"body": "Nice"}`},
+		{"PUT", "/api/meals/" + id(meal), `{"name": "Dinner"}`},
+		{"POST", "/api/meals/" + id(meal) + "/entries", `{"name": "Beans", "calories": 200}`},
+		{"POST", "/api/meals/" + id(meal) + "/comments", `{"body": "Nice"}`},
+		{"DELETE", "/api/meals/entries/" + id(entry), ``},
+		{"DELETE", "/api/nutrition/goals/" + id(goal), ``},
+		{"DELETE", "/api/sets/" + id(set), ``},
+		{"DELETE", "/api/routines/" + id(routine), ``},
+		{"DELETE", "/api/sessions/" + id(workout), ``},
+		{"DELETE", "/api/runs/" + id(run), ``},
+		{"DELETE", "/api/meals/" + id(meal), ``},
+	}
+
+	// snapshot sums up Bob's rows so any change to them shows.
+	snapshot := func() string {
+		t.Helper()
+		var parts []string
+		for _, query := range []string{
+			`SELECT COUNT(*) FROM workout_sessions WHERE user_id = $1 AND end_time IS NULL`,
+			`SELECT COUNT(*) FROM workout_sets ws JOIN workout_sessions s ON s.id = ws.session_id WHERE s.user_id = $1`,
+			`SELECT COALESCE(SUM(ws.weight_kg * ws.reps), 0) FROM workout_sets ws JOIN workout_sessions s ON s.id = ws.session_id WHERE s.user_id = $1`,
+			`SELECT COUNT(*) FROM routines WHERE user_id = $1`,
+			`SELECT COUNT(*) FROM runs WHERE user_id = $1`,
+			`SELECT COUNT(*) FROM meals WHERE user_id = $1 AND name = 'Lunch'`,
+			`SELECT COUNT(*) FROM food_entries fe JOIN meals m ON m.id = fe.meal_id WHERE m.user_id = $1`,
+			`SELECT COUNT(*) FROM nutrition_goals WHERE user_id = $1`,
+			`SELECT COUNT(*) FROM comments WHERE user_id <> $1`,
+		} {
+			var v string
+			if err := db.Pool.QueryRow(query, bob).Scan(&v); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+			parts = append(parts, v)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	before := snapshot()
+	a := signIn(t, db, router, alice)
+	for _, req := range requests {
+		if rec := a.do(req.method, req.path, req.body); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s by another user: status %d, want 404 (%s)", req.method, req.path, rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+	if after := snapshot(); after != before {
+		t.Errorf("another user's requests changed the owner's rows: %s, want %s", after, before)
+	}
+
+	b := signIn(t, db, router, bob)
+	for _, req := range requests {
+		if rec := b.do(req.method, req.path, req.body); rec.Code >= 300 {
+			t.Errorf("%s %s by the owner: status %d (%s)", req.method, req.path, rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+}
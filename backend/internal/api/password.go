@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/domain/identity"
+	"fitness-buddy/internal/mail"
+)
+
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// checkDummyPassword burns the same time as a real password check, so a
+// login for an unknown email can't be told apart by how long it takes.
+func checkDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = auth.HashPassword("not a real password")
+	})
+	auth.CheckPassword(password, dummyHash)
+}
+
+// getMailer returns the configured mailer, or nil when there is none, which
+// turns off everything that mails a link.
+func getMailer() mail.Mailer {
+	m, err := mail.FromEnv()
+	if err != nil {
+		log.Printf("Email registration, verification and password reset are disabled: %v", err)
+		return nil
+	}
+	return m
+}
+
+// requireMailer answers 503 itself when no mailer is configured.
+func (h *AuthHandler) requireMailer(w http.ResponseWriter) bool {
+	if h.mailer == nil {
+		http.Error(w, "Email is not configured on this server", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func normalizeEmail(raw string) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(raw))
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errors.New("invalid email address")
+	}
+	return email, nil
+}
+
+// publicURL builds an absolute link into the frontend for emails. It uses
+// FRONTEND_URL when that is absolute and the request's own host otherwise.
+func publicURL(r *http.Request, path string, query url.Values) string {
+	base := strings.TrimSuffix(frontendURL(), "/")
+	if originOf(base) == "" {
+		scheme := "http"
+		if isSecureRequest(r) {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + path + "?" + query.Encode()
+}
+
+func (h *AuthHandler) sendVerification(ctx context.Context, r *http.Request, user *identity.User, email string) error {
+	token, err := h.identityRepo.CreateEmailToken(ctx, user.ID, identity.PurposeVerifyEmail, email, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	link := publicURL(r, "/login", url.Values{"verify": {token}})
+	return h.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your Fitness Buddy email",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening this link within 24 hours:\n\n%s\n\nIf you didn't sign up, you can ignore this message.\n",
+			user.Name, link),
+	})
+}
+
+func (h *AuthHandler) sendPasswordReset(ctx context.Context, r *http.Request, user *identity.User, email string) error {
+	token, err := h.identityRepo.CreateEmailToken(ctx, user.ID, identity.PurposeResetPassword, email, resetPasswordTTL)
+	if err != nil {
+		return err
+	}
+	link := publicURL(r, "/login", url.Values{"reset": {token}})
+	return h.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Reset your Fitness Buddy password",
+		Body: fmt.Sprintf("Hi %s,\n\nChoose a new password by opening this link within an hour:\n\n%s\n\nIf you didn't ask for this, you can ignore this message; your password is unchanged.\n",
+			user.Name, link),
+	})
+}
+
+// userForEmail finds the account an address signs in to, falling back to
+// the account that merely has it on file (a Google login, say).
+func (h *AuthHandler) userForEmail(ctx context.Context, email string) (*identity.User, error) {
+	user, err := h.identityRepo.GetUserByIdentity(ctx, identity.ProviderEmail, email)
+	if err == sql.ErrNoRows {
+		return h.identityRepo.GetUserByEmail(ctx, email)
+	}
+	return user, err
+}
+
+// acceptedResponse is the answer to register, resend and forgot alike, so
+// that none of them reveal whether an address has an account.
+func acceptedResponse(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "If the address can be used, we've sent an email with next steps.",
+	})
+}
+
+// HandlePasswordRegister creates a local account and mails a verification
+// link. Signing in has to wait until the address is confirmed.
+func (h *AuthHandler) HandlePasswordRegister(w http.ResponseWriter, r *http.Request) {
+	if !h.requireMailer(w) {
+		return
+	}
+	var req struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "User"
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.identityRepo.CreatePasswordUser(r.Context(), name, email, hash)
+	if errors.Is(err, identity.ErrEmailTaken) {
+		// Tell the owner rather than the caller. A reset link is the useful
+		// thing to send: it works whether or not they already have a password.
+		if existing, err := h.userForEmail(r.Context(), email); err == nil {
+			if err := h.sendPasswordReset(r.Context(), r, existing, email); err != nil {
+				log.Printf("Failed to send password reset to %s: %v", email, err)
+			}
+		}
+		acceptedResponse(w)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.sendVerification(r.Context(), r, user, email); err != nil {
+		http.Error(w, "Failed to send verification email: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	acceptedResponse(w)
+}
+
+// HandlePasswordLogin signs in with email and password.
+func (h *AuthHandler) HandlePasswordLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil || len(req.Password) > auth.MaxPasswordLength {
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.identityRepo.GetUserByIdentity(r.Context(), identity.ProviderEmail, email)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Failed to look up user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hash := ""
+	if user != nil {
+		if hash, err = h.identityRepo.GetPasswordHash(r.Context(), user.ID); err != nil {
+			http.Error(w, "Failed to look up user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if hash == "" {
+		checkDummyPassword(req.Password)
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+	if err := auth.CheckPassword(req.Password, hash); err != nil {
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+	if user.EmailVerifiedAt == nil {
+		http.Error(w, "Email address not verified", http.StatusForbidden)
+		return
+	}
+
+	if err := h.startSession(w, r, user.ID); err != nil {
+		http.Error(w, "Failed to start session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user":    user,
+	})
+}
+
+// HandleVerifyEmail redeems a verification link and signs the user in.
+func (h *AuthHandler) HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return
+	}
+
+	userID, email, err := h.identityRepo.ConsumeEmailToken(r.Context(), identity.PurposeVerifyEmail, req.Token)
+	if err == nil {
+		err = h.identityRepo.MarkEmailVerified(r.Context(), userID, email)
+	}
+	if errors.Is(err, identity.ErrInvalidToken) {
+		http.Error(w, "This link is invalid or has expired", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to verify email: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.identityRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err := h.startSession(w, r, user.ID); err != nil {
+		http.Error(w, "Failed to start session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user":    user,
+	})
+}
+
+// HandleResendVerification mails a fresh link to an unverified address.
+func (h *AuthHandler) HandleResendVerification(w http.ResponseWriter, r *http.Request) {
+	if !h.requireMailer(w) {
+		return
+	}
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.identityRepo.GetUserByEmail(r.Context(), email)
+	if err == nil && user.EmailVerifiedAt == nil {
+		if err := h.sendVerification(r.Context(), r, user, email); err != nil {
+			log.Printf("Failed to send verification to %s: %v", email, err)
+		}
+	}
+	acceptedResponse(w)
+}
+
+// HandleForgotPassword mails a reset link to any account with the address,
+// including Google and phone accounts that have never set a password.
+func (h *AuthHandler) HandleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if !h.requireMailer(w) {
+		return
+	}
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if user, err := h.userForEmail(r.Context(), email); err == nil {
+		if err := h.sendPasswordReset(r.Context(), r, user, email); err != nil {
+			log.Printf("Failed to send password reset to %s: %v", email, err)
+		}
+	}
+	acceptedResponse(w)
+}
+
+// HandleResetPassword sets a new password from a reset link. Every existing
+// session is signed out, and the caller gets a fresh one. Receiving the link
+// proves the address, so it also counts as verification.
+func (h *AuthHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return
+	}
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	userID, email, err := h.identityRepo.ConsumeEmailToken(r.Context(), identity.PurposeResetPassword, req.Token)
+	if errors.Is(err, identity.ErrInvalidToken) {
+		http.Error(w, "This link is invalid or has expired", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.identityRepo.SetPassword(r.Context(), userID, hash); err != nil {
+		http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The address now signs in with the password. If it is somehow linked
+	// to a different account, that account keeps it.
+	if err := h.identityRepo.LinkIdentity(r.Context(), userID, identity.ProviderEmail, email, email); err != nil && !errors.Is(err, identity.ErrIdentityTaken) {
+		http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.identityRepo.MarkEmailVerified(r.Context(), userID, email); err != nil && !errors.Is(err, identity.ErrInvalidToken) {
+		http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.sessions.RevokeAll(r.Context(), userID, ""); err != nil {
+		http.Error(w, "Failed to sign out old sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.identityRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err := h.startSession(w, r, user.ID); err != nil {
+		http.Error(w, "Failed to start session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user":    user,
+	})
+}
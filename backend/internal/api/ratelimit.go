@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter allows each client limit requests per fixed window. Clients are
+// told apart by IP address, which middleware.RealIP has already resolved.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, clients: map[string]*rateWindow{}}
+}
+
+// allow counts a request from key and reports whether it is within the
+// limit, and if not, how long until the client's window resets.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Forget finished windows now and then so the map doesn't grow with
+	// every address ever seen.
+	if len(l.clients) >= 1024 {
+		for k, c := range l.clients {
+			if now.Sub(c.start) >= l.window {
+				delete(l.clients, k)
+			}
+		}
+	}
+
+	c, ok := l.clients[key]
+	if !ok || now.Sub(c.start) >= l.window {
+		c = &rateWindow{start: now}
+		l.clients[key] = c
+	}
+	if c.count >= l.limit {
+		return false, c.start.Add(l.window).Sub(now)
+	}
+	c.count++
+	return true, 0
+}
+
+func (l *rateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		ok, retry := l.allow(ip, time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+			http.Error(w, "Too many requests, try again later", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
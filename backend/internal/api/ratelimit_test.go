@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	l := newRateLimiter(2, time.Hour)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("1.2.3.4", now); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	ok, retry := l.allow("1.2.3.4", now.Add(10*time.Minute))
+	if ok {
+		t.Fatal("third request allowed")
+	}
+	if retry != 50*time.Minute {
+		t.Errorf("retry = %v, want 50m", retry)
+	}
+	if ok, _ := l.allow("5.6.7.8", now); !ok {
+		t.Error("another client was refused")
+	}
+	if ok, _ := l.allow("1.2.3.4", now.Add(time.Hour)); !ok {
+		t.Error("request in the next window refused")
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	h := newRateLimiter(1, time.Hour).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/demo", nil)
+		req.RemoteAddr = "1.2.3.4:5678"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("429 without Retry-After")
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("codes = %v, want [200 429]", codes)
+	}
+}
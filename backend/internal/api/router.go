@@ -4,14 +4,21 @@ import (
 	"io/fs"
 	"net/http"
 	"strings"
+	"time"
 
+	"fitness-buddy/internal/account"
 	"fitness-buddy/internal/database"
 	"fitness-buddy/internal/domain/analytics"
+	"fitness-buddy/internal/domain/apitoken"
 	"fitness-buddy/internal/domain/body"
+	"fitness-buddy/internal/domain/coaching"
 	"fitness-buddy/internal/domain/identity"
 	"fitness-buddy/internal/domain/nutrition"
 	"fitness-buddy/internal/domain/resistance"
 	"fitness-buddy/internal/domain/running"
+	"fitness-buddy/internal/domain/session"
+	"fitness-buddy/internal/energy"
+	"fitness-buddy/internal/takeout"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -23,10 +30,14 @@ func NewRouter(db *database.DB, frontendFS fs.FS) http.Handler {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RealIP)
-	r.Use(JWTMiddleware)
 
 	identityRepo := identity.NewRepository(db)
-	authHandler := NewAuthHandler(identityRepo)
+	sessionRepo := session.NewRepository(db)
+	tokenRepo := apitoken.NewRepository(db)
+	coachingRepo := coaching.NewRepository(db)
+	coachingHandler := coaching.NewHandler(coachingRepo)
+	authHandler := NewAuthHandler(identityRepo, sessionRepo, tokenRepo, account.NewDeleter(db))
+	r.Use(authHandler.JWTMiddleware)
 
 	r.Route("/api", func(r chi.Router) {
 		// Set Content-Type for all API responses
@@ -39,30 +50,111 @@ func NewRouter(db *database.DB, frontendFS fs.FS) http.Handler {
 		r.Get("/auth/google/login", authHandler.HandleGoogleLogin)
 		r.Get("/auth/google/callback", authHandler.HandleGoogleCallback)
 		r.Get("/auth/logout", authHandler.HandleLogout)
+		r.Post("/auth/refresh", authHandler.HandleRefresh)
 		r.Post("/auth/phone", authHandler.HandlePhoneAuth)
+		r.Post("/auth/password/register", authHandler.HandlePasswordRegister)
+		r.Post("/auth/password/login", authHandler.HandlePasswordLogin)
+		r.Post("/auth/password/verify", authHandler.HandleVerifyEmail)
+		r.Post("/auth/password/resend", authHandler.HandleResendVerification)
+		r.Post("/auth/password/forgot", authHandler.HandleForgotPassword)
+		r.Post("/auth/password/reset", authHandler.HandleResetPassword)
+		if demoModeEnabled() {
+			r.With(newRateLimiter(demoLoginsPerHour, time.Hour).Middleware).Post("/auth/demo", authHandler.HandleDemoLogin)
+		}
 
-		identityHandler := identity.NewHandler(identityRepo)
-		identityHandler.RegisterRoutes(r)
+		// Account management is for interactive logins only.
+		r.Group(func(r chi.Router) {
+			r.Use(requireSession)
+			r.Post("/auth/logout-all", authHandler.HandleLogoutAll)
+			r.Get("/auth/sessions", authHandler.HandleListSessions)
+			r.Delete("/auth/sessions/{id}", authHandler.HandleRevokeSession)
+			if demoModeEnabled() {
+				r.Post("/auth/demo/reset", authHandler.HandleDemoReset)
+			}
+
+			r.Delete("/user", authHandler.HandleDeleteAccount)
+			r.Post("/user/restore", authHandler.HandleRestoreAccount)
+			r.Get("/user/identities", authHandler.HandleListIdentities)
+			r.Delete("/user/identities/{id}", authHandler.HandleUnlinkIdentity)
+			r.Post("/user/identities/google/link", authHandler.HandleLinkGoogle)
+			r.Post("/user/identities/phone", authHandler.HandleLinkPhone)
+			r.Post("/user/identities/email", authHandler.HandleLinkEmail)
 
-		resistanceRepo := resistance.NewRepository(db)
-		resistanceHandler := resistance.NewHandler(resistanceRepo)
-		resistanceHandler.RegisterRoutes(r)
+			tokenHandler := apitoken.NewHandler(tokenRepo)
+			tokenHandler.RegisterRoutes(r)
 
-		runningRepo := running.NewRepository(db)
-		runningHandler := running.NewHandler(runningRepo)
-		runningHandler.RegisterRoutes(r)
+			// Archives span every scope group, so export and import
+			// aren't offered to API tokens.
+			takeoutHandler := takeout.NewHandler(takeout.NewExporter(db), takeout.NewImporter(db))
+			takeoutHandler.RegisterRoutes(r)
 
-		nutritionRepo := nutrition.NewRepository(db)
-		nutritionHandler := nutrition.NewHandler(nutritionRepo)
-		nutritionHandler.RegisterRoutes(r)
+			coachingHandler.RegisterRoutes(r)
+		})
 
-		bodyRepo := body.NewRepository(db)
-		bodyHandler := body.NewHandler(bodyRepo)
-		bodyHandler.RegisterRoutes(r)
+		// Each domain is its own scope group for API tokens; see
+		// auth.ScopeGroups. The groups in coaching.Groups can also be read
+		// by a coach with ?user_id=. Handlers only deal in metric units;
+		// convertUnits translates at the edge. Groups with per-day figures
+		// get the owner's timezone from localDays.
+		r.Group(func(r chi.Router) {
+			r.Use(requireScope("identity"))
+			r.Use(convertUnits(identityRepo))
+			r.Use(localDays(identityRepo))
+			identityHandler := identity.NewHandler(identityRepo)
+			identityHandler.RegisterRoutes(r)
+			energyHandler := energy.NewHandler(energy.NewRepository(db))
+			energyHandler.RegisterRoutes(r)
+		})
 
-		analyticsRepo := analytics.NewRepository(db)
-		analyticsHandler := analytics.NewHandler(analyticsRepo)
-		analyticsHandler.RegisterRoutes(r)
+		r.Group(func(r chi.Router) {
+			r.Use(requireScope("resistance"))
+			r.Use(allowDelegation(coachingRepo, "resistance"))
+			r.Use(convertUnits(identityRepo))
+			r.Use(localDays(identityRepo))
+			resistanceRepo := resistance.NewRepository(db)
+			resistanceHandler := resistance.NewHandler(resistanceRepo)
+			resistanceHandler.RegisterRoutes(r)
+			coachingHandler.RegisterCommentRoutes(r, coaching.TargetSession)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireScope("running"))
+			r.Use(allowDelegation(coachingRepo, "running"))
+			r.Use(convertUnits(identityRepo))
+			runningRepo := running.NewRepository(db)
+			runningHandler := running.NewHandler(runningRepo)
+			runningHandler.RegisterRoutes(r)
+			coachingHandler.RegisterCommentRoutes(r, coaching.TargetRun)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireScope("nutrition"))
+			r.Use(allowDelegation(coachingRepo, "nutrition"))
+			r.Use(convertUnits(identityRepo))
+			r.Use(localDays(identityRepo))
+			nutritionRepo := nutrition.NewRepository(db)
+			nutritionHandler := nutrition.NewHandler(nutritionRepo)
+			nutritionHandler.RegisterRoutes(r)
+			coachingHandler.RegisterCommentRoutes(r, coaching.TargetMeal)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireScope("body"))
+			r.Use(allowDelegation(coachingRepo, "body"))
+			r.Use(convertUnits(identityRepo))
+			bodyRepo := body.NewRepository(db)
+			bodyHandler := body.NewHandler(bodyRepo)
+			bodyHandler.RegisterRoutes(r)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireScope("analytics"))
+			r.Use(convertUnits(identityRepo))
+			r.Use(localDays(identityRepo))
+			analyticsRepo := analytics.NewRepository(db)
+			analyticsHandler := analytics.NewHandler(analyticsRepo)
+			analyticsHandler.RegisterRoutes(r)
+		})
 	})
 
 	// Serve Frontend
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/domain/coaching"
+)
+
+// requireScope limits a route group to API tokens that carry a scope for it.
+// Safe methods need read access, everything else write access. Cookie
+// sessions pass straight through.
+func requireScope(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			access := auth.AccessWrite
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				access = auth.AccessRead
+			}
+			if !auth.Allowed(r.Context(), group, access) {
+				http.Error(w, "Token lacks scope "+group+":"+access, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireSession keeps API tokens away from account management: sessions,
+// tokens and anything else that should need an interactive login.
+func requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, isToken := auth.TokenScopes(r.Context()); isToken {
+			http.Error(w, "Not available to API tokens", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowDelegation lets a coach read an athlete's data in group by adding
+// ?user_id=<athlete> to a GET, as long as the athlete has granted group to
+// them. Handlers then see the athlete as the user, so none of them need to
+// know about coaching. Writes on someone else's behalf are refused.
+func allowDelegation(grants *coaching.Repository, group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := r.URL.Query().Get("user_id")
+			if raw == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			callerID, ok := auth.RequireUserID(w, r)
+			if !ok {
+				return
+			}
+			athleteID, err := strconv.Atoi(raw)
+			if err != nil {
+				http.Error(w, "Invalid user ID", http.StatusBadRequest)
+				return
+			}
+			if athleteID == callerID {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				http.Error(w, "Coaches have read-only access", http.StatusForbidden)
+				return
+			}
+
+			allowed, err := grants.Allowed(r.Context(), callerID, athleteID, group)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				http.Error(w, "No "+group+" access to this user", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithDelegatedUser(r.Context(), athleteID)))
+		})
+	}
+}
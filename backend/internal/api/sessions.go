@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/domain/session"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	accessCookie  = "auth_token"
+	refreshCookie = "refresh_token"
+
+	// accessTokenTTL bounds how long a stolen access token is useful. The
+	// frontend refreshes transparently when it runs out.
+	accessTokenTTL = 15 * time.Minute
+	// sessionTTL is how long a device stays signed in without being used;
+	// every refresh pushes it forward again.
+	sessionTTL = 30 * 24 * time.Hour
+)
+
+// startSession opens a session for the user on this device and sets the
+// access and refresh cookies.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, userID int) error {
+	s, refresh, err := h.sessions.Create(r.Context(), userID, r.UserAgent(), clientIP(r), sessionTTL)
+	if err != nil {
+		return err
+	}
+	return setAuthCookies(w, r, s, refresh)
+}
+
+// issueToken signs a short-lived access token bound to the session.
+func issueToken(userID int, sessionID string, expires time.Time) (string, error) {
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"sid":     sessionID,
+		"exp":     expires.Unix(),
+	})
+	return jwtToken.SignedString(getJWTSecret())
+}
+
+func setAuthCookies(w http.ResponseWriter, r *http.Request, s *session.Session, refresh string) error {
+	expires := time.Now().Add(accessTokenTTL)
+	access, err := issueToken(s.UserID, s.ID, expires)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessCookie,
+		Value:    access,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	// The refresh token is only ever needed by the auth endpoints, so keep
+	// it off every other request.
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    refresh,
+		Path:     "/api/auth",
+		Expires:  s.ExpiresAt,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+func clearAuthCookies(w http.ResponseWriter, r *http.Request) {
+	for _, c := range []struct{ name, path string }{{accessCookie, "/"}, {refreshCookie, "/api/auth"}} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     c.name,
+			Value:    "",
+			Path:     c.path,
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   isSecureRequest(r),
+		})
+	}
+}
+
+// clientIP is the request's remote address without the port. RealIP has
+// already replaced it with the forwarded address when there is one.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HandleRefresh swaps the refresh cookie for a new one and issues a fresh
+// access token. Replaying an old refresh token revokes the whole session.
+func (h *AuthHandler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(refreshCookie)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s, refresh, err := h.sessions.Rotate(r.Context(), cookie.Value, r.UserAgent(), clientIP(r), sessionTTL)
+	if errors.Is(err, session.ErrInvalidToken) || errors.Is(err, session.ErrTokenReused) {
+		clearAuthCookies(w, r)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to refresh session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := setAuthCookies(w, r, s, refresh); err != nil {
+		http.Error(w, "Failed to sign token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"expires_at": s.ExpiresAt,
+	})
+}
+
+// HandleListSessions lists the devices the caller is signed in on.
+func (h *AuthHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessions.ListActive(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	current := auth.GetSessionID(r.Context())
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// HandleRevokeSession signs one of the caller's devices out.
+func (h *AuthHandler) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if err := h.sessions.Revoke(r.Context(), userID, id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if id == auth.GetSessionID(r.Context()) {
+		clearAuthCookies(w, r)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleLogoutAll signs the caller out everywhere, this device included.
+func (h *AuthHandler) HandleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.sessions.RevokeAll(r.Context(), userID, ""); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	clearAuthCookies(w, r)
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/calendar"
+	"fitness-buddy/internal/domain/identity"
+)
+
+// localDays sets the timezone per-day figures are bucketed in: ?tz= if given,
+// for a client that is travelling, otherwise the profile's. Days belong to
+// whoever owns the data, so a coach sees an athlete's days in the athlete's
+// zone.
+func localDays(users *identity.Repository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if raw := r.URL.Query().Get("tz"); raw != "" {
+				loc, err := calendar.LoadLocation(raw)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				ctx = calendar.WithLocation(ctx, loc)
+			} else if userID, ok := auth.GetUserID(ctx); ok {
+				loc, err := users.GetLocation(ctx, userID)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				ctx = calendar.WithLocation(ctx, loc)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/calendar"
+	"fitness-buddy/internal/domain/identity"
+	"fitness-buddy/internal/domain/nutrition"
+	"fitness-buddy/internal/testdb"
+)
+
+func TestLocalDays(t *testing.T) {
+	db := testdb.SQLite(t)
+	userID := insertID(t, db, `INSERT INTO users (name, email, timezone) VALUES ('Traveller', 'traveller@example.com', 'Asia/Kolkata')`)
+	handler := localDays(identity.NewRepository(db))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(calendar.Location(r.Context()).String()))
+	}))
+
+	tests := []struct {
+		name     string
+		query    string
+		signedIn bool
+		wantCode int
+		wantZone string
+	}{
+		{name: "profile zone", signedIn: true, wantCode: http.StatusOK, wantZone: "Asia/Kolkata"},
+		{name: "tz overrides the profile", query: "?tz=America/Los_Angeles", signedIn: true, wantCode: http.StatusOK, wantZone: "America/Los_Angeles"},
+		{name: "tz without a user", query: "?tz=Europe/Berlin", wantCode: http.StatusOK, wantZone: "Europe/Berlin"},
+		{name: "no user", wantCode: http.StatusOK, wantZone: "UTC"},
+		{name: "server zone refused", query: "?tz=Local", signedIn: true, wantCode: http.StatusBadRequest},
+		{name: "unknown zone", query: "?tz=Mars/Olympus_Mons", signedIn: true, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/nutrition/water"+tt.query, nil)
+			if tt.signedIn {
+				req = req.WithContext(auth.WithUserID(req.Context(), userID))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK && rec.Body.String() != tt.wantZone {
+				t.Errorf("zone %s, want %s", rec.Body.String(), tt.wantZone)
+			}
+		})
+	}
+}
+
+// TestWaterDayFollowsTimezone logs water at 20:00 UTC, which is the next
+// morning at home in Kolkata but midday on a trip to Los Angeles.
+func TestWaterDayFollowsTimezone(t *testing.T) {
+	db := testdb.SQLite(t)
+	router := NewRouter(db, fstest.MapFS{})
+	userID := insertID(t, db, `INSERT INTO users (name, email, timezone) VALUES ('Traveller', 'traveller@example.com', 'Asia/Kolkata')`)
+	insertID(t, db, `INSERT INTO water_logs (user_id, amount_ml, recorded_at) VALUES ($1, 250, $2)`, userID, time.Date(2026, time.March, 1, 20, 0, 0, 0, time.UTC))
+	c := signIn(t, db, router, userID)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{query: "?date=2026-03-01", want: 0},
+		{query: "?date=2026-03-02", want: 250},
+		{query: "?date=2026-03-01&tz=America/Los_Angeles", want: 250},
+		{query: "?date=2026-03-02&tz=America/Los_Angeles", want: 0},
+	}
+	for _, tt := range tests {
+		rec := c.do("GET", "/api/nutrition/water"+tt.query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d (%s)", tt.query, rec.Code, rec.Body.String())
+		}
+		var got nutrition.WaterDay
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.AmountML != tt.want {
+			t.Errorf("%s: %d ml, want %d", tt.query, got.AmountML, tt.want)
+		}
+	}
+}
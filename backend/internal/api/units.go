@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/domain/identity"
+	"fitness-buddy/internal/units"
+)
+
+// convertUnits keeps handlers metric-only. Imperial fields in JSON request
+// bodies (weight_lb, distance_miles, ...) are converted to metric whatever
+// the caller's setting, and JSON responses are rendered in the caller's unit
+// system: ?units= if given, otherwise their profile's. A coach reading an
+// athlete's data sees it in the coach's units.
+func convertUnits(users *identity.Repository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			system := units.Metric
+			if raw := r.URL.Query().Get("units"); raw != "" {
+				s, err := units.Parse(raw)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				system = s
+			} else if userID, ok := auth.ActorID(r.Context()); ok {
+				s, err := users.GetUnits(r.Context(), userID)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				system = s
+			}
+
+			if err := normalizeBody(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if system == units.Metric {
+				next.ServeHTTP(w, r)
+				return
+			}
+			uw := &unitsWriter{ResponseWriter: w, system: system}
+			next.ServeHTTP(uw, r)
+			uw.flush()
+		})
+	}
+}
+
+// normalizeBody converts the request body to metric. Bodies that aren't
+// JSON are left for the handler to reject.
+func normalizeBody(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if converted, err := units.Normalize(body); err == nil {
+			body = converted
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// unitsWriter holds back JSON responses so they can be converted once the
+// handler is done. Anything else, errors from http.Error included, goes
+// straight through.
+type unitsWriter struct {
+	http.ResponseWriter
+	system  units.System
+	status  int
+	buf     *bytes.Buffer
+	decided bool
+}
+
+func (uw *unitsWriter) decide() {
+	if uw.decided {
+		return
+	}
+	uw.decided = true
+	if strings.HasPrefix(uw.Header().Get("Content-Type"), "application/json") {
+		uw.buf = &bytes.Buffer{}
+	}
+}
+
+func (uw *unitsWriter) WriteHeader(status int) {
+	uw.decide()
+	if uw.buf != nil {
+		uw.status = status
+		return
+	}
+	uw.ResponseWriter.WriteHeader(status)
+}
+
+func (uw *unitsWriter) Write(p []byte) (int, error) {
+	uw.decide()
+	if uw.buf != nil {
+		return uw.buf.Write(p)
+	}
+	return uw.ResponseWriter.Write(p)
+}
+
+func (uw *unitsWriter) flush() {
+	if uw.buf == nil {
+		return
+	}
+	status := uw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	body := uw.buf.Bytes()
+	if status < 300 && len(body) > 0 {
+		if converted, err := units.Render(body, uw.system); err == nil {
+			body = converted
+		}
+	}
+	uw.Header().Del("Content-Length")
+	uw.ResponseWriter.WriteHeader(status)
+	uw.ResponseWriter.Write(body)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/domain/identity"
+	"fitness-buddy/internal/testdb"
+)
+
+// echoSet stands in for a handler: it records the body it was given and
+// answers with a stored set.
+type echoSet struct{ body string }
+
+func (e *echoSet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	e.body = string(b)
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("fail") != "" {
+		http.Error(w, `{"weight_kg": 1}`, http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte(`{"weight_kg": 100, "reps": 5}`))
+}
+
+func TestConvertUnits(t *testing.T) {
+	db := testdb.SQLite(t)
+	metric := insertID(t, db, `INSERT INTO users (name, email) VALUES ('Metric', 'metric@example.com')`)
+	imperial := insertID(t, db, `INSERT INTO users (name, email, units) VALUES ('Imperial', 'imperial@example.com', 'imperial')`)
+
+	tests := []struct {
+		name     string
+		userID   int
+		query    string
+		body     string
+		status   int
+		wantIn   map[string]any
+		wantResp string
+	}{
+		{name: "profile metric", userID: metric, status: 200, wantResp: `{"weight_kg": 100, "reps": 5}`},
+		{name: "profile imperial", userID: imperial, status: 200, wantResp: `{"weight_lb": 220.46, "reps": 5}`},
+		{name: "override to imperial", userID: metric, query: "?units=imperial", status: 200, wantResp: `{"weight_lb": 220.46, "reps": 5}`},
+		{name: "override to metric", userID: imperial, query: "?units=METRIC", status: 200, wantResp: `{"weight_kg": 100, "reps": 5}`},
+		{name: "override when signed out", query: "?units=imperial", status: 200, wantResp: `{"weight_lb": 220.46, "reps": 5}`},
+		{name: "invalid override", userID: metric, query: "?units=stone", status: 400},
+		{name: "errors are not rendered", userID: imperial, query: "?fail=1", status: 400, wantResp: `{"weight_kg": 1}`},
+
+		// Input is normalized whatever the caller's units.
+		{name: "imperial input, metric user", userID: metric, body: `{"weight_lb": 220.46, "reps": 5}`, status: 200,
+			wantIn: map[string]any{"weight_kg": 100.0, "reps": 5.0}, wantResp: `{"weight_kg": 100, "reps": 5}`},
+		{name: "metric input, imperial user", userID: imperial, body: `{"weight_kg": 100.25}`, status: 200,
+			wantIn: map[string]any{"weight_kg": 100.25}, wantResp: `{"weight_lb": 220.46, "reps": 5}`},
+		{name: "nested input", userID: imperial, body: `{"sets": [{"weight_lb": 45}], "height_inches": 70}`, status: 200,
+			wantIn: map[string]any{"sets": []any{map[string]any{"weight_kg": 20.41}}, "height_cm": 177.8}, wantResp: `{"weight_lb": 220.46, "reps": 5}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &echoSet{}
+			handler := convertUnits(identity.NewRepository(db))(next)
+			req := httptest.NewRequest("POST", "/api/sets"+tt.query, strings.NewReader(tt.body))
+			if tt.userID != 0 {
+				req = req.WithContext(auth.WithUserID(req.Context(), tt.userID))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d (%s)", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.wantIn != nil {
+				var got map[string]any
+				if err := json.Unmarshal([]byte(next.body), &got); err != nil {
+					t.Fatalf("handler got %q: %v", next.body, err)
+				}
+				if !jsonEqual(got, tt.wantIn) {
+					t.Errorf("handler got %s, want %v", next.body, tt.wantIn)
+				}
+			}
+			if tt.wantResp != "" {
+				var got, want any
+				json.Unmarshal(rec.Body.Bytes(), &got)
+				json.Unmarshal([]byte(tt.wantResp), &want)
+				if !jsonEqual(got, want) {
+					t.Errorf("response %s, want %s", rec.Body.String(), tt.wantResp)
+				}
+			}
+		})
+	}
+}
+
+func jsonEqual(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"net/http"
 )
 
 type contextKey string
@@ -10,13 +11,61 @@ const (
 	UserIDKey contextKey = "userID"
 )
 
-func GetUserID(ctx context.Context) int {
-	if id, ok := ctx.Value(UserIDKey).(int); ok {
-		return id
+// GetUserID returns the authenticated user for the request context. There is
+// deliberately no fallback user: ok is false whenever the middleware did not
+// attach an identity.
+func GetUserID(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(UserIDKey).(int)
+	if !ok || id <= 0 {
+		return 0, false
 	}
-	return 1 // Fallback to guest user
+	return id, true
+}
+
+// RequireUserID is GetUserID for handlers: it answers 401 itself when the
+// request is unauthenticated, so callers only need to return.
+func RequireUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, ok := GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}
+	return id, ok
 }
 
 func WithUserID(ctx context.Context, userID int) context.Context {
 	return context.WithValue(ctx, UserIDKey, userID)
 }
+
+const sessionIDKey contextKey = "sessionID"
+
+// WithSessionID records which login session the request was authenticated
+// with, so handlers can tell the caller's own session apart from others.
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDKey, sessionID)
+}
+
+func GetSessionID(ctx context.Context) string {
+	id, _ := ctx.Value(sessionIDKey).(string)
+	return id
+}
+
+const actorIDKey contextKey = "actorID"
+
+// WithDelegatedUser points the request at another user's data on behalf of
+// the authenticated caller, as when a coach views an athlete's workouts.
+// GetUserID then returns userID, and ActorID still returns the caller.
+func WithDelegatedUser(ctx context.Context, userID int) context.Context {
+	if actor, ok := GetUserID(ctx); ok {
+		ctx = context.WithValue(ctx, actorIDKey, actor)
+	}
+	return WithUserID(ctx, userID)
+}
+
+// ActorID returns the user actually making the request, which differs from
+// GetUserID only for delegated requests.
+func ActorID(ctx context.Context) (int, bool) {
+	if id, ok := ctx.Value(actorIDKey).(int); ok && id > 0 {
+		return id, true
+	}
+	return GetUserID(ctx)
+}
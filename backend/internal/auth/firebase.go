@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// FirebaseJWKSURL publishes the keys that sign Firebase Auth ID tokens.
+const FirebaseJWKSURL = "https://www.googleapis.com/service_accounts/v1/jwk/securetoken@system.gserviceaccount.com"
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySource resolves the RSA public key for a token's "kid" header.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// StaticKeySource is a fixed set of keys, for tests and air-gapped setups.
+type StaticKeySource map[string]*rsa.PublicKey
+
+func (s StaticKeySource) Key(_ context.Context, kid string) (*rsa.PublicKey, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// JWKSKeySource fetches a JSON Web Key Set over HTTP and caches it for as
+// long as the response's Cache-Control max-age allows. If a refresh fails the
+// previous keys stay in use, so a brief outage upstream doesn't stop logins.
+// Concurrent lookups share one fetch, made without holding the cache lock.
+type JWKSKeySource struct {
+	URL    string
+	Client *http.Client
+	// MinRefresh rate-limits refetches triggered by an unknown kid.
+	MinRefresh time.Duration
+
+	fetch     singleflight.Group
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expires   time.Time
+	lastFetch time.Time
+	fetching  bool
+}
+
+func NewJWKSKeySource(url string) *JWKSKeySource {
+	return &JWKSKeySource{
+		URL:        url,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+		MinRefresh: time.Minute,
+	}
+}
+
+func (s *JWKSKeySource) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	key, known := s.keys[kid]
+	// Refetch when the cache has expired, or when a new kid shows up (keys
+	// rotate before the old max-age runs out), but not more than once per
+	// MinRefresh. A fetch already under way is waited for.
+	due := (time.Now().After(s.expires) || !known) && (s.fetching || time.Since(s.lastFetch) >= s.MinRefresh)
+	s.mu.Unlock()
+
+	if due {
+		// The fetch is shared, so one caller giving up mustn't cancel it for
+		// the rest; the client's timeout still bounds it.
+		_, err, _ := s.fetch.Do("", func() (any, error) {
+			return nil, s.refresh(context.WithoutCancel(ctx))
+		})
+		s.mu.Lock()
+		key, known = s.keys[kid]
+		empty := len(s.keys) == 0
+		s.mu.Unlock()
+		if err != nil && empty {
+			return nil, fmt.Errorf("fetch signing keys: %w", err)
+		}
+	}
+	if !known {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refresh refetches the key set unless another fetch started less than
+// MinRefresh ago. Only the cache update holds s.mu.
+func (s *JWKSKeySource) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	if time.Since(s.lastFetch) < s.MinRefresh {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastFetch = time.Now()
+	s.fetching = true
+	s.mu.Unlock()
+
+	keys, ttl, err := s.fetchKeys(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetching = false
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	s.expires = time.Now().Add(ttl)
+	return nil
+}
+
+func (s *JWKSKeySource) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, 0, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || k.Kid == "" {
+			continue
+		}
+		key, err := parseRSAKey(k.N, k.E)
+		if err != nil {
+			return nil, 0, fmt.Errorf("key %s: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, 0, errors.New("no RSA keys in key set")
+	}
+	return keys, maxAge(resp.Header.Get("Cache-Control"), time.Hour), nil
+}
+
+func parseRSAKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("exponent too large")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+func maxAge(cacheControl string, fallback time.Duration) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok || !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return fallback
+}
+
+type FirebaseClaims struct {
+	PhoneNumber string `json:"phone_number"`
+	AuthTime    int64  `json:"auth_time"`
+	jwt.RegisteredClaims
+}
+
+// FirebaseVerifier checks Firebase Auth ID tokens locally: RS256 signature
+// against Keys, audience and issuer for ProjectID, and expiry.
+type FirebaseVerifier struct {
+	ProjectID string
+	Keys      KeySource
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+func NewFirebaseVerifier(projectID string, keys KeySource) *FirebaseVerifier {
+	return &FirebaseVerifier{ProjectID: projectID, Keys: keys, Now: time.Now}
+}
+
+func (v *FirebaseVerifier) Verify(ctx context.Context, idToken string) (*FirebaseClaims, error) {
+	if v.ProjectID == "" {
+		return nil, errors.New("firebase project id not configured")
+	}
+
+	claims := &FirebaseClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("missing kid header")
+		}
+		return v.Keys.Key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithAudience(v.ProjectID),
+		jwt.WithIssuer("https://securetoken.google.com/"+v.ProjectID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+		jwt.WithTimeFunc(v.Now),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	if claims.AuthTime == 0 || time.Unix(claims.AuthTime, 0).After(v.Now().Add(time.Minute)) {
+		return nil, errors.New("token has an invalid auth_time")
+	}
+	return claims, nil
+}
+
+// NormalizePhone strips the formatting characters people type into phone
+// numbers so that "+91 98765-43210" and "+919876543210" compare equal.
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testProject = "fitness-buddy-test"
+
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":          "https://securetoken.google.com/" + testProject,
+		"aud":          testProject,
+		"sub":          "firebase-uid-1",
+		"iat":          testNow.Add(-5 * time.Minute).Unix(),
+		"exp":          testNow.Add(55 * time.Minute).Unix(),
+		"auth_time":    testNow.Add(-5 * time.Minute).Unix(),
+		"phone_number": "+15555550100",
+	}
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestFirebaseVerifierVerify(t *testing.T) {
+	key := newTestKey(t)
+	other := newTestKey(t)
+	v := NewFirebaseVerifier(testProject, StaticKeySource{"k1": &key.PublicKey})
+	v.Now = func() time.Time { return testNow }
+
+	tests := []struct {
+		name    string
+		kid     string
+		signer  *rsa.PrivateKey
+		modify  func(jwt.MapClaims)
+		wantErr bool
+	}{
+		{name: "valid", kid: "k1", signer: key},
+		{name: "wrong audience", kid: "k1", signer: key, modify: func(c jwt.MapClaims) { c["aud"] = "another-project" }, wantErr: true},
+		{name: "wrong issuer", kid: "k1", signer: key, modify: func(c jwt.MapClaims) { c["iss"] = "https://securetoken.google.com/another-project" }, wantErr: true},
+		{name: "expired", kid: "k1", signer: key, modify: func(c jwt.MapClaims) { c["exp"] = testNow.Add(-2 * time.Minute).Unix() }, wantErr: true},
+		{name: "expired within leeway", kid: "k1", signer: key, modify: func(c jwt.MapClaims) { c["exp"] = testNow.Add(-30 * time.Second).Unix() }},
+		{name: "no expiry", kid: "k1", signer: key, modify: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: true},
+		{name: "issued in the future", kid: "k1", signer: key, modify: func(c jwt.MapClaims) { c["iat"] = testNow.Add(5 * time.Minute).Unix() }, wantErr: true},
+		{name: "no auth_time", kid: "k1", signer: key, modify: func(c jwt.MapClaims) { delete(c, "auth_time") }, wantErr: true},
+		{name: "auth_time in the future", kid: "k1", signer: key, modify: func(c jwt.MapClaims) { c["auth_time"] = testNow.Add(5 * time.Minute).Unix() }, wantErr: true},
+		{name: "no subject", kid: "k1", signer: key, modify: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: true},
+		{name: "unknown kid", kid: "k2", signer: key, wantErr: true},
+		{name: "signed by another key", kid: "k1", signer: other, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			got, err := v.Verify(context.Background(), signToken(t, tt.signer, tt.kid, claims))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Verify succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if got.Subject != "firebase-uid-1" || got.PhoneNumber != "+15555550100" {
+				t.Errorf("claims = %+v", got)
+			}
+		})
+	}
+}
+
+func TestFirebaseVerifierUnknownKid(t *testing.T) {
+	key := newTestKey(t)
+	v := NewFirebaseVerifier(testProject, StaticKeySource{"k1": &key.PublicKey})
+	v.Now = func() time.Time { return testNow }
+
+	_, err := v.Verify(context.Background(), signToken(t, key, "k2", validClaims()))
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestFirebaseVerifierRejectsOtherAlgorithms(t *testing.T) {
+	key := newTestKey(t)
+	v := NewFirebaseVerifier(testProject, StaticKeySource{"k1": &key.PublicKey})
+	v.Now = func() time.Time { return testNow }
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(context.Background(), signed); err == nil {
+		t.Fatal("Verify accepted an HS256 token")
+	}
+}
+
+// jwksServer serves the public half of keys as a JSON Web Key Set and counts
+// the requests it gets.
+func jwksServer(t *testing.T, keys map[string]*rsa.PrivateKey, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(delay)
+		type jwk struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		}
+		set := struct {
+			Keys []jwk `json:"keys"`
+		}{}
+		for kid, key := range keys {
+			set.Keys = append(set.Keys, jwk{
+				Kid: kid,
+				Kty: "RSA",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestJWKSKeySource(t *testing.T) {
+	key := newTestKey(t)
+	srv, hits := jwksServer(t, map[string]*rsa.PrivateKey{"k1": key}, 0)
+	src := NewJWKSKeySource(srv.URL)
+
+	got, err := src.Key(context.Background(), "k1")
+	if err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+	if !got.Equal(&key.PublicKey) {
+		t.Error("Key returned a different key")
+	}
+	if _, err := src.Key(context.Background(), "k1"); err != nil {
+		t.Fatalf("cached Key: %v", err)
+	}
+	// An unknown kid doesn't refetch within MinRefresh of the last fetch.
+	if _, err := src.Key(context.Background(), "k2"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+}
+
+func TestJWKSKeySourceSharesFetch(t *testing.T) {
+	key := newTestKey(t)
+	srv, hits := jwksServer(t, map[string]*rsa.PrivateKey{"k1": key}, 100*time.Millisecond)
+	src := NewJWKSKeySource(srv.URL)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := src.Key(context.Background(), "k1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Key: %v", err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+}
+
+func TestJWKSKeySourceKeepsKeysOnFailure(t *testing.T) {
+	key := newTestKey(t)
+	srv, _ := jwksServer(t, map[string]*rsa.PrivateKey{"k1": key}, 0)
+	src := NewJWKSKeySource(srv.URL)
+	src.MinRefresh = 0
+
+	if _, err := src.Key(context.Background(), "k1"); err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+	srv.Close()
+	src.mu.Lock()
+	src.expires = time.Time{}
+	src.mu.Unlock()
+	if _, err := src.Key(context.Background(), "k1"); err != nil {
+		t.Fatalf("Key after the server went away: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Scope groups match the route groups in api.NewRouter. A scope is written
+// "<group>:<access>", where access is "read" or "write" (write implies read)
+// and group "*" stands for every group: "*:read" is a read-only token,
+// "nutrition:write" can only touch meals and water.
+var ScopeGroups = []string{"identity", "resistance", "running", "nutrition", "body", "analytics"}
+
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+const scopesKey contextKey = "scopes"
+
+// ParseScopes validates a list of scopes and returns them normalized.
+func ParseScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	out := make([]string, 0, len(scopes))
+	for _, raw := range scopes {
+		scope := strings.ToLower(strings.TrimSpace(raw))
+		group, access, ok := strings.Cut(scope, ":")
+		if !ok || (access != AccessRead && access != AccessWrite) || (group != "*" && !knownGroup(group)) {
+			return nil, fmt.Errorf("invalid scope %q", raw)
+		}
+		out = append(out, scope)
+	}
+	return out, nil
+}
+
+func knownGroup(group string) bool {
+	for _, g := range ScopeGroups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// WithScopes marks the request as authenticated by an API token limited to
+// the given scopes. Cookie sessions carry no scopes and may do anything.
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+// TokenScopes returns the scopes of the API token the request was made with,
+// and false for cookie sessions.
+func TokenScopes(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesKey).([]string)
+	return scopes, ok
+}
+
+// Allowed reports whether the request may use access on group.
+func Allowed(ctx context.Context, group, access string) bool {
+	scopes, isToken := TokenScopes(ctx)
+	if !isToken {
+		return true
+	}
+	for _, scope := range scopes {
+		g, a, _ := strings.Cut(scope, ":")
+		if (g == "*" || g == group) && (a == access || a == AccessWrite) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+// Package calendar works out which day a moment belongs to for a user.
+//
+// Timestamps are stored as instants; a "day" only exists in a timezone. Each
+// user has an IANA timezone on their profile, and every per-day figure is
+// bucketed by midnight-to-midnight in that zone, so a late dinner in
+// Asia/Kolkata counts towards the day it was eaten on wherever the server
+// runs. Days are not always 24 hours long: across a DST change they are 23 or
+// 25, which is why ranges are built with time.Date rather than by adding
+// hours.
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	// The production image has no zoneinfo of its own.
+	_ "time/tzdata"
+)
+
+// DateLayout is how days are written in URLs and responses.
+const DateLayout = "2006-01-02"
+
+// DefaultTimezone is used for users who have never set one.
+const DefaultTimezone = "UTC"
+
+// LoadLocation validates an IANA timezone name such as "Europe/Berlin".
+// "Local" is refused, since it would mean the server's zone.
+func LoadLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("invalid timezone %q, expected an IANA name such as Europe/Berlin", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q, expected an IANA name such as Europe/Berlin", name)
+	}
+	return loc, nil
+}
+
+// DayStart returns local midnight at the start of the day t falls on in loc.
+func DayStart(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// NextDay returns midnight of the following day. start must be a local
+// midnight, as from DayStart.
+func NextDay(start time.Time) time.Time {
+	return start.AddDate(0, 0, 1)
+}
+
+// ParseDate reads a DateLayout day as local midnight in loc.
+func ParseDate(s string, loc *time.Location) (time.Time, error) {
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc), nil
+}
+
+// Date names the day t falls on in loc.
+func Date(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(DateLayout)
+}
+
+type contextKey struct{}
+
+// WithLocation records the timezone days are counted in for the request.
+func WithLocation(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, contextKey{}, loc)
+}
+
+// Location returns the request's timezone, or UTC if none was set.
+func Location(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(contextKey{}).(*time.Location); ok && loc != nil {
+		return loc
+	}
+	return time.UTC
+}
@@ -0,0 +1,125 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func mustParseTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDayStartAndNextDay(t *testing.T) {
+	tests := []struct {
+		name      string
+		zone      string
+		at        string
+		wantStart string
+		wantNext  string
+		wantHours float64
+	}{
+		{name: "ordinary day", zone: "Europe/Berlin", at: "2026-03-10T12:00:00Z", wantStart: "2026-03-10T00:00:00+01:00", wantNext: "2026-03-11T00:00:00+01:00", wantHours: 24},
+		{name: "spring forward", zone: "Europe/Berlin", at: "2026-03-29T12:00:00Z", wantStart: "2026-03-29T00:00:00+01:00", wantNext: "2026-03-30T00:00:00+02:00", wantHours: 23},
+		{name: "fall back", zone: "Europe/Berlin", at: "2026-10-25T12:00:00Z", wantStart: "2026-10-25T00:00:00+02:00", wantNext: "2026-10-26T00:00:00+01:00", wantHours: 25},
+		{name: "spring forward, before the change", zone: "America/Los_Angeles", at: "2026-03-08T09:30:00Z", wantStart: "2026-03-08T00:00:00-08:00", wantNext: "2026-03-09T00:00:00-07:00", wantHours: 23},
+		{name: "fall back, in the repeated hour", zone: "America/Los_Angeles", at: "2026-11-01T09:30:00Z", wantStart: "2026-11-01T00:00:00-07:00", wantNext: "2026-11-02T00:00:00-08:00", wantHours: 25},
+		{name: "already tomorrow locally", zone: "Asia/Kolkata", at: "2026-03-01T20:00:00Z", wantStart: "2026-03-02T00:00:00+05:30", wantNext: "2026-03-03T00:00:00+05:30", wantHours: 24},
+		{name: "still yesterday locally", zone: "America/Los_Angeles", at: "2026-03-02T03:00:00Z", wantStart: "2026-03-01T00:00:00-08:00", wantNext: "2026-03-02T00:00:00-08:00", wantHours: 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := DayStart(mustParseTime(t, tt.at), mustLoad(t, tt.zone))
+			next := NextDay(start)
+			if got := start.Format(time.RFC3339); got != tt.wantStart {
+				t.Errorf("DayStart = %s, want %s", got, tt.wantStart)
+			}
+			if got := next.Format(time.RFC3339); got != tt.wantNext {
+				t.Errorf("NextDay = %s, want %s", got, tt.wantNext)
+			}
+			if got := next.Sub(start).Hours(); got != tt.wantHours {
+				t.Errorf("day is %v hours long, want %v", got, tt.wantHours)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		date    string
+		zone    string
+		want    string
+		wantErr bool
+	}{
+		{date: "2026-03-29", zone: "Europe/Berlin", want: "2026-03-29T00:00:00+01:00"},
+		{date: "2026-03-30", zone: "Europe/Berlin", want: "2026-03-30T00:00:00+02:00"},
+		{date: "2026-10-25", zone: "Europe/Berlin", want: "2026-10-25T00:00:00+02:00"},
+		{date: "2026-11-01", zone: "America/Los_Angeles", want: "2026-11-01T00:00:00-07:00"},
+		{date: "2026-03-02", zone: "Asia/Kolkata", want: "2026-03-02T00:00:00+05:30"},
+		{date: "2026-02-30", zone: "UTC", wantErr: true},
+		{date: "29/03/2026", zone: "UTC", wantErr: true},
+		{date: "2026-03-29T00:00:00Z", zone: "UTC", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone+" "+tt.date, func(t *testing.T) {
+			loc := mustLoad(t, tt.zone)
+			got, err := ParseDate(tt.date, loc)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseDate = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDate: %v", err)
+			}
+			if s := got.Format(time.RFC3339); s != tt.want {
+				t.Errorf("ParseDate = %s, want %s", s, tt.want)
+			}
+			// Parsing a day and naming it again round-trips.
+			if d := Date(got, loc); d != tt.date {
+				t.Errorf("Date = %s, want %s", d, tt.date)
+			}
+		})
+	}
+}
+
+// TestDateWhileTravelling shows the same moments falling on different days
+// for a user who logs them at home and then after flying across zones.
+func TestDateWhileTravelling(t *testing.T) {
+	la := mustLoad(t, "America/Los_Angeles")
+	kolkata := mustLoad(t, "Asia/Kolkata")
+	dinner := mustParseTime(t, "2026-03-01T20:00:00Z") // 12:00 in LA, 01:30 next day in Kolkata
+	if got := Date(dinner, la); got != "2026-03-01" {
+		t.Errorf("Date in LA = %s, want 2026-03-01", got)
+	}
+	if got := Date(dinner, kolkata); got != "2026-03-02" {
+		t.Errorf("Date in Kolkata = %s, want 2026-03-02", got)
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	for _, name := range []string{"Europe/Berlin", " Asia/Kolkata ", "UTC"} {
+		if _, err := LoadLocation(name); err != nil {
+			t.Errorf("LoadLocation(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "Local", "Mars/Olympus_Mons", "+05:30"} {
+		if _, err := LoadLocation(name); err == nil {
+			t.Errorf("LoadLocation(%q) succeeded, want an error", name)
+		}
+	}
+}
@@ -2,29 +2,48 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+const (
+	DriverPostgres = "pgx"
+	DriverSQLite   = "sqlite3"
+)
+
+// ErrNotFound is returned by repositories when a row does not exist or does
+// not belong to the calling user. Handlers map it to 404 so that another
+// user's IDs are indistinguishable from missing ones.
+var ErrNotFound = errors.New("not found")
+
+// minSQLiteVersion is the oldest SQLite with both upsert and RETURNING.
+const minSQLiteVersion = "3.35.0"
+
 type DB struct {
-	Pool *sql.DB
+	Pool    *sql.DB
+	Driver  string
+	Dialect Dialect
 }
 
 func New(connString string) (*DB, error) {
     var driver, dsn string
 
     if strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://") {
-        driver = "pgx"
+        driver = DriverPostgres
         dsn = connString
     } else {
-        driver = "sqlite3"
+        driver = DriverSQLite
         if connString == "" {
             connString = "fitness_buddy.db"
         }
-        dsn = connString + "?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on&_parseTime=true&_loc=Local"
+        // Times are read back in UTC; which day they belong to depends on
+        // the user's timezone, never the server's (see package calendar).
+        dsn = connString + "?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on&_parseTime=true&_loc=UTC"
     }
 
 	db, err := sql.Open(driver, dsn)
@@ -32,7 +51,7 @@ func New(connString string) (*DB, error) {
 		return nil, fmt.Errorf("unable to open database: %w", err)
 	}
 
-    if driver == "sqlite3" {
+    if driver == DriverSQLite {
         db.SetMaxOpenConns(1)
     }
 
@@ -40,9 +59,58 @@ func New(connString string) (*DB, error) {
 		return nil, fmt.Errorf("unable to ping database: %w", err)
 	}
 
-	return &DB{Pool: db}, nil
+	var dialect Dialect = postgresDialect{}
+	if driver == DriverSQLite {
+		var version string
+		if err := db.QueryRow("SELECT sqlite_version()").Scan(&version); err != nil {
+			return nil, fmt.Errorf("unable to read sqlite version: %w", err)
+		}
+		if compareVersions(version, minSQLiteVersion) < 0 {
+			return nil, fmt.Errorf("sqlite %s is too old, need %s or newer", version, minSQLiteVersion)
+		}
+		dialect = sqliteDialect{}
+	}
+
+	return &DB{Pool: db, Driver: driver, Dialect: dialect}, nil
 }
 
 func (db *DB) Close() {
 	db.Pool.Close()
-}
\ No newline at end of file
+}
+
+// RequireAffected turns an UPDATE or DELETE that matched no rows into
+// ErrNotFound.
+func RequireAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// compareVersions compares dotted numeric versions such as "3.35.0".
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
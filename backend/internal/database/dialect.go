@@ -0,0 +1,261 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect hides the SQL differences between the backends we support. Queries
+// and migrations are written once in Postgres style and call into the dialect
+// for the handful of constructs that are spelled differently.
+type Dialect interface {
+	Name() string
+
+	// Rewrite adapts DDL that uses Postgres column types (SERIAL, TIMESTAMPTZ,
+	// JSONB, BYTEA) to the backend. Literals and comments are left untouched.
+	Rewrite(sql string) string
+
+	// InsertIgnore returns the clause that turns an INSERT into a no-op when
+	// it would violate a unique constraint on the given columns. With no
+	// columns any constraint applies.
+	InsertIgnore(conflictColumns ...string) string
+
+	// Upsert returns the clause that updates updateColumns from the proposed
+	// row when conflictColumns already exist.
+	Upsert(conflictColumns []string, updateColumns ...string) string
+
+	// Date truncates a timestamp expression to its calendar date.
+	Date(expr string) string
+
+	// DateTrunc truncates a timestamp expression to the start of the given
+	// unit: hour, day, week (ISO, Monday), month or year.
+	DateTrunc(unit, expr string) string
+
+	// AddInterval shifts a timestamp expression by amount units, where amount
+	// is itself a SQL expression such as a literal or a placeholder.
+	AddInterval(expr, amount, unit string) string
+
+	// SecondsBetween returns end - start in seconds as a floating point value.
+	SecondsBetween(start, end string) string
+
+	// SupportsReturning reports whether INSERT ... RETURNING is available.
+	SupportsReturning() bool
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+func (postgresDialect) Rewrite(sql string) string { return sql }
+
+func (postgresDialect) InsertIgnore(conflictColumns ...string) string {
+	return onConflict(conflictColumns) + " DO NOTHING"
+}
+
+func (postgresDialect) Upsert(conflictColumns []string, updateColumns ...string) string {
+	return upsert(conflictColumns, updateColumns)
+}
+
+func (postgresDialect) Date(expr string) string {
+	return fmt.Sprintf("(%s)::date", expr)
+}
+
+func (postgresDialect) DateTrunc(unit, expr string) string {
+	return fmt.Sprintf("date_trunc('%s', %s)", unit, expr)
+}
+
+func (postgresDialect) AddInterval(expr, amount, unit string) string {
+	return fmt.Sprintf("(%s + (%s) * interval '1 %s')", expr, amount, unit)
+}
+
+func (postgresDialect) SecondsBetween(start, end string) string {
+	return fmt.Sprintf("EXTRACT(EPOCH FROM (%s - %s))", end, start)
+}
+
+func (postgresDialect) SupportsReturning() bool { return true }
+
+// sqliteDialect targets SQLite 3.35+, the first release with both upsert and
+// RETURNING. New refuses to open older libraries.
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+var sqliteTypes = map[string]string{
+	"TIMESTAMPTZ": "DATETIME",
+	"JSONB":       "TEXT",
+	"BYTEA":       "BLOB",
+}
+
+func (sqliteDialect) Rewrite(sql string) string {
+	tokens := tokenize(sql)
+	var b strings.Builder
+	for i, tok := range tokens {
+		if !tok.word {
+			b.WriteString(tok.text)
+			continue
+		}
+		upper := strings.ToUpper(tok.text)
+		switch {
+		case upper == "SERIAL" || upper == "BIGSERIAL":
+			// Only INTEGER PRIMARY KEY aliases the rowid, so the keyword
+			// has to be spelled exactly that way to auto-increment.
+			b.WriteString("INTEGER")
+			if next := nextWords(tokens, i, 2); len(next) == 2 && strings.EqualFold(next[0], "PRIMARY") && strings.EqualFold(next[1], "KEY") {
+				markAutoincrement(tokens, i)
+			}
+		case upper == "KEY" && tok.autoincrement:
+			b.WriteString(tok.text + " AUTOINCREMENT")
+		case sqliteTypes[upper] != "":
+			b.WriteString(sqliteTypes[upper])
+		default:
+			b.WriteString(tok.text)
+		}
+	}
+	return b.String()
+}
+
+func (sqliteDialect) InsertIgnore(conflictColumns ...string) string {
+	return onConflict(conflictColumns) + " DO NOTHING"
+}
+
+func (sqliteDialect) Upsert(conflictColumns []string, updateColumns ...string) string {
+	return upsert(conflictColumns, updateColumns)
+}
+
+func (sqliteDialect) Date(expr string) string {
+	return fmt.Sprintf("date(%s)", expr)
+}
+
+func (sqliteDialect) DateTrunc(unit, expr string) string {
+	switch unit {
+	case "hour":
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:00:00', %s)", expr)
+	case "week":
+		// 'weekday 0' moves forward to Sunday (or stays on it); six days
+		// back from there is the Monday that starts the ISO week.
+		return fmt.Sprintf("datetime(date(%s, 'weekday 0', '-6 days'))", expr)
+	case "month", "year":
+		return fmt.Sprintf("datetime(%s, 'start of %s')", expr, unit)
+	default:
+		return fmt.Sprintf("datetime(date(%s))", expr)
+	}
+}
+
+func (sqliteDialect) AddInterval(expr, amount, unit string) string {
+	return fmt.Sprintf("datetime(%s, printf('%%+d %ss', %s))", expr, unit, amount)
+}
+
+func (sqliteDialect) SecondsBetween(start, end string) string {
+	return fmt.Sprintf("((julianday(%s) - julianday(%s)) * 86400.0)", end, start)
+}
+
+func (sqliteDialect) SupportsReturning() bool { return true }
+
+func onConflict(columns []string) string {
+	if len(columns) == 0 {
+		return "ON CONFLICT"
+	}
+	return "ON CONFLICT (" + strings.Join(columns, ", ") + ")"
+}
+
+func upsert(conflictColumns, updateColumns []string) string {
+	sets := make([]string, len(updateColumns))
+	for i, col := range updateColumns {
+		sets[i] = fmt.Sprintf("%s = excluded.%s", col, col)
+	}
+	return onConflict(conflictColumns) + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+type token struct {
+	text          string
+	word          bool
+	autoincrement bool
+}
+
+// tokenize splits SQL into bare words and everything else. String literals,
+// quoted identifiers and comments are kept as opaque non-word tokens so that
+// rewrites never reach inside them.
+func tokenize(sql string) []token {
+	tokens := []token{}
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			j := i + 1
+			for j < len(sql) {
+				if sql[j] == c {
+					if j+1 < len(sql) && sql[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			j = min(j+1, len(sql))
+			tokens = append(tokens, token{text: sql[i:j]})
+			i = j
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			j := strings.IndexByte(sql[i:], '\n')
+			if j < 0 {
+				j = len(sql) - i
+			}
+			tokens = append(tokens, token{text: sql[i : i+j]})
+			i += j
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			j := strings.Index(sql[i+2:], "*/")
+			end := len(sql)
+			if j >= 0 {
+				end = i + 2 + j + 2
+			}
+			tokens = append(tokens, token{text: sql[i:end]})
+			i = end
+		case isWordByte(c):
+			j := i
+			for j < len(sql) && isWordByte(sql[j]) {
+				j++
+			}
+			tokens = append(tokens, token{text: sql[i:j], word: true})
+			i = j
+		default:
+			tokens = append(tokens, token{text: sql[i : i+1]})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// nextWords returns up to n words following position i, giving up at the
+// first token that is neither a word nor whitespace.
+func nextWords(tokens []token, i, n int) []string {
+	words := []string{}
+	for j := i + 1; j < len(tokens) && len(words) < n; j++ {
+		if tokens[j].word {
+			words = append(words, tokens[j].text)
+			continue
+		}
+		if strings.TrimSpace(tokens[j].text) != "" {
+			break
+		}
+	}
+	return words
+}
+
+func markAutoincrement(tokens []token, i int) {
+	seen := 0
+	for j := i + 1; j < len(tokens); j++ {
+		if !tokens[j].word {
+			continue
+		}
+		seen++
+		if seen == 2 {
+			tokens[j].autoincrement = true
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/testdb"
+)
+
+func forEachBackend(t *testing.T, fn func(t *testing.T, db *database.DB)) {
+	t.Run("sqlite", func(t *testing.T) { fn(t, testdb.SQLite(t)) })
+	t.Run("postgres", func(t *testing.T) {
+		db := testdb.Postgres(t)
+		// date_trunc works in the session's zone; pin it on one connection.
+		db.Pool.SetMaxOpenConns(1)
+		if _, err := db.Pool.Exec(`SET TIME ZONE 'UTC'`); err != nil {
+			t.Fatal(err)
+		}
+		fn(t, db)
+	})
+}
+
+// scanTime reads a timestamp the way either backend hands it back: a
+// time.Time from Postgres, text from SQLite's date functions.
+func scanTime(t *testing.T, db *database.DB, query string, args ...any) time.Time {
+	t.Helper()
+	var v any
+	if err := db.Pool.QueryRow(query, args...).Scan(&v); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	var s string
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC()
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		t.Fatalf("%s: unexpected %T", query, v)
+	}
+	for _, layout := range []string{time.DateTime, time.DateOnly} {
+		if ts, err := time.Parse(layout, s); err == nil {
+			return ts
+		}
+	}
+	t.Fatalf("%s: unexpected timestamp %q", query, s)
+	return time.Time{}
+}
+
+func TestDialectDates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *database.DB) {
+		d := db.Dialect
+		if _, err := db.Pool.Exec(d.Rewrite(`CREATE TABLE dialect_test (id SERIAL PRIMARY KEY, at TIMESTAMPTZ NOT NULL)`)); err != nil {
+			t.Fatal(err)
+		}
+		// A Thursday; its ISO week starts on Monday the 2nd.
+		at := time.Date(2026, 3, 5, 13, 45, 30, 0, time.UTC)
+		if _, err := db.Pool.Exec(`INSERT INTO dialect_test (at) VALUES ($1)`, at); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name string
+			expr string
+			args []any
+			want time.Time
+		}{
+			{"date", d.Date("at"), nil, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+			{"trunc hour", d.DateTrunc("hour", "at"), nil, time.Date(2026, 3, 5, 13, 0, 0, 0, time.UTC)},
+			{"trunc day", d.DateTrunc("day", "at"), nil, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+			{"trunc week", d.DateTrunc("week", "at"), nil, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+			{"trunc month", d.DateTrunc("month", "at"), nil, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+			{"trunc year", d.DateTrunc("year", "at"), nil, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{"add days", d.AddInterval("at", "$1", "day"), []any{2}, at.AddDate(0, 0, 2)},
+			{"subtract hours", d.AddInterval("at", "$1", "hour"), []any{-30}, at.Add(-30 * time.Hour)},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got := scanTime(t, db, `SELECT `+tt.expr+` FROM dialect_test`, tt.args...)
+				if !got.Equal(tt.want) {
+					t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+				}
+			})
+		}
+
+		var seconds float64
+		query := `SELECT ` + d.SecondsBetween("at", d.AddInterval("at", "$1", "second")) + ` FROM dialect_test`
+		if err := db.Pool.QueryRow(query, 90).Scan(&seconds); err != nil {
+			t.Fatal(err)
+		}
+		if seconds < 89.99 || seconds > 90.01 {
+			t.Errorf("seconds between = %v, want 90", seconds)
+		}
+	})
+}
+
+func TestDialectInsertIgnoreAndReturning(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *database.DB) {
+		d := db.Dialect
+		if !d.SupportsReturning() {
+			t.Fatalf("%s: RETURNING should be supported", d.Name())
+		}
+		if _, err := db.Pool.Exec(d.Rewrite(`CREATE TABLE dialect_test (id SERIAL PRIMARY KEY, k TEXT NOT NULL UNIQUE, v INTEGER NOT NULL)`)); err != nil {
+			t.Fatal(err)
+		}
+
+		var id int
+		if err := db.Pool.QueryRow(`INSERT INTO dialect_test (k, v) VALUES ($1, $2) RETURNING id`, "a", 1).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		if id == 0 {
+			t.Error("RETURNING gave no id")
+		}
+
+		res, err := db.Pool.Exec(`INSERT INTO dialect_test (k, v) VALUES ($1, $2) `+d.InsertIgnore("k"), "a", 2)
+		if err != nil {
+			t.Fatalf("insert ignore: %v", err)
+		}
+		if n, _ := res.RowsAffected(); n != 0 {
+			t.Errorf("conflicting insert affected %d rows, want 0", n)
+		}
+
+		if _, err := db.Pool.Exec(`INSERT INTO dialect_test (k, v) VALUES ($1, $2) `+d.Upsert([]string{"k"}, "v"), "a", 3); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+		var v int
+		if err := db.Pool.QueryRow(`SELECT v FROM dialect_test WHERE k = $1`, "a").Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		if v != 3 {
+			t.Errorf("v = %d after upsert, want 3", v)
+		}
+	})
+}
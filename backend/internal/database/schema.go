@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Querier is satisfied by both *sql.DB and *sql.Tx.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// TablesWithColumn lists the tables in the current schema that have a column
+// with the given name, in alphabetical order.
+func (db *DB) TablesWithColumn(ctx context.Context, q Querier, column string) ([]string, error) {
+	query := `SELECT table_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_name = $1
+		ORDER BY table_name`
+	if db.Driver == DriverSQLite {
+		query = `SELECT m.name FROM sqlite_master m JOIN pragma_table_info(m.name) p
+			WHERE m.type = 'table' AND p.name = $1
+			ORDER BY m.name`
+	}
+
+	rows, err := q.QueryContext(ctx, query, column)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
     "fitness-buddy/internal/auth"
+    "fitness-buddy/internal/calendar"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -26,23 +27,27 @@ func (h *Handler) GetDailySummaries(w http.ResponseWriter, r *http.Request) {
     startStr := r.URL.Query().Get("start")
     endStr := r.URL.Query().Get("end")
     
-    // Defaults: last 30 days
-    endDate := time.Now()
+    // Defaults: last 30 days, in the user's timezone
+    loc := calendar.Location(r.Context())
+    endDate := calendar.DayStart(time.Now(), loc)
     startDate := endDate.AddDate(0, 0, -30)
     
     if startStr != "" {
-        if t, err := time.Parse("2006-01-02", startStr); err == nil {
+        if t, err := calendar.ParseDate(startStr, loc); err == nil {
             startDate = t
         }
     }
     if endStr != "" {
-         if t, err := time.Parse("2006-01-02", endStr); err == nil {
+         if t, err := calendar.ParseDate(endStr, loc); err == nil {
             endDate = t
         }
     }
     
-    userID := auth.GetUserID(r.Context())
-    summaries, err := h.repo.GetDailySummaries(r.Context(), userID, startDate, endDate)
+    userID, ok := auth.RequireUserID(w, r)
+    if !ok {
+        return
+    }
+    summaries, err := h.repo.GetDailySummaries(r.Context(), userID, startDate, endDate, loc)
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
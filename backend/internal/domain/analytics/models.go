@@ -6,13 +6,23 @@ type DailySummary struct {
     TotalProtein     float64 `json:"total_protein"`
     TotalCarbs       float64 `json:"total_carbs"`
     TotalFat         float64 `json:"total_fat"`
+    TotalFiber       float64 `json:"total_fiber"`
     RunDistance      float64 `json:"run_distance"`
     WorkoutVolumeKG  float64 `json:"workout_volume_kg"`
     ExerciseCalories int     `json:"exercise_calories"`
     WaterML          int     `json:"water_ml"`
     WeightKG         float64 `json:"weight_kg"`
+
+    exerciseCalories float64
 }
 
+// Rough energy costs used for ExerciseCalories: running at ~70 kg body
+// weight, and moderate resistance training.
+const (
+    runKcalPerKm         = 70 * 1.036
+    liftingKcalPerMinute = 6
+)
+
 type WeeklySummary struct {
     WeekStart       string  `json:"week_start"` // YYYY-MM-DD
     TotalDistance   float64 `json:"total_distance"`
@@ -2,7 +2,9 @@ package analytics
 
 import (
 	"context"
+	"fitness-buddy/internal/calendar"
 	"fitness-buddy/internal/database"
+	"math"
 	"time"
 )
 
@@ -14,91 +16,193 @@ func NewRepository(db *database.DB) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) GetDailySummaries(ctx context.Context, userID int, startDate, endDate time.Time) ([]DailySummary, error) {
+// GetDailySummaries aggregates each domain per calendar day in Go rather than
+// with generate_series/DISTINCT ON, so the same code runs on SQLite and
+// Postgres. Days run midnight to midnight in loc, are inclusive of both
+// startDate and endDate and are returned newest first.
+func (r *Repository) GetDailySummaries(ctx context.Context, userID int, startDate, endDate time.Time, loc *time.Location) ([]DailySummary, error) {
+	first := calendar.DayStart(startDate, loc)
+	last := calendar.DayStart(endDate, loc)
+	if last.Before(first) {
+		return []DailySummary{}, nil
+	}
+
+	byDay := map[string]*DailySummary{}
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		key := d.Format(calendar.DateLayout)
+		byDay[key] = &DailySummary{Date: key}
+	}
+
+	// Stored timestamps may carry any offset, and SQLite compares them as
+	// text, so fetch a day either side and let bucket do the exact cut.
+	from := first.AddDate(0, 0, -1)
+	to := last.AddDate(0, 0, 2)
+	bucket := func(t time.Time) *DailySummary {
+		return byDay[calendar.Date(t, loc)]
+	}
+
+	if err := r.addNutrition(ctx, userID, from, to, bucket); err != nil {
+		return nil, err
+	}
+	if err := r.addRunning(ctx, userID, from, to, bucket); err != nil {
+		return nil, err
+	}
+	if err := r.addLifting(ctx, userID, from, to, bucket); err != nil {
+		return nil, err
+	}
+	if err := r.addWater(ctx, userID, from, to, bucket); err != nil {
+		return nil, err
+	}
+	if err := r.addBody(ctx, userID, from, to, bucket); err != nil {
+		return nil, err
+	}
+
+	summaries := []DailySummary{}
+	for d := last; !d.Before(first); d = d.AddDate(0, 0, -1) {
+		s := byDay[d.Format(calendar.DateLayout)]
+		s.ExerciseCalories = int(math.Round(s.exerciseCalories))
+		summaries = append(summaries, *s)
+	}
+	return summaries, nil
+}
+
+func (r *Repository) addNutrition(ctx context.Context, userID int, from, to time.Time, bucket func(time.Time) *DailySummary) error {
 	query := `
-        WITH dates AS (
-            SELECT generate_series($1::date, $2::date, '1 day'::interval)::date AS day
-        ),
-        nutrition AS (
-            SELECT 
-                m.eaten_at::date AS day, 
-                SUM(fe.calories) AS calories,
-                SUM(fe.protein_g) AS protein,
-                SUM(fe.carbs_g) AS carbs,
-                SUM(fe.fat_g) AS fat
-            FROM meals m
-            JOIN food_entries fe ON m.id = fe.meal_id
-            WHERE m.user_id = $3
-            GROUP BY 1
-        ),
-        running AS (
-            SELECT 
-                start_time::date AS day, 
-                SUM(distance_meters) AS distance,
-                SUM(distance_meters / 1000.0 * 70 * 1.036) AS calories
-            FROM runs
-            WHERE user_id = $4
-            GROUP BY 1
-        ),
-        lifting AS (
-            SELECT 
-                ws.start_time::date AS day, 
-                SUM(s.weight_kg * s.reps) AS volume,
-                SUM(6 * EXTRACT(EPOCH FROM (ws.end_time - ws.start_time)) / 60.0) AS calories
-            FROM workout_sessions ws
-            JOIN workout_sets s ON ws.id = s.session_id
-            WHERE ws.user_id = $5 AND ws.end_time IS NOT NULL
-            GROUP BY 1
-        ),
-        water AS (
-            SELECT 
-                recorded_at::date AS day,
-                SUM(amount_ml) AS total_ml
-            FROM water_logs
-            WHERE user_id = $6
-            GROUP BY 1
-        ),
-        body AS (
-            SELECT DISTINCT ON (recorded_at::date)
-                recorded_at::date AS day,
-                weight_kg
-            FROM body_metrics
-            WHERE user_id = $7
-            ORDER BY recorded_at::date, recorded_at DESC
-        )
-        SELECT 
-            d.day,
-            COALESCE(n.calories, 0),
-            COALESCE(n.protein, 0),
-            COALESCE(n.carbs, 0),
-            COALESCE(n.fat, 0),
-            COALESCE(r.distance, 0),
-            COALESCE(l.volume, 0),
-            COALESCE(r.calories, 0) + COALESCE(l.calories, 0),
-            COALESCE(w.total_ml, 0),
-            COALESCE(b.weight_kg, 0)
-        FROM dates d
-        LEFT JOIN nutrition n ON d.day = n.day
-        LEFT JOIN running r ON d.day = r.day
-        LEFT JOIN lifting l ON d.day = l.day
-        LEFT JOIN water w ON d.day = w.day
-        LEFT JOIN body b ON d.day = b.day
-        ORDER BY d.day DESC
+        SELECT m.eaten_at, SUM(fe.calories), SUM(fe.protein_g), SUM(fe.carbs_g), SUM(fe.fat_g), SUM(fe.fiber_g)
+        FROM meals m
+        JOIN food_entries fe ON m.id = fe.meal_id
+        WHERE m.user_id = $1 AND m.eaten_at >= $2 AND m.eaten_at < $3
+        GROUP BY m.id, m.eaten_at
     `
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eatenAt time.Time
+		var calories int
+		var protein, carbs, fat, fiber float64
+		if err := rows.Scan(&eatenAt, &calories, &protein, &carbs, &fat, &fiber); err != nil {
+			return err
+		}
+		if s := bucket(eatenAt); s != nil {
+			s.TotalCalories += calories
+			s.TotalProtein += protein
+			s.TotalCarbs += carbs
+			s.TotalFat += fat
+			s.TotalFiber += fiber
+		}
+	}
+	return rows.Err()
+}
 
-	rows, err := r.db.Pool.QueryContext(ctx, query, startDate, endDate, userID, userID, userID, userID, userID)
+func (r *Repository) addRunning(ctx context.Context, userID int, from, to time.Time, bucket func(time.Time) *DailySummary) error {
+	query := `
+        SELECT start_time, distance_meters
+        FROM runs
+        WHERE user_id = $1 AND start_time >= $2 AND start_time < $3
+    `
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID, from, to)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	summaries := []DailySummary{}
 	for rows.Next() {
-		var s DailySummary
-		if err := rows.Scan(&s.Date, &s.TotalCalories, &s.TotalProtein, &s.TotalCarbs, &s.TotalFat, &s.RunDistance, &s.WorkoutVolumeKG, &s.ExerciseCalories, &s.WaterML, &s.WeightKG); err != nil {
-			return nil, err
+		var startTime time.Time
+		var distance float64
+		if err := rows.Scan(&startTime, &distance); err != nil {
+			return err
+		}
+		if s := bucket(startTime); s != nil {
+			s.RunDistance += distance
+			s.exerciseCalories += distance / 1000.0 * runKcalPerKm
 		}
-		summaries = append(summaries, s)
 	}
-	return summaries, nil
+	return rows.Err()
+}
+
+// addLifting adds the volume of each finished session, counting only sets
+// that were completed and weren't warm-ups, and the calories its length
+// suggests.
+func (r *Repository) addLifting(ctx context.Context, userID int, from, to time.Time, bucket func(time.Time) *DailySummary) error {
+	query := `
+        SELECT ws.start_time, ` + r.db.Dialect.SecondsBetween("ws.start_time", "ws.end_time") + `, SUM(CASE WHEN s.status = 'completed' AND s.set_type <> 'warmup' THEN s.weight_kg * s.reps ELSE 0 END)
+        FROM workout_sessions ws
+        JOIN workout_sets s ON ws.id = s.session_id
+        WHERE ws.user_id = $1 AND ws.end_time IS NOT NULL AND ws.start_time >= $2 AND ws.start_time < $3
+        GROUP BY ws.id, ws.start_time, ws.end_time
+    `
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var startTime time.Time
+		var seconds, volume float64
+		if err := rows.Scan(&startTime, &seconds, &volume); err != nil {
+			return err
+		}
+		if s := bucket(startTime); s != nil {
+			s.WorkoutVolumeKG += volume
+			s.exerciseCalories += liftingKcalPerMinute * seconds / 60
+		}
+	}
+	return rows.Err()
+}
+
+func (r *Repository) addWater(ctx context.Context, userID int, from, to time.Time, bucket func(time.Time) *DailySummary) error {
+	query := `
+        SELECT recorded_at, amount_ml
+        FROM water_logs
+        WHERE user_id = $1 AND recorded_at >= $2 AND recorded_at < $3
+    `
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recordedAt time.Time
+		var amount int
+		if err := rows.Scan(&recordedAt, &amount); err != nil {
+			return err
+		}
+		if s := bucket(recordedAt); s != nil {
+			s.WaterML += amount
+		}
+	}
+	return rows.Err()
+}
+
+// addBody keeps the last weigh-in of each day.
+func (r *Repository) addBody(ctx context.Context, userID int, from, to time.Time, bucket func(time.Time) *DailySummary) error {
+	query := `
+        SELECT recorded_at, weight_kg
+        FROM body_metrics
+        WHERE user_id = $1 AND weight_kg IS NOT NULL AND recorded_at >= $2 AND recorded_at < $3
+        ORDER BY recorded_at ASC
+    `
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recordedAt time.Time
+		var weight float64
+		if err := rows.Scan(&recordedAt, &weight); err != nil {
+			return err
+		}
+		if s := bucket(recordedAt); s != nil {
+			s.WeightKG = weight
+		}
+	}
+	return rows.Err()
 }
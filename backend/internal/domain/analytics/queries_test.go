@@ -0,0 +1,128 @@
+package analytics
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/testdb"
+)
+
+// dailyFixture spans 7-9 March 2026 in Los Angeles, which springs forward on
+// the 8th. Rows sit on either side of the range's local midnights, where a
+// cut by UTC date or a fetch without the extra day either side goes wrong.
+func dailyFixture(t *testing.T, db *database.DB) (userID int, la *time.Location) {
+	t.Helper()
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, time.March, day, hour, min, 0, 0, la)
+	}
+	id := func(query string, args ...any) int {
+		t.Helper()
+		var id int
+		if err := db.Pool.QueryRow(query+" RETURNING id", args...).Scan(&id); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return id
+	}
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := db.Pool.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	meal := func(user int, eatenAt time.Time, calories ...int) {
+		t.Helper()
+		mealID := id(`INSERT INTO meals (user_id, name, eaten_at) VALUES ($1, 'Meal', $2)`, user, eatenAt)
+		for _, c := range calories {
+			exec(`INSERT INTO food_entries (meal_id, name, calories, protein_g, carbs_g, fat_g, fiber_g) VALUES ($1, 'Food', $2, 10, 20, 5, 2)`, mealID, c)
+		}
+	}
+
+	userID = id(`INSERT INTO users (name, email) VALUES ('Runner', 'runner@example.com')`)
+	other := id(`INSERT INTO users (name, email) VALUES ('Other', 'other@example.com')`)
+	exercise := id(`INSERT INTO exercises (name, category) VALUES ('Test lift', 'Strength')`)
+
+	// Late on the day before the range, and early on the day after.
+	meal(userID, at(6, 23, 30).UTC(), 1000)
+	meal(userID, at(10, 0, 30).UTC(), 1000)
+	// Early on the first day, and late on the last, which is the next day
+	// in UTC.
+	meal(userID, at(7, 0, 30).UTC(), 300, 200)
+	meal(userID, at(9, 23, 30).UTC(), 700)
+	meal(other, at(8, 12, 0).UTC(), 1000)
+
+	// Just after the clocks went forward, stored with another offset.
+	exec(`INSERT INTO runs (user_id, start_time, duration_seconds, distance_meters) VALUES ($1, $2, 3000, 10000)`, userID, at(8, 3, 30).In(kolkata))
+	exec(`INSERT INTO runs (user_id, start_time, duration_seconds, distance_meters) VALUES ($1, $2, 3000, 10000)`, other, at(8, 3, 30))
+
+	// An hour-long session with a completed set, a warm-up, a set that was
+	// only planned and one skipped, and a session still in progress.
+	finished := id(`INSERT INTO workout_sessions (user_id, start_time, end_time) VALUES ($1, $2, $3)`, userID, at(9, 18, 0).UTC(), at(9, 19, 0).UTC())
+	exec(`INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, performed_at) VALUES ($1, $2, 1, 100, 5, $3)`, finished, exercise, at(9, 18, 10).UTC())
+	exec(`INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, performed_at, set_type) VALUES ($1, $2, 2, 40, 10, $3, 'warmup')`, finished, exercise, at(9, 18, 5).UTC())
+	exec(`INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, performed_at, status) VALUES ($1, $2, 3, 100, 5, $3, 'planned')`, finished, exercise, at(9, 18, 20).UTC())
+	exec(`INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, performed_at, status) VALUES ($1, $2, 4, 100, 5, $3, 'skipped')`, finished, exercise, at(9, 18, 30).UTC())
+	open := id(`INSERT INTO workout_sessions (user_id, start_time) VALUES ($1, $2)`, userID, at(9, 20, 0).UTC())
+	exec(`INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, performed_at) VALUES ($1, $2, 1, 100, 5, $3)`, open, exercise, at(9, 20, 10).UTC())
+
+	// Both ends of the short day.
+	exec(`INSERT INTO water_logs (user_id, amount_ml, recorded_at) VALUES ($1, 250, $2)`, userID, at(8, 0, 10).UTC())
+	exec(`INSERT INTO water_logs (user_id, amount_ml, recorded_at) VALUES ($1, 500, $2)`, userID, at(8, 23, 50).UTC())
+
+	// The later weigh-in counts, even though it is the next day in UTC.
+	exec(`INSERT INTO body_metrics (user_id, recorded_at, weight_kg) VALUES ($1, $2, 80)`, userID, at(7, 7, 0).UTC())
+	exec(`INSERT INTO body_metrics (user_id, recorded_at, weight_kg) VALUES ($1, $2, 79.5)`, userID, at(7, 21, 0).UTC())
+	return userID, la
+}
+
+var wantDailySummaries = []DailySummary{
+	{Date: "2026-03-09", TotalCalories: 700, TotalProtein: 10, TotalCarbs: 20, TotalFat: 5, TotalFiber: 2, WorkoutVolumeKG: 500, ExerciseCalories: 360},
+	{Date: "2026-03-08", RunDistance: 10000, ExerciseCalories: 725, WaterML: 750},
+	{Date: "2026-03-07", TotalCalories: 500, TotalProtein: 20, TotalCarbs: 40, TotalFat: 10, TotalFiber: 4, WeightKG: 79.5},
+}
+
+func dailySummaries(t *testing.T, db *database.DB) []DailySummary {
+	t.Helper()
+	userID, la := dailyFixture(t, db)
+	start := time.Date(2026, time.March, 7, 12, 0, 0, 0, la)
+	end := time.Date(2026, time.March, 9, 12, 0, 0, 0, la)
+	got, err := NewRepository(db).GetDailySummaries(context.Background(), userID, start, end, la)
+	if err != nil {
+		t.Fatalf("GetDailySummaries: %v", err)
+	}
+	for i := range got {
+		got[i].exerciseCalories = 0
+	}
+	return got
+}
+
+func checkDailySummaries(t *testing.T, got []DailySummary) {
+	t.Helper()
+	if !reflect.DeepEqual(got, wantDailySummaries) {
+		t.Errorf("GetDailySummaries =\n%+v\nwant\n%+v", got, wantDailySummaries)
+	}
+}
+
+func TestGetDailySummariesSQLite(t *testing.T) {
+	checkDailySummaries(t, dailySummaries(t, testdb.SQLite(t)))
+}
+
+// TestGetDailySummariesPostgres also checks that both databases give the
+// same summaries for the same rows.
+func TestGetDailySummariesPostgres(t *testing.T) {
+	got := dailySummaries(t, testdb.Postgres(t))
+	checkDailySummaries(t, got)
+	if sqlite := dailySummaries(t, testdb.SQLite(t)); !reflect.DeepEqual(got, sqlite) {
+		t.Errorf("Postgres and SQLite differ:\n%+v\n%+v", got, sqlite)
+	}
+}
@@ -0,0 +1,25 @@
+package apitoken
+
+import (
+	"time"
+)
+
+// Token is a personal access token as listed back to its owner. The secret
+// itself is only returned once, when the token is created.
+type Token struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type CreatedToken struct {
+	Token
+
+	// Secret is the value to send as "Authorization: Bearer <secret>".
+	Secret string `json:"token"`
+}
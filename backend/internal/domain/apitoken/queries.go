@@ -0,0 +1,140 @@
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"fitness-buddy/internal/database"
+)
+
+// SecretPrefix starts every token, which makes leaked tokens easy to spot in
+// logs and code search.
+const SecretPrefix = "fb_"
+
+// lastUsedGranularity keeps Authenticate from writing on every request.
+const lastUsedGranularity = time.Minute
+
+var ErrInvalidToken = errors.New("invalid api token")
+
+type Repository struct {
+	db *database.DB
+}
+
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+const tokenColumns = `id, user_id, name, prefix, scopes, created_at, last_used_at, expires_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanToken(row scanner) (*Token, error) {
+	var t Token
+	var scopes string
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, &scopes, &t.CreatedAt, &t.LastUsedAt, &t.ExpiresAt); err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	return &t, nil
+}
+
+// Create mints a token for the user. Scopes must already be validated.
+func (r *Repository) Create(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time) (*CreatedToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	secret := SecretPrefix + base64.RawURLEncoding.EncodeToString(b)
+	prefix := secret[:len(SecretPrefix)+6]
+
+	query := `INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + tokenColumns
+	row := r.db.Pool.QueryRowContext(ctx, query, userID, name, hashSecret(secret), prefix, strings.Join(scopes, " "), time.Now().UTC(), expiresAt)
+	t, err := scanToken(row)
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedToken{Token: *t, Secret: secret}, nil
+}
+
+// List returns the user's tokens that have not been revoked, including
+// expired ones so they can be cleaned up.
+func (r *Repository) List(ctx context.Context, userID int) ([]Token, error) {
+	query := `SELECT ` + tokenColumns + ` FROM api_tokens WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC`
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []Token{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+func (r *Repository) Revoke(ctx context.Context, userID, id int) error {
+	res, err := r.db.Pool.ExecContext(ctx, `UPDATE api_tokens SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`, time.Now().UTC(), id, userID)
+	return database.RequireAffected(res, err)
+}
+
+// RevokeAll revokes every token the user has.
+func (r *Repository) RevokeAll(ctx context.Context, userID int) error {
+	_, err := r.db.Pool.ExecContext(ctx, `UPDATE api_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`, time.Now().UTC(), userID)
+	return err
+}
+
+// Authenticate resolves a bearer secret to its token and records the use.
+// Unknown, revoked and expired tokens all give ErrInvalidToken.
+func (r *Repository) Authenticate(ctx context.Context, secret string) (*Token, error) {
+	if !strings.HasPrefix(secret, SecretPrefix) {
+		return nil, ErrInvalidToken
+	}
+	query := `SELECT ` + tokenColumns + ` FROM api_tokens WHERE token_hash = $1 AND revoked_at IS NULL`
+	t, err := scanToken(r.db.Pool.QueryRowContext(ctx, query, hashSecret(secret)))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if t.ExpiresAt != nil && now.After(*t.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= lastUsedGranularity {
+		if _, err := r.db.Pool.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = $1 WHERE id = $2`, now, t.ID); err != nil {
+			return nil, err
+		}
+		t.LastUsedAt = &now
+	}
+	return t, nil
+}
+
+// PurgeUser deletes the user's tokens inside tx, as part of deleting the
+// account.
+func (r *Repository) PurgeUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = $1`, userID)
+	return err
+}
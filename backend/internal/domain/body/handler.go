@@ -23,7 +23,10 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 }
 
 func (h *Handler) ListMetrics(w http.ResponseWriter, r *http.Request) {
-    userID := auth.GetUserID(r.Context())
+    userID, ok := auth.RequireUserID(w, r)
+    if !ok {
+        return
+    }
     metrics, err := h.repo.ListMetrics(r.Context(), userID, 50)
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -49,7 +52,10 @@ func (h *Handler) CreateMetric(w http.ResponseWriter, r *http.Request) {
         req.RecordedAt = time.Now()
     }
 
-    userID := auth.GetUserID(r.Context())
+    userID, ok := auth.RequireUserID(w, r)
+    if !ok {
+        return
+    }
     bm, err := h.repo.CreateMetric(r.Context(), userID, req.RecordedAt, req.WeightKG, req.BodyFatPercent)
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
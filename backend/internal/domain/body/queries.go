@@ -2,6 +2,7 @@ package body
 
 import (
 	"context"
+	"database/sql"
 	"fitness-buddy/internal/database"
 	"time"
 )
@@ -57,3 +58,12 @@ func (r *Repository) ListMetrics(ctx context.Context, userID, limit int) ([]Body
 	}
 	return metrics, nil
 }
+
+// PurgeUser deletes the user's body metrics inside tx, as part of deleting
+// the account.
+func (r *Repository) PurgeUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM body_metrics WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package coaching
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	inviteTTL = 7 * 24 * time.Hour
+
+	maxCommentLength = 2000
+)
+
+type Handler struct {
+	repo *Repository
+}
+
+func NewHandler(repo *Repository) *Handler {
+	return &Handler{repo: repo}
+}
+
+// RegisterRoutes mounts grant management and comment deletion. The router
+// keeps these to interactive logins.
+func (h *Handler) RegisterRoutes(r chi.Router) {
+	r.Get("/coaching/coaches", h.ListCoaches)
+	r.Get("/coaching/athletes", h.ListAthletes)
+	r.Post("/coaching/invites", h.CreateInvite)
+	r.Post("/coaching/invites/accept", h.AcceptInvite)
+	r.Delete("/coaching/grants/{id}", h.RevokeGrant)
+	r.Delete("/comments/{id}", h.DeleteComment)
+}
+
+// RegisterCommentRoutes mounts the comment thread of a target, as in
+// /runs/{id}/comments. It goes in the target's own scope group.
+func (h *Handler) RegisterCommentRoutes(r chi.Router, target Target) {
+	r.Get("/"+target.Path+"/{id}/comments", h.ListComments(target))
+	r.Post("/"+target.Path+"/{id}/comments", h.AddComment(target))
+}
+
+func (h *Handler) ListCoaches(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	grants, err := h.repo.ListCoaches(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(grants)
+}
+
+func (h *Handler) ListAthletes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	grants, err := h.repo.ListAthletes(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(grants)
+}
+
+type CreateInviteRequest struct {
+	Scopes []string `json:"scopes"`
+}
+
+func (h *Handler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	var req CreateInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	scopes, err := ParseScopes(req.Scopes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	invite, err := h.repo.CreateInvite(r.Context(), userID, scopes, inviteTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+type AcceptInviteRequest struct {
+	Token string `json:"token"`
+}
+
+func (h *Handler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	var req AcceptInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	grant, err := h.repo.AcceptInvite(r.Context(), userID, strings.TrimSpace(req.Token))
+	if errors.Is(err, ErrInvalidInvite) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(grant)
+}
+
+func (h *Handler) RevokeGrant(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid grant ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.Revoke(r.Context(), userID, id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Grant not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// targetID parses the target from the URL and checks the caller may see it.
+// Targets the caller can't see are reported as missing.
+func (h *Handler) targetID(w http.ResponseWriter, r *http.Request, target Target) (int, int, bool) {
+	userID, ok := auth.ActorID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	allowed, err := h.repo.CanAccess(r.Context(), userID, target, id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return 0, 0, false
+	}
+	if !allowed {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+func (h *Handler) ListComments(target Target) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, id, ok := h.targetID(w, r, target)
+		if !ok {
+			return
+		}
+		comments, err := h.repo.ListComments(r.Context(), target, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(comments)
+	}
+}
+
+type AddCommentRequest struct {
+	Body string `json:"body"`
+}
+
+func (h *Handler) AddComment(target Target) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, id, ok := h.targetID(w, r, target)
+		if !ok {
+			return
+		}
+		var req AddCommentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body := strings.TrimSpace(req.Body)
+		if body == "" || len(body) > maxCommentLength {
+			http.Error(w, "body must be 1-"+strconv.Itoa(maxCommentLength)+" characters", http.StatusBadRequest)
+			return
+		}
+
+		comment, err := h.repo.AddComment(r.Context(), userID, target, id, body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(comment)
+	}
+}
+
+func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.DeleteComment(r.Context(), userID, id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
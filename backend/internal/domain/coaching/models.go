@@ -0,0 +1,58 @@
+package coaching
+
+import (
+	"time"
+)
+
+// Grant lets a coach read some of an athlete's data. Until a coach accepts
+// the invite, CoachID is nil.
+type Grant struct {
+	ID          int     `json:"id"`
+	AthleteID   int     `json:"athlete_id"`
+	AthleteName string  `json:"athlete_name"`
+	CoachID     *int    `json:"coach_id"`
+	CoachName   *string `json:"coach_name"`
+
+	// Scopes are the scope groups the coach can read, out of Groups.
+	Scopes          []string   `json:"scopes"`
+	CreatedAt       time.Time  `json:"created_at"`
+	InviteExpiresAt time.Time  `json:"invite_expires_at"`
+	AcceptedAt      *time.Time `json:"accepted_at"`
+}
+
+type CreatedInvite struct {
+	Grant
+
+	// Token is handed to the coach, who accepts it while signed in.
+	Token string `json:"token"`
+}
+
+type Comment struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	AuthorName string    `json:"author_name"`
+	SessionID  *int      `json:"session_id"`
+	RunID      *int      `json:"run_id"`
+	MealID     *int      `json:"meal_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Target is something comments can be attached to.
+type Target struct {
+	// Path is the collection in the URL, as in /api/runs/{id}/comments.
+	Path  string
+	Table string
+
+	// Column is the comments column that points at Table.
+	Column string
+
+	// Group is the scope group a coach needs to see the target.
+	Group string
+}
+
+var (
+	TargetSession = Target{Path: "sessions", Table: "workout_sessions", Column: "session_id", Group: "resistance"}
+	TargetRun     = Target{Path: "runs", Table: "runs", Column: "run_id", Group: "running"}
+	TargetMeal    = Target{Path: "meals", Table: "meals", Column: "meal_id", Group: "nutrition"}
+)
@@ -0,0 +1,280 @@
+package coaching
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fitness-buddy/internal/database"
+)
+
+// Groups are the scope groups an athlete can share with a coach.
+var Groups = []string{"resistance", "running", "nutrition", "body"}
+
+// InvitePrefix starts every invite token.
+const InvitePrefix = "fbi_"
+
+var ErrInvalidInvite = errors.New("invite is invalid, expired or already used")
+
+type Repository struct {
+	db *database.DB
+}
+
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ParseScopes validates the groups of an invite and returns them normalized.
+func ParseScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	out := make([]string, 0, len(scopes))
+	for _, raw := range scopes {
+		scope := strings.ToLower(strings.TrimSpace(raw))
+		if !hasGroup(Groups, scope) {
+			return nil, fmt.Errorf("invalid scope %q, expected one of %s", raw, strings.Join(Groups, ", "))
+		}
+		if !hasGroup(out, scope) {
+			out = append(out, scope)
+		}
+	}
+	return out, nil
+}
+
+func hasGroup(groups []string, group string) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+func hashInvite(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const grantSelect = `SELECT g.id, g.user_id, a.name, g.coach_id, c.name, g.scopes, g.created_at, g.invite_expires_at, g.accepted_at
+	FROM coach_grants g
+	JOIN users a ON a.id = g.user_id
+	LEFT JOIN users c ON c.id = g.coach_id`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanGrant(row scanner) (*Grant, error) {
+	var g Grant
+	var scopes string
+	if err := row.Scan(&g.ID, &g.AthleteID, &g.AthleteName, &g.CoachID, &g.CoachName, &scopes, &g.CreatedAt, &g.InviteExpiresAt, &g.AcceptedAt); err != nil {
+		return nil, err
+	}
+	g.Scopes = strings.Fields(scopes)
+	return &g, nil
+}
+
+func (r *Repository) listGrants(ctx context.Context, where string, args ...any) ([]Grant, error) {
+	rows, err := r.db.Pool.QueryContext(ctx, grantSelect+` WHERE `+where+` ORDER BY g.created_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []Grant{}
+	for rows.Next() {
+		g, err := scanGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, *g)
+	}
+	return grants, rows.Err()
+}
+
+// CreateInvite starts a grant from the athlete. Scopes must already be
+// validated.
+func (r *Repository) CreateInvite(ctx context.Context, athleteID int, scopes []string, ttl time.Duration) (*CreatedInvite, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := InvitePrefix + base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now().UTC()
+
+	var id int
+	query := `INSERT INTO coach_grants (user_id, scopes, invite_hash, invite_expires_at, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	if err := r.db.Pool.QueryRowContext(ctx, query, athleteID, strings.Join(scopes, " "), hashInvite(token), now.Add(ttl), now).Scan(&id); err != nil {
+		return nil, err
+	}
+	g, err := scanGrant(r.db.Pool.QueryRowContext(ctx, grantSelect+` WHERE g.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedInvite{Grant: *g, Token: token}, nil
+}
+
+// AcceptInvite makes the coach the holder of the invite's grant. An invite
+// works once, and a coach can't accept their own. Any earlier grant between
+// the same two people is revoked, so the newest invite decides the scopes.
+func (r *Repository) AcceptInvite(ctx context.Context, coachID int, token string) (*Grant, error) {
+	if !strings.HasPrefix(token, InvitePrefix) {
+		return nil, ErrInvalidInvite
+	}
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var id, athleteID int
+	query := `UPDATE coach_grants SET coach_id = $1, accepted_at = $2
+		WHERE invite_hash = $3 AND accepted_at IS NULL AND revoked_at IS NULL AND invite_expires_at > $2 AND user_id <> $1
+		RETURNING id, user_id`
+	err = tx.QueryRowContext(ctx, query, coachID, now, hashInvite(token)).Scan(&id, &athleteID)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidInvite
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE coach_grants SET revoked_at = $1 WHERE user_id = $2 AND coach_id = $3 AND id <> $4 AND revoked_at IS NULL`, now, athleteID, coachID, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return scanGrant(r.db.Pool.QueryRowContext(ctx, grantSelect+` WHERE g.id = $1`, id))
+}
+
+// ListCoaches returns the grants the athlete has handed out, including
+// invites nobody has accepted yet.
+func (r *Repository) ListCoaches(ctx context.Context, athleteID int) ([]Grant, error) {
+	return r.listGrants(ctx, `g.user_id = $1 AND g.revoked_at IS NULL AND (g.accepted_at IS NOT NULL OR g.invite_expires_at > $2)`, athleteID, time.Now().UTC())
+}
+
+// ListAthletes returns the grants the coach holds.
+func (r *Repository) ListAthletes(ctx context.Context, coachID int) ([]Grant, error) {
+	return r.listGrants(ctx, `g.coach_id = $1 AND g.accepted_at IS NOT NULL AND g.revoked_at IS NULL`, coachID)
+}
+
+// Revoke ends a grant or withdraws an invite. Either the athlete or the coach
+// can do it.
+func (r *Repository) Revoke(ctx context.Context, userID, id int) error {
+	res, err := r.db.Pool.ExecContext(ctx, `UPDATE coach_grants SET revoked_at = $1 WHERE id = $2 AND (user_id = $3 OR coach_id = $3) AND revoked_at IS NULL`, time.Now().UTC(), id, userID)
+	return database.RequireAffected(res, err)
+}
+
+// Allowed reports whether the coach holds a grant covering group for the
+// athlete.
+func (r *Repository) Allowed(ctx context.Context, coachID, athleteID int, group string) (bool, error) {
+	rows, err := r.db.Pool.QueryContext(ctx, `SELECT scopes FROM coach_grants WHERE user_id = $1 AND coach_id = $2 AND accepted_at IS NOT NULL AND revoked_at IS NULL`, athleteID, coachID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var scopes string
+		if err := rows.Scan(&scopes); err != nil {
+			return false, err
+		}
+		if hasGroup(strings.Fields(scopes), group) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// CanAccess reports whether the user may read and comment on a target: they
+// own it, or they coach its owner with the target's group granted. Missing
+// targets give database.ErrNotFound.
+func (r *Repository) CanAccess(ctx context.Context, userID int, target Target, id int) (bool, error) {
+	var ownerID int
+	err := r.db.Pool.QueryRowContext(ctx, `SELECT user_id FROM `+target.Table+` WHERE id = $1`, id).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return false, database.ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	if ownerID == userID {
+		return true, nil
+	}
+	return r.Allowed(ctx, userID, ownerID, target.Group)
+}
+
+const commentSelect = `SELECT cm.id, cm.user_id, u.name, cm.session_id, cm.run_id, cm.meal_id, cm.body, cm.created_at
+	FROM comments cm
+	JOIN users u ON u.id = cm.user_id`
+
+func scanComment(row scanner) (*Comment, error) {
+	var c Comment
+	if err := row.Scan(&c.ID, &c.UserID, &c.AuthorName, &c.SessionID, &c.RunID, &c.MealID, &c.Body, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *Repository) ListComments(ctx context.Context, target Target, id int) ([]Comment, error) {
+	rows, err := r.db.Pool.QueryContext(ctx, commentSelect+` WHERE cm.`+target.Column+` = $1 ORDER BY cm.created_at, cm.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *c)
+	}
+	return comments, rows.Err()
+}
+
+// AddComment stores a comment. Callers check CanAccess first.
+func (r *Repository) AddComment(ctx context.Context, userID int, target Target, id int, body string) (*Comment, error) {
+	var commentID int
+	query := `INSERT INTO comments (user_id, ` + target.Column + `, body, created_at) VALUES ($1, $2, $3, $4) RETURNING id`
+	if err := r.db.Pool.QueryRowContext(ctx, query, userID, id, body, time.Now().UTC()).Scan(&commentID); err != nil {
+		return nil, err
+	}
+	return scanComment(r.db.Pool.QueryRowContext(ctx, commentSelect+` WHERE cm.id = $1`, commentID))
+}
+
+// DeleteComment removes a comment written by the user, or one left on their
+// own workout, run or meal.
+func (r *Repository) DeleteComment(ctx context.Context, userID, id int) error {
+	query := `DELETE FROM comments WHERE id = $1 AND (
+		user_id = $2 OR
+		session_id IN (SELECT id FROM workout_sessions WHERE user_id = $2) OR
+		run_id IN (SELECT id FROM runs WHERE user_id = $2) OR
+		meal_id IN (SELECT id FROM meals WHERE user_id = $2))`
+	res, err := r.db.Pool.ExecContext(ctx, query, id, userID)
+	return database.RequireAffected(res, err)
+}
+
+// PurgeUser deletes the user's comments and every grant they gave or held
+// inside tx, as part of deleting the account. Comments others left on the
+// user's data go with the workouts, runs and meals they belong to.
+func (r *Repository) PurgeUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM coach_grants WHERE user_id = $1 OR coach_id = $1`, userID); err != nil {
+		return err
+	}
+	return nil
+}
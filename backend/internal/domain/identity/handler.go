@@ -7,6 +7,9 @@ import (
 	"net/http"
 
     "fitness-buddy/internal/auth"
+    "fitness-buddy/internal/calendar"
+    "fitness-buddy/internal/energy"
+    "fitness-buddy/internal/units"
 
 
 
@@ -46,7 +49,10 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 
 func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 
-    userID := auth.GetUserID(r.Context())
+    userID, ok := auth.RequireUserID(w, r)
+    if !ok {
+        return
+    }
 
 	user, err := h.repo.GetUser(r.Context(), userID)
 
@@ -80,6 +86,10 @@ type CreateUserRequest struct {
 
 	WeightGoal    *string  `json:"weight_goal"`
 
+	Units         *string  `json:"units"`
+
+	Timezone      *string  `json:"timezone"`
+
 }
 
 
@@ -118,7 +128,10 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 
 
-    userID := auth.GetUserID(r.Context())
+    userID, ok := auth.RequireUserID(w, r)
+    if !ok {
+        return
+    }
 
 
 
@@ -148,9 +161,43 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	if req.Units != nil {
+		system, err := units.Parse(*req.Units)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Units = (*string)(&system)
+	}
+	if req.ActivityLevel != nil {
+		activity, err := energy.ParseActivity(*req.ActivityLevel)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.ActivityLevel = &activity.Level
+	}
+	if req.WeightGoal != nil {
+		goal, err := energy.ParseGoal(*req.WeightGoal)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.WeightGoal = &goal.Name
+	}
+	if req.Timezone != nil {
+		loc, err := calendar.LoadLocation(*req.Timezone)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := loc.String()
+		req.Timezone = &name
+	}
+
 
 
-	user, err := h.repo.UpdateUser(r.Context(), existing.ID, req.Name, req.HeightCM, req.DOB, req.Sex, req.ActivityLevel, req.WeightGoal)
+	user, err := h.repo.UpdateUser(r.Context(), existing.ID, req.Name, req.HeightCM, req.DOB, req.Sex, req.ActivityLevel, req.WeightGoal, req.Units, req.Timezone)
 
 	if err != nil {
 
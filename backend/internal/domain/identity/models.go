@@ -27,7 +27,32 @@ type User struct {
 
 	WeightGoal *string `json:"weight_goal"`
 
+	// Units is "metric" or "imperial"; see package units.
+	Units string `json:"units"`
+
+	// Timezone is the IANA zone days are counted in; see package calendar.
+	Timezone string `json:"timezone"`
+
+	IsDemo bool `json:"is_demo"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// DeleteAfter is set while the account is scheduled for deletion.
+	DeleteAfter *time.Time `json:"delete_after"`
+
 	CreatedAt time.Time `json:"created_at"`
 
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// Identity is one way of signing in to a user account.
+type Identity struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+
+	// Provider is one of ProviderGoogle, ProviderPhone or ProviderEmail.
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Display   *string   `json:"display"`
+	CreatedAt time.Time `json:"created_at"`
+}
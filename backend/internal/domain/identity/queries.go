@@ -2,7 +2,16 @@ package identity
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fitness-buddy/internal/calendar"
 	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/units"
+	"time"
 )
 
 type Repository struct {
@@ -13,33 +22,55 @@ func NewRepository(db *database.DB) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) GetUser(ctx context.Context, userID int) (*User, error) {
-	query := `SELECT id, name, email, google_id, phone_number, firebase_uid, height_cm, dob, sex, activity_level, weight_goal, created_at, updated_at FROM users WHERE id = $1`
-	row := r.db.Pool.QueryRowContext(ctx, query, userID)
+const userColumns = `id, name, email, google_id, phone_number, firebase_uid, height_cm, dob, sex, activity_level, weight_goal, units, timezone, is_demo, email_verified_at, delete_after, created_at, updated_at`
 
+func scanUser(row *sql.Row) (*User, error) {
 	var u User
-	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.GoogleID, &u.PhoneNumber, &u.FirebaseUID, &u.HeightCM, &u.DOB, &u.Sex, &u.ActivityLevel, &u.WeightGoal, &u.CreatedAt, &u.UpdatedAt)
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.GoogleID, &u.PhoneNumber, &u.FirebaseUID, &u.HeightCM, &u.DOB, &u.Sex, &u.ActivityLevel, &u.WeightGoal, &u.Units, &u.Timezone, &u.IsDemo, &u.EmailVerifiedAt, &u.DeleteAfter, &u.CreatedAt, &u.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
+func (r *Repository) GetUser(ctx context.Context, userID int) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(r.db.Pool.QueryRowContext(ctx, query, userID))
+}
+
 func (r *Repository) GetOrCreateUserByGoogleID(ctx context.Context, googleID, email, name string) (*User, error) {
-	query := `SELECT id, name, email, google_id, phone_number, firebase_uid, height_cm, dob, sex, activity_level, weight_goal, created_at, updated_at FROM users WHERE google_id = $1`
-	row := r.db.Pool.QueryRowContext(ctx, query, googleID)
+	u, err := r.GetUserByIdentity(ctx, ProviderGoogle, googleID)
+	if err != sql.ErrNoRows {
+		return u, err
+	}
 
-	var u User
-	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.GoogleID, &u.PhoneNumber, &u.FirebaseUID, &u.HeightCM, &u.DOB, &u.Sex, &u.ActivityLevel, &u.WeightGoal, &u.CreatedAt, &u.UpdatedAt)
-	if err == nil {
-		return &u, nil
+	// A new Google login only claims the address if nobody else has it.
+	// Otherwise it would be a second account with someone else's email;
+	// whoever owns that account links Google from inside it instead.
+	var emailCol *string
+	if email != "" {
+		if _, err := r.GetUserByEmail(ctx, email); err == sql.ErrNoRows {
+			emailCol = &email
+		} else if err != nil {
+			return nil, err
+		}
 	}
 
-	// Create new user if not found - use RETURNING for PostgreSQL
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var newID int
 	insertQuery := `INSERT INTO users (name, email, google_id) VALUES ($1, $2, $3) RETURNING id`
-	err = r.db.Pool.QueryRowContext(ctx, insertQuery, name, email, googleID).Scan(&newID)
-	if err != nil {
+	if err := tx.QueryRowContext(ctx, insertQuery, name, emailCol, googleID).Scan(&newID); err != nil {
+		return nil, err
+	}
+	if err := insertIdentity(ctx, tx, newID, ProviderGoogle, googleID, email); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
@@ -57,7 +88,7 @@ func (r *Repository) CreateUser(ctx context.Context, name string, height *float6
 	return r.GetUserByID(ctx, newID)
 }
 
-func (r *Repository) UpdateUser(ctx context.Context, id int, name string, height *float64, dob *string, sex *string, activity *string, goal *string) (*User, error) {
+func (r *Repository) UpdateUser(ctx context.Context, id int, name string, height *float64, dob *string, sex *string, activity *string, goal *string, unitSystem *string, timezone *string) (*User, error) {
 	current, err := r.GetUserByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -93,47 +124,73 @@ func (r *Repository) UpdateUser(ctx context.Context, id int, name string, height
 		newGoal = goal
 	}
 
-	query := `UPDATE users SET name = $1, height_cm = $2, dob = $3, sex = $4, activity_level = $5, weight_goal = $6, updated_at = CURRENT_TIMESTAMP WHERE id = $7`
-	_, err = r.db.Pool.ExecContext(ctx, query, newName, newHeight, newDob, newSex, newActivity, newGoal, id)
+	newUnits := current.Units
+	if unitSystem != nil {
+		newUnits = *unitSystem
+	}
+
+	newTimezone := current.Timezone
+	if timezone != nil {
+		newTimezone = *timezone
+	}
+
+	query := `UPDATE users SET name = $1, height_cm = $2, dob = $3, sex = $4, activity_level = $5, weight_goal = $6, units = $7, timezone = $8, updated_at = CURRENT_TIMESTAMP WHERE id = $9`
+	_, err = r.db.Pool.ExecContext(ctx, query, newName, newHeight, newDob, newSex, newActivity, newGoal, newUnits, newTimezone, id)
 	if err != nil {
 		return nil, err
 	}
 	return r.GetUserByID(ctx, id)
 }
 
-func (r *Repository) GetUserByID(ctx context.Context, id int) (*User, error) {
-	query := `SELECT id, name, email, google_id, phone_number, firebase_uid, height_cm, dob, sex, activity_level, weight_goal, created_at, updated_at FROM users WHERE id = $1`
-	row := r.db.Pool.QueryRowContext(ctx, query, id)
+// GetUnits returns the unit system the user wants measurements in.
+func (r *Repository) GetUnits(ctx context.Context, userID int) (units.System, error) {
+	var system string
+	err := r.db.Pool.QueryRowContext(ctx, `SELECT units FROM users WHERE id = $1`, userID).Scan(&system)
+	if err == sql.ErrNoRows {
+		return "", database.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return units.Parse(system)
+}
 
-	var u User
-	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.GoogleID, &u.PhoneNumber, &u.FirebaseUID, &u.HeightCM, &u.DOB, &u.Sex, &u.ActivityLevel, &u.WeightGoal, &u.CreatedAt, &u.UpdatedAt)
+// GetLocation returns the timezone the user's days are counted in.
+func (r *Repository) GetLocation(ctx context.Context, userID int) (*time.Location, error) {
+	var name string
+	err := r.db.Pool.QueryRowContext(ctx, `SELECT timezone FROM users WHERE id = $1`, userID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return nil, database.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &u, nil
+	return calendar.LoadLocation(name)
 }
 
-func (r *Repository) GetOrCreateUserByPhone(ctx context.Context, phoneNumber, firebaseUID, name string) (*User, error) {
-	// Try to find by Firebase UID first
-	query := `SELECT id, name, email, google_id, phone_number, firebase_uid, height_cm, dob, sex, activity_level, weight_goal, created_at, updated_at FROM users WHERE firebase_uid = $1`
-	row := r.db.Pool.QueryRowContext(ctx, query, firebaseUID)
+func (r *Repository) GetUserByID(ctx context.Context, id int) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(r.db.Pool.QueryRowContext(ctx, query, id))
+}
 
-	var u User
-	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.GoogleID, &u.PhoneNumber, &u.FirebaseUID, &u.HeightCM, &u.DOB, &u.Sex, &u.ActivityLevel, &u.WeightGoal, &u.CreatedAt, &u.UpdatedAt)
-	if err == nil {
-		return &u, nil
+func (r *Repository) GetOrCreateUserByPhone(ctx context.Context, phoneNumber, firebaseUID, name string) (*User, error) {
+	u, err := r.GetUserByIdentity(ctx, ProviderPhone, firebaseUID)
+	if err != sql.ErrNoRows {
+		return u, err
 	}
 
-	// Try to find by phone number
-	query = `SELECT id, name, email, google_id, phone_number, firebase_uid, height_cm, dob, sex, activity_level, weight_goal, created_at, updated_at FROM users WHERE phone_number = $1`
-	row = r.db.Pool.QueryRowContext(ctx, query, phoneNumber)
-	err = row.Scan(&u.ID, &u.Name, &u.Email, &u.GoogleID, &u.PhoneNumber, &u.FirebaseUID, &u.HeightCM, &u.DOB, &u.Sex, &u.ActivityLevel, &u.WeightGoal, &u.CreatedAt, &u.UpdatedAt)
+	// Firebase has verified the number, so an account that already has it
+	// on file is the same person under a new Firebase UID.
+	query := `SELECT ` + userColumns + ` FROM users WHERE phone_number = $1`
+	u, err = scanUser(r.db.Pool.QueryRowContext(ctx, query, phoneNumber))
 	if err == nil {
-		// Update firebase_uid if not set
-		if u.FirebaseUID == nil {
-			_, _ = r.db.Pool.ExecContext(ctx, `UPDATE users SET firebase_uid = $1 WHERE id = $2`, firebaseUID, u.ID)
+		if err := r.LinkIdentity(ctx, u.ID, ProviderPhone, firebaseUID, phoneNumber); err != nil {
+			return nil, err
 		}
-		return &u, nil
+		return r.GetUserByID(ctx, u.ID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
 	}
 
 	// Create new user
@@ -141,12 +198,514 @@ func (r *Repository) GetOrCreateUserByPhone(ctx context.Context, phoneNumber, fi
 	if useName == "" {
 		useName = "User"
 	}
+
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var newID int
 	insertQuery := `INSERT INTO users (name, phone_number, firebase_uid) VALUES ($1, $2, $3) RETURNING id`
-	err = r.db.Pool.QueryRowContext(ctx, insertQuery, useName, phoneNumber, firebaseUID).Scan(&newID)
+	if err := tx.QueryRowContext(ctx, insertQuery, useName, phoneNumber, firebaseUID).Scan(&newID); err != nil {
+		return nil, err
+	}
+	if err := insertIdentity(ctx, tx, newID, ProviderPhone, firebaseUID, phoneNumber); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetUserByID(ctx, newID)
+}
+
+// CreateDemoUser provisions a fresh throwaway account. Every demo visitor
+// gets their own row, so nobody shares data with anyone else.
+func (r *Repository) CreateDemoUser(ctx context.Context) (*User, error) {
+	var newID int
+	query := `INSERT INTO users (name, height_cm, sex, is_demo) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := r.db.Pool.QueryRowContext(ctx, query, "Demo User", 175, "M", true).Scan(&newID)
 	if err != nil {
 		return nil, err
 	}
+	return r.GetUserByID(ctx, newID)
+}
+
+// userDataTables are the tables holding what a user has logged, each with a
+// user_id column. Child rows (sets, food entries, routine exercises, comments
+// on them) hang off these and follow their parents.
+var userDataTables = []string{"workout_sessions", "routines", "runs", "shoes", "meals", "water_logs", "body_metrics", "nutrition_goals", "comments"}
+
+// libraryTables are the shared libraries whose custom entries record who added
+// them in created_by.
+var libraryTables = []string{"exercises", "food_library"}
+
+// ResetUserData deletes everything the user has logged while keeping the
+// account itself. Child rows (sets, food entries, routine exercises) go with
+// their parents through ON DELETE CASCADE.
+func (r *Repository) ResetUserData(ctx context.Context, userID int) error {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	for _, table := range userDataTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Purposes of the single-use tokens mailed to users.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+var (
+	ErrEmailTaken   = errors.New("email already registered")
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// GetUserByEmail looks an address up case-insensitively. Callers normalize
+// the address first; the lower() covers rows written by the OAuth flow.
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE lower(email) = lower($1) ORDER BY id LIMIT 1`
+	return scanUser(r.db.Pool.QueryRowContext(ctx, query, email))
+}
+
+// CreatePasswordUser registers a local account with an email identity. The
+// address starts out unverified.
+func (r *Repository) CreatePasswordUser(ctx context.Context, name, email, passwordHash string) (*User, error) {
+	if _, err := r.GetUserByEmail(ctx, email); err == nil {
+		return nil, ErrEmailTaken
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+	if _, err := r.GetUserByIdentity(ctx, ProviderEmail, email); err == nil {
+		return nil, ErrEmailTaken
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var newID int
+	query := `INSERT INTO users (name, email, password_hash) VALUES ($1, $2, $3) RETURNING id`
+	if err := tx.QueryRowContext(ctx, query, name, email, passwordHash).Scan(&newID); err != nil {
+		return nil, err
+	}
+	if err := insertIdentity(ctx, tx, newID, ProviderEmail, email, email); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return r.GetUserByID(ctx, newID)
 }
+
+// LinkEmail gives the account email + password sign-in in one transaction:
+// it sets the address, which has to be verified again unless it is the
+// current one, replaces the password and links the email identity.
+func (r *Repository) LinkEmail(ctx context.Context, userID int, email, passwordHash string) error {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var owner int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE lower(email) = lower($1) AND id <> $2 LIMIT 1`, email, userID).Scan(&owner)
+	if err == nil {
+		return ErrEmailTaken
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	query := `UPDATE users SET
+		email_verified_at = CASE WHEN lower(email) = lower($1) THEN email_verified_at ELSE NULL END,
+		email = $1, password_hash = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3`
+	if err := database.RequireAffected(tx.ExecContext(ctx, query, email, passwordHash, userID)); err != nil {
+		return err
+	}
+	if err := linkIdentity(ctx, tx, userID, ProviderEmail, email, email); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPasswordHash returns the user's password hash, or "" for accounts that
+// only sign in through Google, phone or demo mode.
+func (r *Repository) GetPasswordHash(ctx context.Context, userID int) (string, error) {
+	var hash sql.NullString
+	err := r.db.Pool.QueryRowContext(ctx, `SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&hash)
+	return hash.String, err
+}
+
+func (r *Repository) SetPassword(ctx context.Context, userID int, passwordHash string) error {
+	res, err := r.db.Pool.ExecContext(ctx, `UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, passwordHash, userID)
+	return database.RequireAffected(res, err)
+}
+
+// MarkEmailVerified records that the user proved ownership of email. It is a
+// no-op error (ErrInvalidToken) if the account's address has changed since the
+// link was sent.
+func (r *Repository) MarkEmailVerified(ctx context.Context, userID int, email string) error {
+	res, err := r.db.Pool.ExecContext(ctx, `UPDATE users SET email_verified_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND lower(email) = lower($3)`,
+		time.Now().UTC(), userID, email)
+	if err := database.RequireAffected(res, err); errors.Is(err, database.ErrNotFound) {
+		return ErrInvalidToken
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateEmailToken issues a single-use token for purpose, bound to the
+// address it is mailed to. Only its hash is stored.
+func (r *Repository) CreateEmailToken(ctx context.Context, userID int, purpose, email string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now().UTC()
+	query := `INSERT INTO email_tokens (token_hash, user_id, purpose, email, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := r.db.Pool.ExecContext(ctx, query, hashToken(token), userID, purpose, email, now, now.Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeEmailToken redeems a token and returns who it was issued to. Using
+// one token also burns the user's other outstanding tokens for the same
+// purpose, so an older reset link can't be used after a newer one.
+func (r *Repository) ConsumeEmailToken(ctx context.Context, purpose, token string) (int, string, error) {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	// Claiming the token in the UPDATE itself means two concurrent requests
+	// can't both redeem it.
+	now := time.Now().UTC()
+	var userID int
+	var email string
+	query := `UPDATE email_tokens SET used_at = $1
+		WHERE token_hash = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id, email`
+	err = tx.QueryRowContext(ctx, query, now, hashToken(token), purpose).Scan(&userID, &email)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrInvalidToken
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE email_tokens SET used_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL`, now, userID, purpose); err != nil {
+		return 0, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+	return userID, email, nil
+}
+
+const (
+	ProviderGoogle = "google"
+	ProviderPhone  = "phone"
+	ProviderEmail  = "email"
+)
+
+var (
+	// ErrIdentityTaken means the login is already linked to another user.
+	ErrIdentityTaken = errors.New("identity is linked to another account")
+	ErrLastIdentity  = errors.New("cannot unlink the only way to sign in")
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertIdentity(ctx context.Context, db execer, userID int, provider, subject, display string) error {
+	var displayCol *string
+	if display != "" {
+		displayCol = &display
+	}
+	_, err := db.ExecContext(ctx, `INSERT INTO user_identities (user_id, provider, subject, display, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		userID, provider, subject, displayCol, time.Now().UTC())
+	return err
+}
+
+// GetUserByIdentity finds the user a login belongs to, or sql.ErrNoRows.
+func (r *Repository) GetUserByIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2)`
+	return scanUser(r.db.Pool.QueryRowContext(ctx, query, provider, subject))
+}
+
+func (r *Repository) ListIdentities(ctx context.Context, userID int) ([]Identity, error) {
+	query := `SELECT id, user_id, provider, subject, display, created_at FROM user_identities WHERE user_id = $1 ORDER BY created_at, id`
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []Identity{}
+	for rows.Next() {
+		var i Identity
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Display, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+	return identities, rows.Err()
+}
+
+// LinkIdentity attaches a login to the user. Linking one the user already
+// has is a no-op; one that belongs to someone else gives ErrIdentityTaken.
+// The legacy google_id / phone_number / firebase_uid columns are kept in step
+// so the user record still shows them.
+func (r *Repository) LinkIdentity(ctx context.Context, userID int, provider, subject, display string) error {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := linkIdentity(ctx, tx, userID, provider, subject, display); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func linkIdentity(ctx context.Context, tx *sql.Tx, userID int, provider, subject, display string) error {
+	var owner int
+	err := tx.QueryRowContext(ctx, `SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`, provider, subject).Scan(&owner)
+	switch {
+	case err == nil && owner == userID:
+		return nil
+	case err == nil:
+		return ErrIdentityTaken
+	case err != sql.ErrNoRows:
+		return err
+	}
+
+	if err := insertIdentity(ctx, tx, userID, provider, subject, display); err != nil {
+		return err
+	}
+	switch provider {
+	case ProviderGoogle:
+		_, err = tx.ExecContext(ctx, `UPDATE users SET google_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, subject, userID)
+	case ProviderPhone:
+		_, err = tx.ExecContext(ctx, `UPDATE users SET phone_number = $1, firebase_uid = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`, display, subject, userID)
+	default:
+		err = nil
+	}
+	return err
+}
+
+// UnlinkIdentity removes one of the user's logins, refusing to remove the
+// last. Unlinking the last email identity also drops the password.
+func (r *Repository) UnlinkIdentity(ctx context.Context, userID, identityID int) error {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var provider, subject string
+	err = tx.QueryRowContext(ctx, `SELECT provider, subject FROM user_identities WHERE id = $1 AND user_id = $2`, identityID, userID).Scan(&provider, &subject)
+	if err == sql.ErrNoRows {
+		return database.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_identities WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastIdentity
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_identities WHERE id = $1`, identityID); err != nil {
+		return err
+	}
+	switch provider {
+	case ProviderGoogle:
+		_, err = tx.ExecContext(ctx, `UPDATE users SET google_id = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND google_id = $2`, userID, subject)
+	case ProviderPhone:
+		_, err = tx.ExecContext(ctx, `UPDATE users SET phone_number = NULL, firebase_uid = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND firebase_uid = $2`, userID, subject)
+	case ProviderEmail:
+		// The password is shared by all of the user's addresses (a merge can
+		// leave more than one), so it goes with the last of them.
+		query := `UPDATE users SET password_hash = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM user_identities WHERE user_id = $1 AND provider = $2)`
+		_, err = tx.ExecContext(ctx, query, userID, ProviderEmail)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergeUsers folds duplicateID into primaryID in one transaction: all logged
+// data and every login move across, profile fields the primary has left
+// empty are filled from the duplicate, and the duplicate is deleted along
+// with its sessions and API tokens. afterMerge, if not nil, runs in the same
+// transaction once the data has moved, for work other domains derive from it.
+func (r *Repository) MergeUsers(ctx context.Context, primaryID, duplicateID int, afterMerge func(tx *sql.Tx) error) error {
+	if primaryID == duplicateID {
+		return errors.New("cannot merge an account into itself")
+	}
+
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Goals are one per start day; where both accounts have one, the
+	// primary's stands.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM nutrition_goals WHERE user_id = $1 AND effective_from IN (SELECT effective_from FROM nutrition_goals WHERE user_id = $2)`, duplicateID, primaryID); err != nil {
+		return err
+	}
+	for _, table := range append(userDataTables, "user_identities") {
+		if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET user_id = $1 WHERE user_id = $2`, primaryID, duplicateID); err != nil {
+			return err
+		}
+	}
+	for _, table := range libraryTables {
+		if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET created_by = $1 WHERE created_by = $2`, primaryID, duplicateID); err != nil {
+			return err
+		}
+	}
+
+	// Coaching grants move both ways; one between the two accounts
+	// themselves would now be a grant to oneself.
+	if _, err := tx.ExecContext(ctx, `UPDATE coach_grants SET user_id = $1 WHERE user_id = $2`, primaryID, duplicateID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE coach_grants SET coach_id = $1 WHERE coach_id = $2`, primaryID, duplicateID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM coach_grants WHERE coach_id = user_id`); err != nil {
+		return err
+	}
+
+	var dup struct {
+		email, googleID, phone, firebaseUID, passwordHash, dob, sex, activity, goal *string
+		verifiedAt                                                                  *time.Time
+		height                                                                      *float64
+	}
+	query := `SELECT email, email_verified_at, google_id, phone_number, firebase_uid, password_hash, height_cm, dob, sex, activity_level, weight_goal FROM users WHERE id = $1`
+	err = tx.QueryRowContext(ctx, query, duplicateID).Scan(&dup.email, &dup.verifiedAt, &dup.googleID, &dup.phone, &dup.firebaseUID, &dup.passwordHash, &dup.height, &dup.dob, &dup.sex, &dup.activity, &dup.goal)
+	if err == sql.ErrNoRows {
+		return database.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Free the unique columns before the primary takes them over.
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET email = NULL, google_id = NULL, phone_number = NULL, firebase_uid = NULL WHERE id = $1`, duplicateID); err != nil {
+		return err
+	}
+
+	// SET expressions all see the row as it was, so the CASE still tests the
+	// primary's original email.
+	query = `UPDATE users SET
+		email_verified_at = CASE WHEN email IS NULL THEN $1 ELSE email_verified_at END,
+		email = COALESCE(email, $2),
+		google_id = COALESCE(google_id, $3),
+		phone_number = COALESCE(phone_number, $4),
+		firebase_uid = COALESCE(firebase_uid, $5),
+		password_hash = COALESCE(password_hash, $6),
+		height_cm = COALESCE(height_cm, $7),
+		dob = COALESCE(dob, $8),
+		sex = COALESCE(sex, $9),
+		activity_level = COALESCE(activity_level, $10),
+		weight_goal = COALESCE(weight_goal, $11),
+		updated_at = CURRENT_TIMESTAMP
+		WHERE id = $12`
+	res, err := tx.ExecContext(ctx, query, dup.verifiedAt, dup.email, dup.googleID, dup.phone, dup.firebaseUID, dup.passwordHash, dup.height, dup.dob, dup.sex, dup.activity, dup.goal, primaryID)
+	if err := database.RequireAffected(res, err); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, duplicateID); err != nil {
+		return err
+	}
+	if afterMerge != nil {
+		if err := afterMerge(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ScheduleDeletion marks the account for purging once at has passed.
+func (r *Repository) ScheduleDeletion(ctx context.Context, userID int, at time.Time) error {
+	res, err := r.db.Pool.ExecContext(ctx, `UPDATE users SET delete_after = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, at.UTC(), userID)
+	return database.RequireAffected(res, err)
+}
+
+// CancelDeletion takes the account off the deletion schedule. It returns
+// database.ErrNotFound if no deletion was pending.
+func (r *Repository) CancelDeletion(ctx context.Context, userID int) error {
+	res, err := r.db.Pool.ExecContext(ctx, `UPDATE users SET delete_after = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND delete_after IS NOT NULL`, userID)
+	return database.RequireAffected(res, err)
+}
+
+// ListDueDeletions returns the accounts whose grace period is over, and the
+// demo accounts that are at least demoTTL old.
+func (r *Repository) ListDueDeletions(ctx context.Context, now time.Time, demoTTL time.Duration) ([]int, error) {
+	query := `SELECT id FROM users
+		WHERE (delete_after IS NOT NULL AND delete_after <= $1) OR (is_demo AND ` + r.db.Dialect.AddInterval("created_at", "$2", "second") + ` <= $1)
+		ORDER BY id`
+	rows, err := r.db.Pool.QueryContext(ctx, query, now.UTC(), int(demoTTL.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeUser deletes the user's logins, mailed tokens and finally the user
+// row itself inside tx. It has to run after every other domain has purged
+// its rows, or the foreign keys on users(id) refuse the delete.
+func (r *Repository) PurgeUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	for _, table := range []string{"user_identities", "email_tokens"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	return database.RequireAffected(res, err)
+}
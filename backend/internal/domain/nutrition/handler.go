@@ -2,7 +2,10 @@ package nutrition
 
 import (
 	"encoding/json"
+	"errors"
 	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/calendar"
+	"fitness-buddy/internal/database"
 	"net/http"
 	"strconv"
 	"time"
@@ -27,7 +30,168 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 	r.Delete("/meals/entries/{id}", h.DeleteFoodEntry)
 	r.Get("/nutrition/library", h.ListFoodLibrary)
 	r.Post("/nutrition/library", h.CreateFoodLibraryItem)
+	r.Get("/nutrition/water", h.GetWater)
 	r.Post("/nutrition/water", h.LogWater)
+	r.Get("/nutrition/goals", h.ListGoals)
+	r.Post("/nutrition/goals", h.SetGoal)
+	r.Delete("/nutrition/goals/{id}", h.DeleteGoal)
+	r.Get("/nutrition/progress", h.GetProgress)
+}
+
+// day reads ?date= as a local midnight in the user's timezone, today if
+// omitted. It answers 400 itself on a malformed date.
+func day(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	loc := calendar.Location(r.Context())
+	dateStr := r.URL.Query().Get("date")
+	if dateStr == "" {
+		return calendar.DayStart(time.Now(), loc), true
+	}
+	d, err := calendar.ParseDate(dateStr, loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return d, true
+}
+
+// GetWater returns the water logged on ?date=, today if omitted, counted in
+// the user's timezone.
+func (h *Handler) GetWater(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	d, ok := day(w, r)
+	if !ok {
+		return
+	}
+
+	total, err := h.repo.GetWaterForDay(r.Context(), userID, d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(WaterDay{Date: d.Format(calendar.DateLayout), AmountML: total})
+}
+
+func (h *Handler) ListGoals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	goals, err := h.repo.ListGoals(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(goals)
+}
+
+type SetGoalRequest struct {
+	// EffectiveFrom defaults to today in the user's timezone.
+	EffectiveFrom *string  `json:"effective_from"`
+	Calories      *int     `json:"calories"`
+	ProteinG      *float64 `json:"protein_g"`
+	CarbsG        *float64 `json:"carbs_g"`
+	FatG          *float64 `json:"fat_g"`
+	FiberG        *float64 `json:"fiber_g"`
+	WaterML       *int     `json:"water_ml"`
+}
+
+// SetGoal starts a new goal. Earlier goals are kept so past days are still
+// measured against what was in force then; one starting the same day is
+// replaced.
+func (h *Handler) SetGoal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	var req SetGoalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	loc := calendar.Location(r.Context())
+	from := calendar.DayStart(time.Now(), loc)
+	if req.EffectiveFrom != nil {
+		d, err := calendar.ParseDate(*req.EffectiveFrom, loc)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from = d
+	}
+	for _, v := range []*float64{req.ProteinG, req.CarbsG, req.FatG, req.FiberG} {
+		if v != nil && *v < 0 {
+			http.Error(w, "targets can't be negative", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, v := range []*int{req.Calories, req.WaterML} {
+		if v != nil && *v < 0 {
+			http.Error(w, "targets can't be negative", http.StatusBadRequest)
+			return
+		}
+	}
+
+	goal, err := h.repo.SetGoal(r.Context(), userID, Goal{
+		EffectiveFrom: from.Format(calendar.DateLayout),
+		Calories:      req.Calories,
+		ProteinG:      req.ProteinG,
+		CarbsG:        req.CarbsG,
+		FatG:          req.FatG,
+		FiberG:        req.FiberG,
+		WaterML:       req.WaterML,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(goal)
+}
+
+func (h *Handler) DeleteGoal(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid goal ID", http.StatusBadRequest)
+		return
+	}
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	err = h.repo.DeleteGoal(r.Context(), userID, id)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Goal not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetProgress returns consumed against target for ?date=, today if omitted,
+// counted in the user's timezone.
+func (h *Handler) GetProgress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	d, ok := day(w, r)
+	if !ok {
+		return
+	}
+
+	progress, err := h.repo.GetProgress(r.Context(), userID, d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(progress)
 }
 
 func (h *Handler) LogWater(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +202,10 @@ func (h *Handler) LogWater(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	if err := h.repo.LogWater(r.Context(), userID, req.Amount); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -62,7 +229,16 @@ func (h *Handler) UpdateMeal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.repo.UpdateMeal(r.Context(), id, req.Name); err != nil {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	err = h.repo.UpdateMeal(r.Context(), userID, id, req.Name)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Meal not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -76,7 +252,16 @@ func (h *Handler) DeleteMeal(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid meal ID", http.StatusBadRequest)
 		return
 	}
-	if err := h.repo.DeleteMeal(r.Context(), id); err != nil {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	err = h.repo.DeleteMeal(r.Context(), userID, id)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Meal not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -90,7 +275,16 @@ func (h *Handler) DeleteFoodEntry(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid entry ID", http.StatusBadRequest)
 		return
 	}
-	if err := h.repo.DeleteFoodEntry(r.Context(), id); err != nil {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	err = h.repo.DeleteFoodEntry(r.Context(), userID, id)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -98,7 +292,10 @@ func (h *Handler) DeleteFoodEntry(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ListMeals(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	meals, err := h.repo.ListMeals(r.Context(), userID, 20)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -129,7 +326,10 @@ func (h *Handler) CreateMeal(w http.ResponseWriter, r *http.Request) {
 		req.EatenAt = time.Now()
 	}
 
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	m, err := h.repo.CreateMeal(r.Context(), userID, req.Name, req.EatenAt)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -144,6 +344,7 @@ type AddFoodEntryRequest struct {
 	ProteinG float64 `json:"protein_g"`
 	CarbsG   float64 `json:"carbs_g"`
 	FatG     float64 `json:"fat_g"`
+	FiberG   float64 `json:"fiber_g"`
 	Quantity *string `json:"quantity"`
 }
 
@@ -161,7 +362,15 @@ func (h *Handler) AddFoodEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fe, err := h.repo.AddFoodEntry(r.Context(), mealID, req.Name, req.Calories, req.ProteinG, req.CarbsG, req.FatG, req.Quantity)
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	fe, err := h.repo.AddFoodEntry(r.Context(), userID, mealID, req.Name, req.Calories, req.ProteinG, req.CarbsG, req.FatG, req.FiberG, req.Quantity)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Meal not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -179,12 +388,16 @@ func (h *Handler) ListFoodLibrary(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) CreateFoodLibraryItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	var item FoodLibraryItem
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	newItem, err := h.repo.CreateFoodLibraryItem(r.Context(), item)
+	newItem, err := h.repo.CreateFoodLibraryItem(r.Context(), userID, item)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
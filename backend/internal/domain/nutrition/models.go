@@ -23,6 +23,7 @@ type FoodEntry struct {
 	ProteinG  float64   `json:"protein_g"`
 	CarbsG    float64   `json:"carbs_g"`
 	FatG      float64   `json:"fat_g"`
+	FiberG    float64   `json:"fiber_g"`
 	Quantity  *string   `json:"quantity"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -43,3 +44,45 @@ type WaterLog struct {
     AmountML   int       `json:"amount_ml"`
     RecordedAt time.Time `json:"recorded_at"`
 }
+
+// WaterDay is the water logged on one day of the user's calendar.
+type WaterDay struct {
+	Date     string `json:"date"` // YYYY-MM-DD
+	AmountML int    `json:"amount_ml"`
+}
+
+// Goal holds daily targets from EffectiveFrom until the user's next goal.
+// Nil targets aren't tracked.
+type Goal struct {
+	ID            int       `json:"id"`
+	EffectiveFrom string    `json:"effective_from"` // YYYY-MM-DD
+	Calories      *int      `json:"calories"`
+	ProteinG      *float64  `json:"protein_g"`
+	CarbsG        *float64  `json:"carbs_g"`
+	FatG          *float64  `json:"fat_g"`
+	FiberG        *float64  `json:"fiber_g"`
+	WaterML       *int      `json:"water_ml"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Progress compares what was consumed against a target. Target and
+// Remaining are nil when the goal doesn't set one; Remaining goes negative
+// once the target is exceeded.
+type Progress struct {
+	Consumed  float64  `json:"consumed"`
+	Target    *float64 `json:"target"`
+	Remaining *float64 `json:"remaining"`
+}
+
+// DailyProgress is one day of the user's calendar against the goal in force
+// that day, nil if they had none yet.
+type DailyProgress struct {
+	Date     string   `json:"date"` // YYYY-MM-DD
+	Goal     *Goal    `json:"goal"`
+	Calories Progress `json:"calories"`
+	ProteinG Progress `json:"protein_g"`
+	CarbsG   Progress `json:"carbs_g"`
+	FatG     Progress `json:"fat_g"`
+	FiberG   Progress `json:"fiber_g"`
+	WaterML  Progress `json:"water_ml"`
+}
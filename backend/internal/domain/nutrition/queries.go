@@ -2,6 +2,9 @@ package nutrition
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fitness-buddy/internal/calendar"
 	"fitness-buddy/internal/database"
 	"time"
 )
@@ -27,10 +30,14 @@ func (r *Repository) CreateMeal(ctx context.Context, userID int, name *string, e
 	return &m, nil
 }
 
-func (r *Repository) AddFoodEntry(ctx context.Context, mealID int, name string, cals int, p, c, f float64, qty *string) (*FoodEntry, error) {
+func (r *Repository) AddFoodEntry(ctx context.Context, userID, mealID int, name string, cals int, p, c, f, fiber float64, qty *string) (*FoodEntry, error) {
+	if err := r.requireMeal(ctx, userID, mealID); err != nil {
+		return nil, err
+	}
+
 	query := `
-        INSERT INTO food_entries (meal_id, name, calories, protein_g, carbs_g, fat_g, quantity)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        INSERT INTO food_entries (meal_id, name, calories, protein_g, carbs_g, fat_g, fiber_g, quantity)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
         RETURNING id, created_at
     `
 	var fe FoodEntry
@@ -40,8 +47,9 @@ func (r *Repository) AddFoodEntry(ctx context.Context, mealID int, name string,
 	fe.ProteinG = p
 	fe.CarbsG = c
 	fe.FatG = f
+	fe.FiberG = fiber
 	fe.Quantity = qty
-	err := r.db.Pool.QueryRowContext(ctx, query, mealID, name, cals, p, c, f, qty).Scan(&fe.ID, &fe.CreatedAt)
+	err := r.db.Pool.QueryRowContext(ctx, query, mealID, name, cals, p, c, f, fiber, qty).Scan(&fe.ID, &fe.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -79,7 +87,7 @@ func (r *Repository) ListMeals(ctx context.Context, userID, limit int) ([]Meal,
 
 func (r *Repository) GetEntriesForMeal(ctx context.Context, mealID int) ([]FoodEntry, error) {
 	query := `
-        SELECT id, meal_id, name, calories, protein_g, carbs_g, fat_g, quantity, created_at
+        SELECT id, meal_id, name, calories, protein_g, carbs_g, fat_g, fiber_g, quantity, created_at
         FROM food_entries
         WHERE meal_id = $1
         ORDER BY id ASC
@@ -93,7 +101,7 @@ func (r *Repository) GetEntriesForMeal(ctx context.Context, mealID int) ([]FoodE
 	entries := []FoodEntry{}
 	for rows.Next() {
 		var fe FoodEntry
-		if err := rows.Scan(&fe.ID, &fe.MealID, &fe.Name, &fe.Calories, &fe.ProteinG, &fe.CarbsG, &fe.FatG, &fe.Quantity, &fe.CreatedAt); err != nil {
+		if err := rows.Scan(&fe.ID, &fe.MealID, &fe.Name, &fe.Calories, &fe.ProteinG, &fe.CarbsG, &fe.FatG, &fe.FiberG, &fe.Quantity, &fe.CreatedAt); err != nil {
 			return nil, err
 		}
 		entries = append(entries, fe)
@@ -104,19 +112,28 @@ func (r *Repository) GetEntriesForMeal(ctx context.Context, mealID int) ([]FoodE
 	return entries, nil
 }
 
-func (r *Repository) DeleteMeal(ctx context.Context, mealID int) error {
-	_, err := r.db.Pool.ExecContext(ctx, "DELETE FROM meals WHERE id = $1", mealID)
-	return err
+func (r *Repository) DeleteMeal(ctx context.Context, userID, mealID int) error {
+	return database.RequireAffected(r.db.Pool.ExecContext(ctx, "DELETE FROM meals WHERE id = $1 AND user_id = $2", mealID, userID))
 }
 
-func (r *Repository) DeleteFoodEntry(ctx context.Context, entryID int) error {
-	_, err := r.db.Pool.ExecContext(ctx, "DELETE FROM food_entries WHERE id = $1", entryID)
-	return err
+func (r *Repository) DeleteFoodEntry(ctx context.Context, userID, entryID int) error {
+	query := `DELETE FROM food_entries WHERE id = $1 AND meal_id IN (SELECT id FROM meals WHERE user_id = $2)`
+	return database.RequireAffected(r.db.Pool.ExecContext(ctx, query, entryID, userID))
 }
 
-func (r *Repository) UpdateMeal(ctx context.Context, mealID int, name string) error {
-	_, err := r.db.Pool.ExecContext(ctx, "UPDATE meals SET name = $1 WHERE id = $2", name, mealID)
-	return err
+func (r *Repository) UpdateMeal(ctx context.Context, userID, mealID int, name string) error {
+	return database.RequireAffected(r.db.Pool.ExecContext(ctx, "UPDATE meals SET name = $1 WHERE id = $2 AND user_id = $3", name, mealID, userID))
+}
+
+func (r *Repository) requireMeal(ctx context.Context, userID, mealID int) error {
+	var count int
+	if err := r.db.Pool.QueryRowContext(ctx, "SELECT COUNT(*) FROM meals WHERE id = $1 AND user_id = $2", mealID, userID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrNotFound
+	}
+	return nil
 }
 
 func (r *Repository) ListFoodLibrary(ctx context.Context) ([]FoodLibraryItem, error) {
@@ -141,9 +158,9 @@ func (r *Repository) ListFoodLibrary(ctx context.Context) ([]FoodLibraryItem, er
 	return items, nil
 }
 
-func (r *Repository) CreateFoodLibraryItem(ctx context.Context, item FoodLibraryItem) (*FoodLibraryItem, error) {
-	query := `INSERT INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	err := r.db.Pool.QueryRowContext(ctx, query, item.Name, item.CaloriesPer100g, item.ProteinPer100g, item.CarbsPer100g, item.FatPer100g).Scan(&item.ID)
+func (r *Repository) CreateFoodLibraryItem(ctx context.Context, userID int, item FoodLibraryItem) (*FoodLibraryItem, error) {
+	query := `INSERT INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g, created_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err := r.db.Pool.QueryRowContext(ctx, query, item.Name, item.CaloriesPer100g, item.ProteinPer100g, item.CarbsPer100g, item.FatPer100g, userID).Scan(&item.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -155,8 +172,186 @@ func (r *Repository) LogWater(ctx context.Context, userID int, amount int) error
 	return err
 }
 
-func (r *Repository) GetWaterForDay(ctx context.Context, userID int, day string) (int, error) {
-	var total int
-	err := r.db.Pool.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount_ml), 0) FROM water_logs WHERE user_id = $1 AND date(recorded_at) = $2", userID, day).Scan(&total)
-	return total, err
+// GetWaterForDay totals the water logged on the local day starting at day,
+// a midnight from calendar.DayStart. The query takes a day either side,
+// since SQLite compares timestamps stored with different offsets as text,
+// and the exact cut is made here.
+func (r *Repository) GetWaterForDay(ctx context.Context, userID int, day time.Time) (int, error) {
+	next := calendar.NextDay(day)
+	query := `SELECT recorded_at, amount_ml FROM water_logs WHERE user_id = $1 AND recorded_at >= $2 AND recorded_at < $3`
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID, day.AddDate(0, 0, -1), next.AddDate(0, 0, 1))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	for rows.Next() {
+		var recordedAt time.Time
+		var amount int
+		if err := rows.Scan(&recordedAt, &amount); err != nil {
+			return 0, err
+		}
+		if !recordedAt.Before(day) && recordedAt.Before(next) {
+			total += amount
+		}
+	}
+	return total, rows.Err()
+}
+
+const goalColumns = `id, effective_from, calories, protein_g, carbs_g, fat_g, fiber_g, water_ml, created_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanGoal(row scanner) (*Goal, error) {
+	var g Goal
+	err := row.Scan(&g.ID, &g.EffectiveFrom, &g.Calories, &g.ProteinG, &g.CarbsG, &g.FatG, &g.FiberG, &g.WaterML, &g.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// ListGoals returns the user's goal history, newest first.
+func (r *Repository) ListGoals(ctx context.Context, userID int) ([]Goal, error) {
+	rows, err := r.db.Pool.QueryContext(ctx, `SELECT `+goalColumns+` FROM nutrition_goals WHERE user_id = $1 ORDER BY effective_from DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	goals := []Goal{}
+	for rows.Next() {
+		g, err := scanGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, *g)
+	}
+	return goals, rows.Err()
+}
+
+// SetGoal saves g as the goal starting on g.EffectiveFrom, replacing any goal
+// that already starts that day.
+func (r *Repository) SetGoal(ctx context.Context, userID int, g Goal) (*Goal, error) {
+	query := `INSERT INTO nutrition_goals (user_id, effective_from, calories, protein_g, carbs_g, fat_g, fiber_g, water_ml)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ` +
+		r.db.Dialect.Upsert([]string{"user_id", "effective_from"}, "calories", "protein_g", "carbs_g", "fat_g", "fiber_g", "water_ml") + `
+		RETURNING ` + goalColumns
+	return scanGoal(r.db.Pool.QueryRowContext(ctx, query, userID, g.EffectiveFrom, g.Calories, g.ProteinG, g.CarbsG, g.FatG, g.FiberG, g.WaterML))
+}
+
+func (r *Repository) DeleteGoal(ctx context.Context, userID, goalID int) error {
+	return database.RequireAffected(r.db.Pool.ExecContext(ctx, `DELETE FROM nutrition_goals WHERE id = $1 AND user_id = $2`, goalID, userID))
+}
+
+// GoalForDay returns the goal in force on day, a YYYY-MM-DD date, or
+// database.ErrNotFound if the user had none yet.
+func (r *Repository) GoalForDay(ctx context.Context, userID int, day string) (*Goal, error) {
+	query := `SELECT ` + goalColumns + ` FROM nutrition_goals WHERE user_id = $1 AND effective_from <= $2 ORDER BY effective_from DESC LIMIT 1`
+	return scanGoal(r.db.Pool.QueryRowContext(ctx, query, userID, day))
+}
+
+// GetProgress totals what was eaten and drunk on the local day starting at
+// day and sets it against that day's goal. Meals are fetched a day either
+// side for the same reason as in GetWaterForDay.
+func (r *Repository) GetProgress(ctx context.Context, userID int, day time.Time) (*DailyProgress, error) {
+	date := day.Format(calendar.DateLayout)
+	p := &DailyProgress{Date: date}
+
+	goal, err := r.GoalForDay(ctx, userID, date)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+	p.Goal = goal
+
+	next := calendar.NextDay(day)
+	query := `
+        SELECT m.eaten_at, fe.calories, fe.protein_g, fe.carbs_g, fe.fat_g, fe.fiber_g
+        FROM meals m
+        JOIN food_entries fe ON m.id = fe.meal_id
+        WHERE m.user_id = $1 AND m.eaten_at >= $2 AND m.eaten_at < $3
+    `
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID, day.AddDate(0, 0, -1), next.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eatenAt time.Time
+		var calories int
+		var protein, carbs, fat, fiber float64
+		if err := rows.Scan(&eatenAt, &calories, &protein, &carbs, &fat, &fiber); err != nil {
+			return nil, err
+		}
+		if eatenAt.Before(day) || !eatenAt.Before(next) {
+			continue
+		}
+		p.Calories.Consumed += float64(calories)
+		p.ProteinG.Consumed += protein
+		p.CarbsG.Consumed += carbs
+		p.FatG.Consumed += fat
+		p.FiberG.Consumed += fiber
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	water, err := r.GetWaterForDay(ctx, userID, day)
+	if err != nil {
+		return nil, err
+	}
+	p.WaterML.Consumed = float64(water)
+
+	if goal != nil {
+		p.Calories.against(intTarget(goal.Calories))
+		p.ProteinG.against(goal.ProteinG)
+		p.CarbsG.against(goal.CarbsG)
+		p.FatG.against(goal.FatG)
+		p.FiberG.against(goal.FiberG)
+		p.WaterML.against(intTarget(goal.WaterML))
+	}
+	return p, nil
+}
+
+func (p *Progress) against(target *float64) {
+	if target == nil {
+		return
+	}
+	remaining := *target - p.Consumed
+	p.Target = target
+	p.Remaining = &remaining
+}
+
+func intTarget(v *int) *float64 {
+	if v == nil {
+		return nil
+	}
+	f := float64(*v)
+	return &f
+}
+
+// PurgeUser deletes the user's meals, water logs and goals inside tx, as part
+// of deleting the account. Food entries cascade with their meals. Custom
+// foods stay in the shared library but lose their owner.
+func (r *Repository) PurgeUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM meals WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM water_logs WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM nutrition_goals WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE food_library SET created_by = NULL WHERE created_by = $1`, userID); err != nil {
+		return err
+	}
+	return nil
 }
@@ -2,11 +2,16 @@ package resistance
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/calendar"
+	"fitness-buddy/internal/database"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -22,6 +27,9 @@ func NewHandler(repo *Repository) *Handler {
 func (h *Handler) RegisterRoutes(r chi.Router) {
 	r.Get("/exercises", h.ListExercises)
 	r.Post("/exercises", h.CreateExercise)
+	r.Get("/exercises/{id}/records", h.ListRecords)
+	r.Get("/exercises/{id}/history", h.GetExerciseHistory)
+	r.Get("/exercises/{id}/next", h.GetNextSets)
 	r.Get("/sessions", h.ListSessions)
 	r.Post("/sessions", h.CreateSession)
 	r.Post("/sessions/{id}/finish", h.FinishSession)
@@ -30,6 +38,7 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 	r.Delete("/sets/{id}", h.DeleteSet)
 	r.Get("/routines", h.ListRoutines)
 	r.Post("/routines", h.CreateRoutine)
+	r.Post("/routines/{id}/start", h.StartRoutine)
 	r.Delete("/routines/{id}", h.DeleteRoutine)
 	r.Delete("/sessions/{id}", h.DeleteSession)
 }
@@ -41,7 +50,16 @@ func (h *Handler) DeleteRoutine(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid routine ID", http.StatusBadRequest)
 		return
 	}
-	if err := h.repo.DeleteRoutine(r.Context(), id); err != nil {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	err = h.repo.DeleteRoutine(r.Context(), userID, id)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -55,7 +73,16 @@ func (h *Handler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid session ID", http.StatusBadRequest)
 		return
 	}
-	if err := h.repo.DeleteSession(r.Context(), id); err != nil {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	err = h.repo.DeleteSession(r.Context(), userID, id)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -78,12 +105,16 @@ type CreateExerciseRequest struct {
 }
 
 func (h *Handler) CreateExercise(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	var req CreateExerciseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	e, err := h.repo.CreateExercise(r.Context(), req.Name, req.Category, req.Equipment)
+	e, err := h.repo.CreateExercise(r.Context(), userID, req.Name, req.Category, req.Equipment)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -92,7 +123,10 @@ func (h *Handler) CreateExercise(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	sessions, err := h.repo.ListSessions(r.Context(), userID, 20)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -124,7 +158,10 @@ func (h *Handler) CreateSession(w http.ResponseWriter, r *http.Request) {
 		req.StartTime = time.Now()
 	}
 
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	s, err := h.repo.CreateSession(r.Context(), userID, req.StartTime, req.Notes)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -153,11 +190,146 @@ func (h *Handler) FinishSession(w http.ResponseWriter, r *http.Request) {
 		req.EndTime = time.Now()
 	}
 
-	if err := h.repo.FinishSession(r.Context(), id, req.EndTime); err != nil {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	summary, err := h.repo.FinishSession(r.Context(), userID, id, req.EndTime)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summary)
+}
+
+// ListRecords returns the caller's standing personal records for an
+// exercise.
+func (h *Handler) ListRecords(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	records, err := h.repo.ListRecords(r.Context(), userID, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(records)
+}
+
+// GetExerciseHistory returns every set of an exercise grouped by session.
+// ?start= and ?end= bound the days sessions started on, in the user's
+// timezone; end defaults to today and start to the first session. ?limit=
+// (default 20, at most 100) and ?offset= page through the sessions.
+func (h *Handler) GetExerciseHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	loc := calendar.Location(r.Context())
+	var start time.Time
+	end := calendar.DayStart(time.Now(), loc)
+	if s := q.Get("start"); s != "" {
+		if start, err = calendar.ParseDate(s, loc); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if s := q.Get("end"); s != "" {
+		if end, err = calendar.ParseDate(s, loc); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if !start.IsZero() && end.Before(start) {
+		http.Error(w, "end is before start", http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := 20, 0
+	if s := q.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := q.Get("offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	history, err := h.repo.ExerciseHistory(r.Context(), userID, id, start, end, loc, limit, offset)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Exercise not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(history)
+}
+
+// GetNextSets recommends today's weight and reps for an exercise.
+// ?strategy= is one of the Progression constants, auto by default, and
+// ?routine_id= takes the rep range and target RPE from a routine.
+func (h *Handler) GetNextSets(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+	strategy := ProgressionAuto
+	if s := r.URL.Query().Get("strategy"); s != "" {
+		if !ValidProgression(s) {
+			http.Error(w, fmt.Sprintf("invalid strategy %q", s), http.StatusBadRequest)
+			return
+		}
+		strategy = s
+	}
+	routineID := 0
+	if s := r.URL.Query().Get("routine_id"); s != "" {
+		if routineID, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "Invalid routine ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	rec, err := h.repo.NextSets(r.Context(), userID, id, routineID, strategy)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Exercise or routine not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(rec)
 }
 
 type AddSetRequest struct {
@@ -166,6 +338,106 @@ type AddSetRequest struct {
 	Reps        int        `json:"reps"`
 	RPE         *float64   `json:"rpe"`
 	PerformedAt *time.Time `json:"performed_at"`
+	SetDetails
+}
+
+// details validates the request's set and its details. set_type defaults to
+// normal.
+func (req *AddSetRequest) details() (SetDetails, error) {
+	if err := validateSet(req.WeightKG, req.Reps); err != nil {
+		return req.SetDetails, err
+	}
+	d := req.SetDetails
+	if d.SetType == "" {
+		d.SetType = SetTypeNormal
+	}
+	return validateDetails(d)
+}
+
+// UpdateSetRequest is AddSetRequest with the set details optional: a detail
+// left out keeps its stored value, and superset_group, rir or tempo sent as
+// null clears it.
+type UpdateSetRequest struct {
+	WeightKG      float64          `json:"weight_kg"`
+	Reps          int              `json:"reps"`
+	RPE           *float64         `json:"rpe"`
+	PerformedAt   *time.Time       `json:"performed_at"`
+	SetType       *string          `json:"set_type"`
+	SupersetGroup nullable[int]    `json:"superset_group"`
+	RIR           nullable[int]    `json:"rir"`
+	Tempo         nullable[string] `json:"tempo"`
+}
+
+// nullable tells a JSON null apart from a missing field: Present is set
+// whenever the field is in the document, Value only when it isn't null.
+type nullable[T any] struct {
+	Present bool
+	Value   *T
+}
+
+func (n *nullable[T]) UnmarshalJSON(b []byte) error {
+	n.Present = true
+	return json.Unmarshal(b, &n.Value)
+}
+
+// cleared reports whether the field was sent as null.
+func (n nullable[T]) cleared() bool {
+	return n.Present && n.Value == nil
+}
+
+// details validates the request's set and its details. SetType is empty when
+// the request leaves it out.
+func (req *UpdateSetRequest) details() (SetDetailsUpdate, error) {
+	d := SetDetailsUpdate{
+		SetDetails:         SetDetails{SupersetGroup: req.SupersetGroup.Value, RIR: req.RIR.Value, Tempo: req.Tempo.Value},
+		ClearSupersetGroup: req.SupersetGroup.cleared(),
+		ClearRIR:           req.RIR.cleared(),
+		ClearTempo:         req.Tempo.cleared(),
+	}
+	if err := validateSet(req.WeightKG, req.Reps); err != nil {
+		return d, err
+	}
+	if req.SetType != nil {
+		d.SetType = *req.SetType
+		if d.SetType == "" {
+			return d, errors.New("set_type must not be empty")
+		}
+	}
+	var err error
+	d.SetDetails, err = validateDetails(d.SetDetails)
+	return d, err
+}
+
+func validateSet(weightKG float64, reps int) error {
+	if reps < 1 {
+		return errors.New("reps must be at least 1")
+	}
+	if weightKG < 0 {
+		return errors.New("weight_kg must not be negative")
+	}
+	return nil
+}
+
+// validateDetails checks set details and normalizes the tempo. An empty
+// SetType is left for the caller to fill in or keep.
+func validateDetails(d SetDetails) (SetDetails, error) {
+	if d.SetType != "" && !ValidSetType(d.SetType) {
+		return d, fmt.Errorf("invalid set_type %q", d.SetType)
+	}
+	if d.SupersetGroup != nil && *d.SupersetGroup < 1 {
+		return d, errors.New("superset_group must be at least 1")
+	}
+	if d.RIR != nil && (*d.RIR < 0 || *d.RIR > 10) {
+		return d, errors.New("rir must be between 0 and 10")
+	}
+	if d.Tempo != nil {
+		tempo := strings.ToUpper(strings.ReplaceAll(*d.Tempo, "-", ""))
+		if len(tempo) != 4 || strings.Trim(tempo, "0123456789X") != "" {
+			return d, fmt.Errorf("invalid tempo %q, want four digits or X such as 31X0", *d.Tempo)
+		}
+		d.Tempo = &tempo
+	}
+	return d, nil
 }
 
 func (h *Handler) AddSet(w http.ResponseWriter, r *http.Request) {
@@ -182,12 +454,26 @@ func (h *Handler) AddSet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	details, err := req.details()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	performedAt := time.Now()
 	if req.PerformedAt != nil {
 		performedAt = *req.PerformedAt
 	}
 
-	s, err := h.repo.AddSet(r.Context(), sessionID, req.ExerciseID, req.WeightKG, req.Reps, req.RPE, performedAt)
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	s, err := h.repo.AddSet(r.Context(), userID, sessionID, req.ExerciseID, req.WeightKG, req.Reps, req.RPE, details, performedAt)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -203,17 +489,35 @@ func (h *Handler) UpdateSet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req AddSetRequest // Reuse struct
+	var req UpdateSetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	details, err := req.details()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if err := h.repo.UpdateSet(r.Context(), id, req.WeightKG, req.Reps, req.RPE); err != nil {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	performedAt := time.Now()
+	if req.PerformedAt != nil {
+		performedAt = *req.PerformedAt
+	}
+	s, err := h.repo.UpdateSet(r.Context(), userID, id, req.WeightKG, req.Reps, req.RPE, details, performedAt)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s)
 }
 
 func (h *Handler) DeleteSet(w http.ResponseWriter, r *http.Request) {
@@ -224,7 +528,16 @@ func (h *Handler) DeleteSet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.repo.DeleteSet(r.Context(), id); err != nil {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	err = h.repo.DeleteSet(r.Context(), userID, id)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Set not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -232,7 +545,10 @@ func (h *Handler) DeleteSet(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ListRoutines(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	routines, err := h.repo.ListRoutines(r.Context(), userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -241,10 +557,13 @@ func (h *Handler) ListRoutines(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(routines)
 }
 
+// CreateRoutineRequest takes either exercise_ids, for a plain list, or
+// exercises with targets.
 type CreateRoutineRequest struct {
-	Name        string  `json:"name"`
-	Notes       *string `json:"notes"`
-	ExerciseIDs []int   `json:"exercise_ids"`
+	Name        string            `json:"name"`
+	Notes       *string           `json:"notes"`
+	ExerciseIDs []int             `json:"exercise_ids"`
+	Exercises   []RoutineExercise `json:"exercises"`
 }
 
 func (h *Handler) CreateRoutine(w http.ResponseWriter, r *http.Request) {
@@ -254,11 +573,86 @@ func (h *Handler) CreateRoutine(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := auth.GetUserID(r.Context())
-	rt, err := h.repo.CreateRoutine(r.Context(), userID, req.Name, req.Notes, req.ExerciseIDs)
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	exercises := req.Exercises
+	if len(exercises) == 0 {
+		for _, id := range req.ExerciseIDs {
+			exercises = append(exercises, RoutineExercise{ExerciseID: id})
+		}
+	}
+	for _, re := range exercises {
+		if err := validateTargets(re); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	rt, err := h.repo.CreateRoutine(r.Context(), userID, req.Name, req.Notes, exercises)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(rt)
 }
+
+func validateTargets(re RoutineExercise) error {
+	if re.TargetSets != nil && *re.TargetSets < 1 {
+		return errors.New("target_sets must be at least 1")
+	}
+	if re.RepRangeMin != nil && *re.RepRangeMin < 1 || re.RepRangeMax != nil && *re.RepRangeMax < 1 {
+		return errors.New("rep range must be at least 1")
+	}
+	if re.RepRangeMin != nil && re.RepRangeMax != nil && *re.RepRangeMin > *re.RepRangeMax {
+		return errors.New("rep_range_min is above rep_range_max")
+	}
+	if re.TargetRPE != nil && (*re.TargetRPE < 1 || *re.TargetRPE > 10) {
+		return errors.New("target_rpe must be between 1 and 10")
+	}
+	if re.RestSeconds != nil && *re.RestSeconds < 0 {
+		return errors.New("rest_seconds can't be negative")
+	}
+	if re.Progression != nil && (*re.Progression == ProgressionAuto || !ValidProgression(*re.Progression)) {
+		return fmt.Errorf("invalid progression %q", *re.Progression)
+	}
+	return nil
+}
+
+type StartRoutineRequest struct {
+	StartTime time.Time `json:"start_time"`
+}
+
+// StartRoutine starts a session from a routine with its sets planned. Sets
+// are completed by updating them with what was actually lifted.
+func (h *Handler) StartRoutine(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid routine ID", http.StatusBadRequest)
+		return
+	}
+
+	var req StartRoutineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		req.StartTime = time.Now()
+	}
+	if req.StartTime.IsZero() {
+		req.StartTime = time.Now()
+	}
+
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	start, err := h.repo.StartRoutine(r.Context(), userID, id, req.StartTime)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(start)
+}
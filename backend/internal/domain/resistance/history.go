@@ -0,0 +1,213 @@
+package resistance
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"fitness-buddy/internal/calendar"
+	"fitness-buddy/internal/database"
+)
+
+// ExerciseHistory is every set of one exercise in a date range, grouped by
+// session, newest session first. Sessions are paged; Trend always covers the
+// whole range so a chart doesn't depend on which page is showing.
+type ExerciseHistory struct {
+	ExerciseID   int    `json:"exercise_id"`
+	ExerciseName string `json:"exercise_name"`
+
+	// Start is omitted when the range is open, reaching back to the first
+	// session.
+	Start         string           `json:"start,omitempty"`
+	End           string           `json:"end"`
+	TotalSessions int              `json:"total_sessions"`
+	Limit         int              `json:"limit"`
+	Offset        int              `json:"offset"`
+	Sessions      []SessionHistory `json:"sessions"`
+
+	// Trend has one point per session, oldest first.
+	Trend []TrendPoint `json:"trend"`
+}
+
+// SessionHistory is one session's sets of the exercise and what they add up
+// to, warm-ups aside. TopSet is the heaviest set, the one with more reps on
+// a tie; E1RMKG is the best estimate from any set, which need not be the top
+// set.
+type SessionHistory struct {
+	SessionID int          `json:"session_id"`
+	StartTime time.Time    `json:"start_time"`
+	Date      string       `json:"date"`
+	Sets      []WorkoutSet `json:"sets"`
+	TopSet    *WorkoutSet  `json:"top_set"`
+	E1RMKG    float64      `json:"e1rm_kg"`
+	VolumeKG  float64      `json:"volume_kg"`
+}
+
+type TrendPoint struct {
+	Date        string  `json:"date"`
+	SessionID   int     `json:"session_id"`
+	TopWeightKG float64 `json:"top_weight_kg"`
+	E1RMKG      float64 `json:"e1rm_kg"`
+	VolumeKG    float64 `json:"volume_kg"`
+}
+
+// ExerciseHistory returns the user's completed sets of an exercise from
+// sessions that started on the days start to end inclusive, both local
+// midnights in loc; a zero start leaves the range open at the beginning. The
+// query is widened by a day either side and cut here, as SQLite compares
+// timestamps stored with different offsets as text.
+func (r *Repository) ExerciseHistory(ctx context.Context, userID, exerciseID int, start, end time.Time, loc *time.Location, limit, offset int) (*ExerciseHistory, error) {
+	h := ExerciseHistory{
+		ExerciseID: exerciseID,
+		End:        end.Format(calendar.DateLayout),
+		Limit:      limit,
+		Offset:     offset,
+		Sessions:   []SessionHistory{},
+		Trend:      []TrendPoint{},
+	}
+	from := start
+	if !start.IsZero() {
+		h.Start = start.Format(calendar.DateLayout)
+		from = start.AddDate(0, 0, -1)
+	}
+	to := calendar.NextDay(end).AddDate(0, 0, 1)
+
+	err := r.db.Pool.QueryRowContext(ctx, `SELECT name FROM exercises WHERE id = $1`, exerciseID).Scan(&h.ExerciseName)
+	if err == sql.ErrNoRows {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT ws.id, ws.session_id, ws.set_order, ws.weight_kg, ws.reps, ws.rpe, ws.performed_at, ws.created_at, ws.set_type, ws.superset_group, ws.rir, ws.tempo, s.start_time
+        FROM workout_sets ws
+        JOIN workout_sessions s ON s.id = ws.session_id
+        WHERE s.user_id = $1 AND ws.exercise_id = $2 AND ws.status = $3 AND s.start_time >= $4 AND s.start_time < $5
+    `
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID, exerciseID, SetCompleted, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := map[int]*SessionHistory{}
+	for rows.Next() {
+		var s WorkoutSet
+		var startTime time.Time
+		if err := rows.Scan(&s.ID, &s.SessionID, &s.SetOrder, &s.WeightKG, &s.Reps, &s.RPE, &s.PerformedAt, &s.CreatedAt, &s.SetType, &s.SupersetGroup, &s.RIR, &s.Tempo, &startTime); err != nil {
+			return nil, err
+		}
+		if startTime.Before(start) || !startTime.Before(calendar.NextDay(end)) {
+			continue
+		}
+		s.ExerciseID = exerciseID
+		s.ExerciseName = h.ExerciseName
+		s.Status = SetCompleted
+		sh, ok := sessions[s.SessionID]
+		if !ok {
+			sh = &SessionHistory{SessionID: s.SessionID, StartTime: startTime, Date: calendar.Date(startTime, loc)}
+			sessions[s.SessionID] = sh
+		}
+		sh.Sets = append(sh.Sets, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	all := make([]SessionHistory, 0, len(sessions))
+	for _, sh := range sessions {
+		summarizeSession(sh)
+		all = append(all, *sh)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].StartTime.Equal(all[j].StartTime) {
+			return all[i].StartTime.After(all[j].StartTime)
+		}
+		return all[i].SessionID > all[j].SessionID
+	})
+
+	h.TotalSessions = len(all)
+	for i := len(all) - 1; i >= 0; i-- {
+		sh := all[i]
+		p := TrendPoint{Date: sh.Date, SessionID: sh.SessionID, E1RMKG: sh.E1RMKG, VolumeKG: sh.VolumeKG}
+		if sh.TopSet != nil {
+			p.TopWeightKG = sh.TopSet.WeightKG
+		}
+		h.Trend = append(h.Trend, p)
+	}
+	if offset < len(all) {
+		h.Sessions = all[offset:min(offset+limit, len(all))]
+	}
+	return &h, nil
+}
+
+// summarizeSession orders a session's sets and works out its top set, best
+// e1RM and volume from all but the warm-ups.
+func summarizeSession(sh *SessionHistory) {
+	sort.Slice(sh.Sets, func(i, j int) bool {
+		return sh.Sets[i].SetOrder < sh.Sets[j].SetOrder
+	})
+	for i := range sh.Sets {
+		s := &sh.Sets[i]
+		if s.SetType == SetTypeWarmup {
+			continue
+		}
+		sh.VolumeKG += s.WeightKG * float64(s.Reps)
+		sh.E1RMKG = max(sh.E1RMKG, E1RM(s.WeightKG, s.Reps))
+		if sh.TopSet == nil || s.WeightKG > sh.TopSet.WeightKG || (s.WeightKG == sh.TopSet.WeightKG && s.Reps > sh.TopSet.Reps) {
+			sh.TopSet = s
+		}
+	}
+}
+
+// lastPerformance returns the completed working sets of an exercise, so not
+// warm-ups, from the most recent session the user did it in, other than
+// excludeSessionID, in set order. It is empty if the exercise has never been
+// done.
+func lastPerformance(ctx context.Context, q queryExecer, userID, exerciseID, excludeSessionID int) ([]WorkoutSet, error) {
+	query := `
+        SELECT ws.id, ws.session_id, ws.set_order, ws.weight_kg, ws.reps, ws.rpe, ws.performed_at, ws.created_at, ws.set_type, ws.superset_group, ws.rir, ws.tempo, s.start_time
+        FROM workout_sets ws
+        JOIN workout_sessions s ON s.id = ws.session_id
+        WHERE s.user_id = $1 AND ws.exercise_id = $2 AND ws.status = $3 AND ws.set_type <> $4 AND s.id <> $5
+    `
+	rows, err := q.QueryContext(ctx, query, userID, exerciseID, SetCompleted, SetTypeWarmup, excludeSessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var latest time.Time
+	latestSession := 0
+	sets := []WorkoutSet{}
+	for rows.Next() {
+		var s WorkoutSet
+		var startTime time.Time
+		if err := rows.Scan(&s.ID, &s.SessionID, &s.SetOrder, &s.WeightKG, &s.Reps, &s.RPE, &s.PerformedAt, &s.CreatedAt, &s.SetType, &s.SupersetGroup, &s.RIR, &s.Tempo, &startTime); err != nil {
+			return nil, err
+		}
+		s.ExerciseID = exerciseID
+		s.Status = SetCompleted
+		if latestSession == 0 || startTime.After(latest) || (startTime.Equal(latest) && s.SessionID > latestSession) {
+			latest, latestSession = startTime, s.SessionID
+		}
+		sets = append(sets, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	last := []WorkoutSet{}
+	for _, s := range sets {
+		if s.SessionID == latestSession {
+			last = append(last, s)
+		}
+	}
+	sort.Slice(last, func(i, j int) bool {
+		return last[i].SetOrder < last[j].SetOrder
+	})
+	return last, nil
+}
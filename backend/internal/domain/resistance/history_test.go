@@ -0,0 +1,147 @@
+package resistance
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// brisbane is ten hours ahead of UTC, so the start of a local day falls on
+// the UTC day before.
+var brisbane = time.FixedZone("AEST", 10*60*60)
+
+func local(d, h, m int) time.Time {
+	return time.Date(2026, time.March, d, h, m, 0, 0, brisbane)
+}
+
+func historySessions(h *ExerciseHistory) []int {
+	ids := []int{}
+	for _, sh := range h.Sessions {
+		ids = append(ids, sh.SessionID)
+	}
+	return ids
+}
+
+func TestExerciseHistoryRange(t *testing.T) {
+	f := newFixture(t)
+	// Sessions half an hour either side of the range's local midnights, the
+	// ones inside stored in UTC: as text they sort a day earlier than the
+	// local bounds.
+	before := f.session(local(1, 23, 30))
+	first := f.session(local(2, 0, 30).UTC())
+	last := f.session(local(4, 23, 30).UTC())
+	after := f.session(local(5, 0, 30))
+	for _, s := range []int{before, first, last, after} {
+		f.addSet(s, 100, 5, SetTypeNormal, day)
+	}
+
+	tests := []struct {
+		name  string
+		start time.Time
+		want  []int
+		dates []string
+	}{
+		{"bounded", local(2, 0, 0), []int{last, first}, []string{"2026-03-04", "2026-03-02"}},
+		{"open start", time.Time{}, []int{last, first, before}, []string{"2026-03-04", "2026-03-02", "2026-03-01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := f.repo.ExerciseHistory(t.Context(), f.userID, f.exerciseID, tt.start, local(4, 0, 0), brisbane, 20, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := historySessions(h); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("sessions = %v, want %v", got, tt.want)
+			}
+			for i, sh := range h.Sessions {
+				if sh.Date != tt.dates[i] {
+					t.Errorf("session %d dated %s, want %s", sh.SessionID, sh.Date, tt.dates[i])
+				}
+			}
+			if h.TotalSessions != len(tt.want) || len(h.Trend) != len(tt.want) {
+				t.Errorf("total %d and %d trend points, want %d", h.TotalSessions, len(h.Trend), len(tt.want))
+			}
+			if (h.Start == "") != tt.start.IsZero() {
+				t.Errorf("start = %q for range start %v", h.Start, tt.start)
+			}
+		})
+	}
+}
+
+func TestExerciseHistoryPaging(t *testing.T) {
+	f := newFixture(t)
+	ids := []int{}
+	for d := 1; d <= 5; d++ {
+		s := f.session(day.AddDate(0, 0, d))
+		f.addSet(s, float64(100+d), 5, SetTypeNormal, day)
+		ids = append([]int{s}, ids...)
+	}
+
+	tests := []struct {
+		limit, offset int
+		want          []int
+	}{
+		{20, 0, ids},
+		{2, 0, ids[:2]},
+		{2, 2, ids[2:4]},
+		{2, 4, ids[4:]},
+		{10, 3, ids[3:]},
+		{2, 5, []int{}},
+		{2, 50, []int{}},
+	}
+	for _, tt := range tests {
+		h, err := f.repo.ExerciseHistory(t.Context(), f.userID, f.exerciseID, time.Time{}, day.AddDate(0, 0, 10), time.UTC, tt.limit, tt.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := historySessions(h); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("limit %d offset %d: sessions = %v, want %v", tt.limit, tt.offset, got, tt.want)
+		}
+		if h.TotalSessions != 5 || len(h.Trend) != 5 {
+			t.Errorf("limit %d offset %d: total %d and %d trend points, want 5", tt.limit, tt.offset, h.TotalSessions, len(h.Trend))
+		}
+		if h.Trend[0].SessionID != ids[4] || h.Trend[0].TopWeightKG != 101 {
+			t.Errorf("trend starts at %+v, want the oldest session", h.Trend[0])
+		}
+	}
+}
+
+func TestExerciseHistorySummary(t *testing.T) {
+	f := newFixture(t)
+	session := f.session(day)
+	f.addSet(session, 150, 1, SetTypeWarmup, day)
+	f.addSet(session, 100, 5, SetTypeNormal, day)
+	top := f.addSet(session, 100, 8, SetTypeNormal, day)
+	f.addSet(session, 100, 8, SetTypeNormal, day)
+	f.addSet(session, 90, 12, SetTypeNormal, day)
+
+	midnight := day.Truncate(24 * time.Hour)
+	h, err := f.repo.ExerciseHistory(t.Context(), f.userID, f.exerciseID, midnight, midnight, time.UTC, 20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Sessions) != 1 {
+		t.Fatalf("%d sessions, want 1", len(h.Sessions))
+	}
+	sh := h.Sessions[0]
+	if len(sh.Sets) != 5 {
+		t.Errorf("%d sets, want all 5 including the warm-up", len(sh.Sets))
+	}
+	// The heaviest weight ties three ways: most reps wins, then set order.
+	if sh.TopSet == nil || sh.TopSet.ID != top.ID {
+		t.Errorf("top set = %+v, want set %d", sh.TopSet, top.ID)
+	}
+	// 90 x 12 estimates higher than the top set, and the heavier warm-up
+	// single doesn't count.
+	if want := E1RM(90, 12); math.Abs(sh.E1RMKG-want) > 1e-9 || sh.E1RMKG <= E1RM(100, 8) {
+		t.Errorf("e1RM = %g, want %g from the 90 kg set", sh.E1RMKG, want)
+	}
+	if sh.VolumeKG != 3180 {
+		t.Errorf("volume = %g, want 3180", sh.VolumeKG)
+	}
+	p := h.Trend[0]
+	if p.TopWeightKG != 100 || p.E1RMKG != sh.E1RMKG || p.VolumeKG != sh.VolumeKG {
+		t.Errorf("trend point %+v doesn't match the session", p)
+	}
+}
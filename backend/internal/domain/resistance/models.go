@@ -35,6 +35,17 @@ type WorkoutSet struct {
 	RPE         *float64  `json:"rpe"`
 	PerformedAt time.Time `json:"performed_at"`
 	CreatedAt   time.Time `json:"created_at"`
+
+	// Status is one of the Set* constants. Only completed sets count towards
+	// records, history and volume; a planned set's weight and reps are what
+	// it was laid out with.
+	Status string `json:"status"`
+
+	SetDetails
+
+	// PersonalRecords are the records the set holds, returned when it is
+	// added or edited.
+	PersonalRecords []PersonalRecord `json:"personal_records,omitempty"`
 }
 
 type Routine struct {
@@ -52,4 +63,110 @@ type RoutineExercise struct {
     ExerciseID    int    `json:"exercise_id"`
     ExerciseName  string `json:"exercise_name"` // Joined
     ExerciseOrder int    `json:"exercise_order"`
-}
\ No newline at end of file
+
+	// Targets, each nil when the routine doesn't set one. Reps are a range;
+	// for a fixed count the two ends are equal.
+	TargetSets  *int     `json:"target_sets"`
+	RepRangeMin *int     `json:"rep_range_min"`
+	RepRangeMax *int     `json:"rep_range_max"`
+	TargetRPE   *float64 `json:"target_rpe"`
+	RestSeconds *int     `json:"rest_seconds"`
+
+	// Progression is the strategy recommendations use, one of the
+	// Progression constants other than auto; nil picks one.
+	Progression *string `json:"progression"`
+}
+
+// SetDetails describe how a set was done, beyond weight and reps.
+type SetDetails struct {
+	// SetType is one of the SetType constants.
+	SetType string `json:"set_type"`
+
+	// SupersetGroup numbers the sets of a session done back to back; nil
+	// for a set done on its own.
+	SupersetGroup *int `json:"superset_group"`
+
+	// RIR is reps in reserve, an alternative to RPE.
+	RIR *int `json:"rir"`
+
+	// Tempo is four digits or X for eccentric, pause, concentric and pause
+	// seconds, as in "31X0".
+	Tempo *string `json:"tempo"`
+}
+
+// SetDetailsUpdate changes a set's details. An empty SetType and nil fields
+// keep the stored values; a Clear flag empties its field instead.
+type SetDetailsUpdate struct {
+	SetDetails
+	ClearSupersetGroup bool
+	ClearRIR           bool
+	ClearTempo         bool
+}
+
+// Set types. Warm-ups are kept out of volume, records and progression.
+const (
+	SetTypeNormal  = "normal"
+	SetTypeWarmup  = "warmup"
+	SetTypeDrop    = "drop"
+	SetTypeFailure = "failure"
+	SetTypeAMRAP   = "amrap"
+)
+
+func ValidSetType(t string) bool {
+	switch t {
+	case SetTypeNormal, SetTypeWarmup, SetTypeDrop, SetTypeFailure, SetTypeAMRAP:
+		return true
+	}
+	return false
+}
+
+// Set statuses.
+const (
+	SetPlanned   = "planned"
+	SetCompleted = "completed"
+	SetSkipped   = "skipped"
+)
+
+// RoutineStart is a session just started from a routine, with its sets laid
+// out, and the routine it follows.
+type RoutineStart struct {
+	Session *WorkoutSession `json:"session"`
+	Routine *Routine        `json:"routine"`
+
+	// Recommendations has one entry per routine exercise, in routine order.
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// FinishSummary reports how a session went against its plan.
+type FinishSummary struct {
+	SessionID int               `json:"session_id"`
+	EndTime   time.Time         `json:"end_time"`
+	Completed int               `json:"completed"`
+	Skipped   int               `json:"skipped"`
+	Exercises []ExerciseSummary `json:"exercises"`
+}
+
+type ExerciseSummary struct {
+	ExerciseID   int    `json:"exercise_id"`
+	ExerciseName string `json:"exercise_name"`
+	Completed    int    `json:"completed"`
+	Skipped      int    `json:"skipped"`
+}
+
+// PersonalRecord is a set that beat every earlier set of the exercise on one
+// measure. ValueKG is the weight for max_weight, e1rm and rep_max, and weight
+// times reps for volume. Reps is set only for rep_max.
+type PersonalRecord struct {
+	ID           int     `json:"id"`
+	SetID        int     `json:"set_id"`
+	ExerciseID   int     `json:"exercise_id"`
+	ExerciseName string  `json:"exercise_name,omitempty"`
+	Kind         string  `json:"kind"`
+	Reps         int     `json:"reps,omitempty"`
+	ValueKG      float64 `json:"value_kg"`
+
+	// PreviousValueKG is the record this one beat, nil for the first.
+	PreviousValueKG *float64  `json:"previous_value_kg"`
+	AchievedAt      time.Time `json:"achieved_at"`
+	CreatedAt       time.Time `json:"created_at"`
+}
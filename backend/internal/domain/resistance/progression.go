@@ -0,0 +1,292 @@
+package resistance
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"fitness-buddy/internal/database"
+)
+
+// Progression strategies. ProgressionAuto picks double progression when
+// there is a rep range to climb, RPE autoregulation when the last sets were
+// rated, and fixed increments otherwise.
+const (
+	ProgressionAuto              = "auto"
+	ProgressionDoubleProgression = "double_progression"
+	ProgressionRPE               = "rpe"
+	ProgressionFixedIncrement    = "fixed_increment"
+)
+
+func ValidProgression(strategy string) bool {
+	switch strategy {
+	case ProgressionAuto, ProgressionDoubleProgression, ProgressionRPE, ProgressionFixedIncrement:
+		return true
+	}
+	return false
+}
+
+// incrementsKG is the smallest sensible jump in load for each kind of
+// equipment, which is also what recommended weights are rounded to.
+// Bodyweight exercises progress by reps instead.
+var incrementsKG = map[string]float64{
+	"Barbell":    2.5,
+	"Dumbbell":   2,
+	"Cable":      2.5,
+	"Machine":    5,
+	"Bodyweight": 0,
+}
+
+const defaultIncrementKG = 2.5
+
+// Defaults for strategies that need a target the routine doesn't set.
+const (
+	defaultRepRangeMin = 8
+	defaultRepRangeMax = 12
+	defaultTargetRPE   = 8
+)
+
+func IncrementKG(equipment *string) float64 {
+	if equipment != nil {
+		if inc, ok := incrementsKG[*equipment]; ok {
+			return inc
+		}
+	}
+	return defaultIncrementKG
+}
+
+// Recommendation is what to lift next time for one exercise, and why. Reason
+// leaves weights out, so it reads the same in either unit system.
+type Recommendation struct {
+	ExerciseID   int      `json:"exercise_id"`
+	ExerciseName string   `json:"exercise_name"`
+	Strategy     string   `json:"strategy"`
+	WeightKG     float64  `json:"weight_kg"`
+	Reps         int      `json:"reps"`
+	TargetRPE    *float64 `json:"target_rpe,omitempty"`
+
+	// E1RMKG is the estimate RPE autoregulation worked from.
+	E1RMKG      *float64 `json:"e1rm_kg,omitempty"`
+	IncrementKG float64  `json:"increment_kg"`
+	Reason      string   `json:"reason"`
+
+	// LastSets are the completed sets the recommendation is based on, from
+	// the most recent session with the exercise.
+	LastSets []WorkoutSet `json:"last_sets"`
+}
+
+// Recommend works out the next weight and reps for an exercise from the last
+// session it was done in. targets supplies the rep range and target RPE,
+// usually from a routine; strategy is one of the Progression constants, and a
+// routine exercise's own progression wins over ProgressionAuto.
+func Recommend(last []WorkoutSet, equipment *string, targets RoutineExercise, strategy string) Recommendation {
+	rec := Recommendation{
+		ExerciseID:  targets.ExerciseID,
+		IncrementKG: IncrementKG(equipment),
+		LastSets:    last,
+	}
+	if strategy == ProgressionAuto && targets.Progression != nil {
+		strategy = *targets.Progression
+	}
+	if strategy == ProgressionAuto {
+		switch {
+		case targets.RepRangeMin != nil || targets.RepRangeMax != nil:
+			strategy = ProgressionDoubleProgression
+		case targets.TargetRPE != nil || setRPE(topSet(last)) != nil:
+			strategy = ProgressionRPE
+		default:
+			strategy = ProgressionFixedIncrement
+		}
+	}
+	rec.Strategy = strategy
+	if strategy == ProgressionRPE {
+		target := float64(defaultTargetRPE)
+		if targets.TargetRPE != nil {
+			target = *targets.TargetRPE
+		}
+		rec.TargetRPE = &target
+	}
+
+	repMin, repMax := repRange(targets)
+	if len(last) == 0 {
+		rec.Reps = repMin
+		rec.Reason = "No completed sets yet: pick a weight you can lift for the reps with a few to spare."
+		return rec
+	}
+
+	top := topSet(last)
+	switch strategy {
+	case ProgressionDoubleProgression:
+		fewest := last[0].Reps
+		for _, s := range last {
+			fewest = min(fewest, s.Reps)
+		}
+		switch {
+		case fewest >= repMax && rec.IncrementKG > 0:
+			rec.WeightKG = top.WeightKG + rec.IncrementKG
+			rec.Reps = repMin
+			rec.Reason = fmt.Sprintf("Every set reached %d reps, the top of the range: add weight and start again at %d.", repMax, repMin)
+		case fewest >= repMax:
+			rec.WeightKG = top.WeightKG
+			rec.Reps = fewest + 1
+			rec.Reason = fmt.Sprintf("Every set reached %d reps and the load can't go up: add a rep.", repMax)
+		default:
+			rec.WeightKG = top.WeightKG
+			rec.Reps = min(max(fewest+1, repMin), repMax)
+			rec.Reason = fmt.Sprintf("The weakest set got %d reps: keep the weight and add reps until every set reaches %d.", fewest, repMax)
+		}
+
+	case ProgressionRPE:
+		target := *rec.TargetRPE
+		rec.Reps = top.Reps
+		if targets.RepRangeMin != nil || targets.RepRangeMax != nil {
+			rec.Reps = min(max(top.Reps, repMin), repMax)
+		}
+		if top.Reps < 1 {
+			// Without reps there is no e1RM, and a weight worked out from
+			// one would be zero.
+			rec.WeightKG = top.WeightKG
+			rec.Reps = repMin
+			rec.Reason = fmt.Sprintf("The last top set has no reps to estimate from: repeat the weight for %d reps, aiming for RPE %g.", repMin, target)
+			break
+		}
+		rpe := setRPE(top)
+		if rpe == nil {
+			rec.WeightKG = top.WeightKG
+			rec.Reason = fmt.Sprintf("The last top set has no RPE to adjust from: repeat it and rate it, aiming for RPE %g.", target)
+			break
+		}
+		if rec.IncrementKG == 0 {
+			rec.WeightKG = top.WeightKG
+			rec.Reason = fmt.Sprintf("The load can't go up: keep it and adjust reps to land at RPE %g.", target)
+			break
+		}
+		// Reps in reserve count as reps the set could have gone to, so an
+		// e1RM from reps plus RIR can be turned back into a weight for the
+		// target reps and RPE.
+		e1rm := E1RM(top.WeightKG, top.Reps+rir(*rpe))
+		rec.E1RMKG = &e1rm
+		rec.WeightKG = roundTo(e1rm/E1RM(1, rec.Reps+rir(target)), rec.IncrementKG)
+		rec.Reason = fmt.Sprintf("The last top set, %d reps at RPE %g, gives the e1RM: this is the weight for %d reps at RPE %g.", top.Reps, *rpe, rec.Reps, target)
+
+	default:
+		rec.Strategy = ProgressionFixedIncrement
+		target := top.Reps
+		if targets.RepRangeMax != nil {
+			target = repMax
+		}
+		missed := false
+		for _, s := range last {
+			if s.Reps < target {
+				missed = true
+			}
+		}
+		rec.Reps = target
+		switch {
+		case missed:
+			rec.WeightKG = top.WeightKG
+			rec.Reason = fmt.Sprintf("Not every set reached %d reps: repeat the weight.", target)
+		case rec.IncrementKG == 0:
+			rec.WeightKG = top.WeightKG
+			rec.Reps = target + 1
+			rec.Reason = "Every set was completed: add a rep."
+		default:
+			rec.WeightKG = top.WeightKG + rec.IncrementKG
+			rec.Reason = fmt.Sprintf("Every set reached %d reps: add one increment.", target)
+		}
+	}
+	return rec
+}
+
+// recommend loads what Recommend needs for one exercise. excludeSessionID
+// keeps a session just started from counting as the last one.
+func recommend(ctx context.Context, q queryExecer, userID int, targets RoutineExercise, strategy string, excludeSessionID int) (*Recommendation, error) {
+	var name string
+	var equipment *string
+	err := q.QueryRowContext(ctx, `SELECT name, equipment FROM exercises WHERE id = $1`, targets.ExerciseID).Scan(&name, &equipment)
+	if err == sql.ErrNoRows {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	last, err := lastPerformance(ctx, q, userID, targets.ExerciseID, excludeSessionID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range last {
+		last[i].ExerciseName = name
+	}
+	rec := Recommend(last, equipment, targets, strategy)
+	rec.ExerciseName = name
+	return &rec, nil
+}
+
+// NextSets recommends the next weight and reps for an exercise. routineID,
+// if not zero, takes the targets for the exercise from that routine of the
+// user's.
+func (r *Repository) NextSets(ctx context.Context, userID, exerciseID, routineID int, strategy string) (*Recommendation, error) {
+	targets := RoutineExercise{ExerciseID: exerciseID}
+	if routineID != 0 {
+		rt, err := r.GetRoutine(ctx, routineID)
+		if err == sql.ErrNoRows || (err == nil && rt.UserID != userID) {
+			return nil, database.ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, re := range rt.Exercises {
+			if re.ExerciseID == exerciseID {
+				targets = re
+				break
+			}
+		}
+	}
+	return recommend(ctx, r.db.Pool, userID, targets, strategy, 0)
+}
+
+// topSet is the heaviest set, the one with more reps on a tie.
+func topSet(sets []WorkoutSet) WorkoutSet {
+	var top WorkoutSet
+	for i, s := range sets {
+		if i == 0 || s.WeightKG > top.WeightKG || (s.WeightKG == top.WeightKG && s.Reps > top.Reps) {
+			top = s
+		}
+	}
+	return top
+}
+
+func repRange(targets RoutineExercise) (int, int) {
+	lo, hi := defaultRepRangeMin, defaultRepRangeMax
+	switch {
+	case targets.RepRangeMin != nil && targets.RepRangeMax != nil:
+		lo, hi = *targets.RepRangeMin, *targets.RepRangeMax
+	case targets.RepRangeMin != nil:
+		lo, hi = *targets.RepRangeMin, *targets.RepRangeMin
+	case targets.RepRangeMax != nil:
+		lo, hi = *targets.RepRangeMax, *targets.RepRangeMax
+	}
+	return lo, hi
+}
+
+// setRPE is the set's RPE, or the one its reps in reserve imply.
+func setRPE(s WorkoutSet) *float64 {
+	if s.RPE == nil && s.RIR != nil {
+		rpe := float64(max(10-*s.RIR, 1))
+		return &rpe
+	}
+	return s.RPE
+}
+
+// rir converts RPE to reps in reserve, rounding half reps down.
+func rir(rpe float64) int {
+	return max(int(math.Floor(10-rpe)), 0)
+}
+
+func roundTo(weightKG, increment float64) float64 {
+	if increment <= 0 {
+		return weightKG
+	}
+	return math.Round(weightKG/increment) * increment
+}
@@ -0,0 +1,133 @@
+package resistance
+
+import (
+	"math"
+	"testing"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+// sets returns one completed set per entry in reps, all at weightKG.
+func sets(weightKG float64, reps ...int) []WorkoutSet {
+	out := make([]WorkoutSet, len(reps))
+	for i, r := range reps {
+		out[i] = WorkoutSet{WeightKG: weightKG, Reps: r, Status: SetCompleted}
+	}
+	return out
+}
+
+// rated is sets, each rated at rpe.
+func rated(rpe float64, weightKG float64, reps ...int) []WorkoutSet {
+	out := sets(weightKG, reps...)
+	for i := range out {
+		out[i].RPE = &rpe
+	}
+	return out
+}
+
+func rangeOf(lo, hi int) RoutineExercise {
+	return RoutineExercise{RepRangeMin: &lo, RepRangeMax: &hi}
+}
+
+func TestRecommend(t *testing.T) {
+	barbell, dumbbell, machine, bodyweight := ptr("Barbell"), ptr("Dumbbell"), ptr("Machine"), ptr("Bodyweight")
+	withRIR := sets(100, 5, 5)
+	for i := range withRIR {
+		withRIR[i].RIR = ptr(3)
+	}
+
+	tests := []struct {
+		name         string
+		last         []WorkoutSet
+		equipment    *string
+		targets      RoutineExercise
+		strategy     string
+		wantStrategy string
+		wantWeight   float64
+		wantReps     int
+	}{
+		// Double progression climbs the rep range, then adds weight.
+		{"double: every set at the top", sets(100, 12, 12, 12), barbell, rangeOf(8, 12), ProgressionDoubleProgression, ProgressionDoubleProgression, 102.5, 8},
+		{"double: one set short", sets(100, 12, 10, 12), barbell, rangeOf(8, 12), ProgressionDoubleProgression, ProgressionDoubleProgression, 100, 11},
+		{"double: below the range", sets(100, 6, 5), barbell, rangeOf(8, 12), ProgressionDoubleProgression, ProgressionDoubleProgression, 100, 8},
+		{"double: past the top", sets(100, 14, 13), barbell, rangeOf(8, 12), ProgressionDoubleProgression, ProgressionDoubleProgression, 102.5, 8},
+		{"double: fixed rep count", sets(60, 5, 5), dumbbell, rangeOf(5, 5), ProgressionDoubleProgression, ProgressionDoubleProgression, 62, 5},
+		{"double: default range", sets(40, 12, 12), machine, RoutineExercise{}, ProgressionDoubleProgression, ProgressionDoubleProgression, 45, 8},
+		{"double: bodyweight adds reps", sets(0, 12, 12), bodyweight, rangeOf(8, 12), ProgressionDoubleProgression, ProgressionDoubleProgression, 0, 13},
+
+		// RPE turns the last top set into an e1RM and back into a weight.
+		{"rpe: on target", rated(8, 100, 5, 5), barbell, RoutineExercise{}, ProgressionRPE, ProgressionRPE, 100, 5},
+		{"rpe: too easy", rated(7, 100, 5, 5), barbell, RoutineExercise{}, ProgressionRPE, ProgressionRPE, 102.5, 5},
+		{"rpe: too hard", rated(9.5, 100, 5, 5), barbell, RoutineExercise{}, ProgressionRPE, ProgressionRPE, 95, 5},
+		{"rpe: from RIR", withRIR, barbell, RoutineExercise{}, ProgressionRPE, ProgressionRPE, 102.5, 5},
+		{"rpe: routine target", rated(8, 100, 5, 5), barbell, RoutineExercise{TargetRPE: ptr(9.0)}, ProgressionRPE, ProgressionRPE, 102.5, 5},
+		{"rpe: reps clamped to the range", rated(8, 100, 8), barbell, rangeOf(3, 5), ProgressionRPE, ProgressionRPE, 110, 5},
+		{"rpe: unrated", sets(100, 5, 5), barbell, RoutineExercise{}, ProgressionRPE, ProgressionRPE, 100, 5},
+		{"rpe: no reps", rated(10, 100, 0), barbell, RoutineExercise{}, ProgressionRPE, ProgressionRPE, 100, 8},
+		{"rpe: bodyweight", rated(7, 0, 10), bodyweight, RoutineExercise{}, ProgressionRPE, ProgressionRPE, 0, 10},
+
+		// Fixed increments add one step once every set is done.
+		{"fixed: every set done", sets(100, 5, 5, 5), barbell, RoutineExercise{}, ProgressionFixedIncrement, ProgressionFixedIncrement, 102.5, 5},
+		{"fixed: a set missed", sets(100, 5, 4, 5), barbell, RoutineExercise{}, ProgressionFixedIncrement, ProgressionFixedIncrement, 100, 5},
+		{"fixed: dumbbell", sets(20, 10, 10), dumbbell, RoutineExercise{}, ProgressionFixedIncrement, ProgressionFixedIncrement, 22, 10},
+		{"fixed: machine", sets(50, 10, 10), machine, RoutineExercise{}, ProgressionFixedIncrement, ProgressionFixedIncrement, 55, 10},
+		{"fixed: unknown equipment", sets(50, 10), ptr("Sandbag"), RoutineExercise{}, ProgressionFixedIncrement, ProgressionFixedIncrement, 52.5, 10},
+		{"fixed: no equipment", sets(50, 10), nil, RoutineExercise{}, ProgressionFixedIncrement, ProgressionFixedIncrement, 52.5, 10},
+		{"fixed: bodyweight adds a rep", sets(0, 10, 10), bodyweight, RoutineExercise{}, ProgressionFixedIncrement, ProgressionFixedIncrement, 0, 11},
+		{"fixed: short of the range top", sets(100, 8, 8), barbell, rangeOf(6, 10), ProgressionFixedIncrement, ProgressionFixedIncrement, 100, 10},
+
+		// Auto picks from what the targets and sets offer.
+		{"auto: rep range", sets(100, 12, 12), barbell, rangeOf(8, 12), ProgressionAuto, ProgressionDoubleProgression, 102.5, 8},
+		{"auto: rated sets", rated(8, 100, 5), barbell, RoutineExercise{}, ProgressionAuto, ProgressionRPE, 100, 5},
+		{"auto: target RPE", sets(100, 5), barbell, RoutineExercise{TargetRPE: ptr(8.0)}, ProgressionAuto, ProgressionRPE, 100, 5},
+		{"auto: nothing to go on", sets(100, 5), barbell, RoutineExercise{}, ProgressionAuto, ProgressionFixedIncrement, 102.5, 5},
+		{"auto: routine pins a strategy", sets(100, 12, 12), barbell, RoutineExercise{RepRangeMin: ptr(8), RepRangeMax: ptr(12), Progression: ptr(ProgressionFixedIncrement)}, ProgressionAuto, ProgressionFixedIncrement, 102.5, 12},
+		{"auto: explicit strategy beats the pin", sets(100, 5), barbell, RoutineExercise{Progression: ptr(ProgressionRPE)}, ProgressionFixedIncrement, ProgressionFixedIncrement, 102.5, 5},
+
+		// Without history there is nothing to progress from.
+		{"no history", nil, barbell, RoutineExercise{}, ProgressionAuto, ProgressionFixedIncrement, 0, 8},
+		{"no history: routine range", nil, barbell, rangeOf(5, 8), ProgressionAuto, ProgressionDoubleProgression, 0, 5},
+		{"no history: rpe", nil, barbell, RoutineExercise{}, ProgressionRPE, ProgressionRPE, 0, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := Recommend(tt.last, tt.equipment, tt.targets, tt.strategy)
+			if rec.Strategy != tt.wantStrategy {
+				t.Errorf("strategy = %q, want %q", rec.Strategy, tt.wantStrategy)
+			}
+			if math.Abs(rec.WeightKG-tt.wantWeight) > 1e-9 || rec.Reps != tt.wantReps {
+				t.Errorf("got %g kg x %d, want %g kg x %d (%s)", rec.WeightKG, rec.Reps, tt.wantWeight, tt.wantReps, rec.Reason)
+			}
+			if rec.Reason == "" {
+				t.Error("no reason given")
+			}
+			if rec.WeightKG < 0 || (len(tt.last) > 0 && tt.last[0].WeightKG > 0 && rec.WeightKG == 0) {
+				t.Errorf("weight %g kg recommended after lifting %g kg", rec.WeightKG, tt.last[0].WeightKG)
+			}
+		})
+	}
+}
+
+func TestIncrementKG(t *testing.T) {
+	tests := []struct {
+		equipment *string
+		want      float64
+	}{
+		{ptr("Barbell"), 2.5},
+		{ptr("Dumbbell"), 2},
+		{ptr("Cable"), 2.5},
+		{ptr("Machine"), 5},
+		{ptr("Bodyweight"), 0},
+		{ptr("Kettlebell"), defaultIncrementKG},
+		{nil, defaultIncrementKG},
+	}
+	for _, tt := range tests {
+		if got := IncrementKG(tt.equipment); got != tt.want {
+			name := "<nil>"
+			if tt.equipment != nil {
+				name = *tt.equipment
+			}
+			t.Errorf("IncrementKG(%s) = %g, want %g", name, got, tt.want)
+		}
+	}
+}
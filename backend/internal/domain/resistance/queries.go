@@ -2,7 +2,9 @@ package resistance
 
 import (
 	"context"
+	"database/sql"
 	"fitness-buddy/internal/database"
+	"sort"
 	"time"
 )
 
@@ -36,13 +38,13 @@ func (r *Repository) ListExercises(ctx context.Context) ([]Exercise, error) {
 	return exercises, nil
 }
 
-func (r *Repository) CreateExercise(ctx context.Context, name, category string, equipment *string) (*Exercise, error) {
-	query := `INSERT INTO exercises (name, category, equipment) VALUES ($1, $2, $3) RETURNING id, created_at`
+func (r *Repository) CreateExercise(ctx context.Context, userID int, name, category string, equipment *string) (*Exercise, error) {
+	query := `INSERT INTO exercises (name, category, equipment, created_by) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
 	var e Exercise
 	e.Name = name
 	e.Category = category
 	e.Equipment = equipment
-	err := r.db.Pool.QueryRowContext(ctx, query, name, category, equipment).Scan(&e.ID, &e.CreatedAt)
+	err := r.db.Pool.QueryRowContext(ctx, query, name, category, equipment, userID).Scan(&e.ID, &e.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -62,23 +64,100 @@ func (r *Repository) CreateSession(ctx context.Context, userID int, startTime ti
 	return &s, nil
 }
 
-func (r *Repository) FinishSession(ctx context.Context, id int, endTime time.Time) error {
-	query := `UPDATE workout_sessions SET end_time = $1 WHERE id = $2`
-	_, err := r.db.Pool.ExecContext(ctx, query, endTime, id)
-	return err
+// FinishSession ends a session and reports its sets against the plan. Sets
+// still planned were not done and are marked skipped.
+func (r *Repository) FinishSession(ctx context.Context, userID, id int, endTime time.Time) (*FinishSummary, error) {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE workout_sessions SET end_time = $1 WHERE id = $2 AND user_id = $3`
+	if err := database.RequireAffected(tx.ExecContext(ctx, query, endTime, id, userID)); err != nil {
+		return nil, err
+	}
+	query = `UPDATE workout_sets SET status = $1 WHERE session_id = $2 AND status = $3`
+	if _, err := tx.ExecContext(ctx, query, SetSkipped, id, SetPlanned); err != nil {
+		return nil, err
+	}
+
+	query = `
+        SELECT ws.exercise_id, e.name, ws.status, COUNT(*), MIN(ws.set_order)
+        FROM workout_sets ws
+        JOIN exercises e ON e.id = ws.exercise_id
+        WHERE ws.session_id = $1
+        GROUP BY ws.exercise_id, e.name, ws.status
+    `
+	rows, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary := FinishSummary{SessionID: id, EndTime: endTime, Exercises: []ExerciseSummary{}}
+	index := map[int]int{}
+	firstSet := map[int]int{}
+	for rows.Next() {
+		var es ExerciseSummary
+		var status string
+		var count, first int
+		if err := rows.Scan(&es.ExerciseID, &es.ExerciseName, &status, &count, &first); err != nil {
+			return nil, err
+		}
+		i, ok := index[es.ExerciseID]
+		if !ok {
+			i = len(summary.Exercises)
+			index[es.ExerciseID] = i
+			firstSet[es.ExerciseID] = first
+			summary.Exercises = append(summary.Exercises, es)
+		} else if first < firstSet[es.ExerciseID] {
+			firstSet[es.ExerciseID] = first
+		}
+		switch status {
+		case SetCompleted:
+			summary.Exercises[i].Completed += count
+			summary.Completed += count
+		case SetSkipped:
+			summary.Exercises[i].Skipped += count
+			summary.Skipped += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(summary.Exercises, func(i, j int) bool {
+		return firstSet[summary.Exercises[i].ExerciseID] < firstSet[summary.Exercises[j].ExerciseID]
+	})
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &summary, nil
 }
 
-func (r *Repository) AddSet(ctx context.Context, sessionID, exerciseID int, weight float64, reps int, rpe *float64, performedAt time.Time) (*WorkoutSet, error) {
+// AddSet logs a set and returns it with any personal records it sets.
+func (r *Repository) AddSet(ctx context.Context, userID, sessionID, exerciseID int, weight float64, reps int, rpe *float64, details SetDetails, performedAt time.Time) (*WorkoutSet, error) {
+	if err := r.requireSession(ctx, userID, sessionID); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	countQuery := `SELECT COUNT(*) FROM workout_sets WHERE session_id = $1`
 	var count int
-	if err := r.db.Pool.QueryRowContext(ctx, countQuery, sessionID).Scan(&count); err != nil {
+	if err := tx.QueryRowContext(ctx, countQuery, sessionID).Scan(&count); err != nil {
 		return nil, err
 	}
 	setOrder := count + 1
 
 	query := `
-        INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, rpe, performed_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, rpe, performed_at, set_type, superset_group, rir, tempo)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
         RETURNING id, created_at
     `
 	var s WorkoutSet
@@ -89,12 +168,23 @@ func (r *Repository) AddSet(ctx context.Context, sessionID, exerciseID int, weig
 	s.Reps = reps
 	s.RPE = rpe
 	s.PerformedAt = performedAt
+	s.Status = SetCompleted
+	s.SetDetails = details
 
-	err := r.db.Pool.QueryRowContext(ctx, query, sessionID, exerciseID, setOrder, weight, reps, rpe, performedAt).Scan(&s.ID, &s.CreatedAt)
+	err = tx.QueryRowContext(ctx, query, sessionID, exerciseID, setOrder, weight, reps, rpe, performedAt, details.SetType, details.SupersetGroup, details.RIR, details.Tempo).Scan(&s.ID, &s.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 
+	if _, err := RecomputeRecords(ctx, tx, userID, exerciseID); err != nil {
+		return nil, err
+	}
+	if s.PersonalRecords, err = recordsForSet(ctx, tx, userID, s.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &s, nil
 }
 
@@ -127,7 +217,7 @@ func (r *Repository) ListSessions(ctx context.Context, userID int, limit int) ([
 	return sessions, nil
 }
 
-func (r *Repository) CreateRoutine(ctx context.Context, userID int, name string, notes *string, exerciseIDs []int) (*Routine, error) {
+func (r *Repository) CreateRoutine(ctx context.Context, userID int, name string, notes *string, exercises []RoutineExercise) (*Routine, error) {
 	tx, err := r.db.Pool.Begin()
 	if err != nil {
 		return nil, err
@@ -142,8 +232,9 @@ func (r *Repository) CreateRoutine(ctx context.Context, userID int, name string,
 	}
 
 	// Add Exercises
-	for i, exID := range exerciseIDs {
-		_, err := tx.ExecContext(ctx, "INSERT INTO routine_exercises (routine_id, exercise_id, exercise_order) VALUES ($1, $2, $3)", routineID, exID, i+1)
+	for i, re := range exercises {
+		_, err := tx.ExecContext(ctx, "INSERT INTO routine_exercises (routine_id, exercise_id, exercise_order, target_sets, rep_range_min, rep_range_max, target_rpe, rest_seconds, progression) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+			routineID, re.ExerciseID, i+1, re.TargetSets, re.RepRangeMin, re.RepRangeMax, re.TargetRPE, re.RestSeconds, re.Progression)
 		if err != nil {
 			return nil, err
 		}
@@ -200,7 +291,7 @@ func (r *Repository) GetRoutine(ctx context.Context, id int) (*Routine, error) {
 
 func (r *Repository) GetRoutineExercises(ctx context.Context, routineID int) ([]RoutineExercise, error) {
 	query := `
-        SELECT re.id, re.routine_id, re.exercise_id, e.name, re.exercise_order 
+        SELECT re.id, re.routine_id, re.exercise_id, e.name, re.exercise_order, re.target_sets, re.rep_range_min, re.rep_range_max, re.target_rpe, re.rest_seconds, re.progression
         FROM routine_exercises re
         JOIN exercises e ON re.exercise_id = e.id
         WHERE re.routine_id = $1
@@ -215,7 +306,7 @@ func (r *Repository) GetRoutineExercises(ctx context.Context, routineID int) ([]
 	exs := []RoutineExercise{}
 	for rows.Next() {
 		var re RoutineExercise
-		if err := rows.Scan(&re.ID, &re.RoutineID, &re.ExerciseID, &re.ExerciseName, &re.ExerciseOrder); err != nil {
+		if err := rows.Scan(&re.ID, &re.RoutineID, &re.ExerciseID, &re.ExerciseName, &re.ExerciseOrder, &re.TargetSets, &re.RepRangeMin, &re.RepRangeMax, &re.TargetRPE, &re.RestSeconds, &re.Progression); err != nil {
 			return nil, err
 		}
 		exs = append(exs, re)
@@ -226,9 +317,75 @@ func (r *Repository) GetRoutineExercises(ctx context.Context, routineID int) ([]
 	return exs, nil
 }
 
+// StartRoutine starts a session that follows one of the user's routines.
+// Each exercise gets its target number of sets, or as many as last time, and
+// each set is planned with the weight and reps of the same set last time.
+// Reps start at the bottom of the routine's range when it has one. What to
+// aim for instead comes back as a recommendation per exercise.
+func (r *Repository) StartRoutine(ctx context.Context, userID, routineID int, startTime time.Time) (*RoutineStart, error) {
+	rt, err := r.GetRoutine(ctx, routineID)
+	if err == sql.ErrNoRows || (err == nil && rt.UserID != userID) {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	session := WorkoutSession{UserID: userID, StartTime: startTime}
+	query := `INSERT INTO workout_sessions (user_id, start_time) VALUES ($1, $2) RETURNING id, created_at`
+	if err := tx.QueryRowContext(ctx, query, userID, startTime).Scan(&session.ID, &session.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	recommendations := []Recommendation{}
+	setOrder := 0
+	for _, re := range rt.Exercises {
+		rec, err := recommend(ctx, tx, userID, re, ProgressionAuto, session.ID)
+		if err != nil {
+			return nil, err
+		}
+		recommendations = append(recommendations, *rec)
+		last := rec.LastSets
+		count := max(len(last), 1)
+		if re.TargetSets != nil {
+			count = *re.TargetSets
+		}
+		for i := 0; i < count; i++ {
+			var weight float64
+			var reps int
+			if len(last) > 0 {
+				prev := last[min(i, len(last)-1)]
+				weight, reps = prev.WeightKG, prev.Reps
+			}
+			if re.RepRangeMin != nil {
+				reps = *re.RepRangeMin
+			}
+			setOrder++
+			query := `INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, performed_at, status) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+			if _, err := tx.ExecContext(ctx, query, session.ID, re.ExerciseID, setOrder, weight, reps, startTime, SetPlanned); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if session.Sets, err = r.GetSetsForSession(ctx, session.ID); err != nil {
+		return nil, err
+	}
+	return &RoutineStart{Session: &session, Routine: rt, Recommendations: recommendations}, nil
+}
+
 func (r *Repository) GetSetsForSession(ctx context.Context, sessionID int) ([]WorkoutSet, error) {
 	query := `
-        SELECT s.id, s.session_id, s.exercise_id, e.name, s.set_order, s.weight_kg, s.reps, s.rpe, s.performed_at, s.created_at
+        SELECT s.id, s.session_id, s.exercise_id, e.name, s.set_order, s.weight_kg, s.reps, s.rpe, s.performed_at, s.created_at, s.status, s.set_type, s.superset_group, s.rir, s.tempo
         FROM workout_sets s
         JOIN exercises e ON s.exercise_id = e.id
         WHERE s.session_id = $1
@@ -243,7 +400,7 @@ func (r *Repository) GetSetsForSession(ctx context.Context, sessionID int) ([]Wo
 	sets := []WorkoutSet{}
 	for rows.Next() {
 		var s WorkoutSet
-		if err := rows.Scan(&s.ID, &s.SessionID, &s.ExerciseID, &s.ExerciseName, &s.SetOrder, &s.WeightKG, &s.Reps, &s.RPE, &s.PerformedAt, &s.CreatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.SessionID, &s.ExerciseID, &s.ExerciseName, &s.SetOrder, &s.WeightKG, &s.Reps, &s.RPE, &s.PerformedAt, &s.CreatedAt, &s.Status, &s.SetType, &s.SupersetGroup, &s.RIR, &s.Tempo); err != nil {
 			return nil, err
 		}
 		sets = append(sets, s)
@@ -254,24 +411,151 @@ func (r *Repository) GetSetsForSession(ctx context.Context, sessionID int) ([]Wo
 	return sets, nil
 }
 
-func (r *Repository) UpdateSet(ctx context.Context, setID int, weight float64, reps int, rpe *float64) error {
-	query := `UPDATE workout_sets SET weight_kg = $1, reps = $2, rpe = $3 WHERE id = $4`
-	_, err := r.db.Pool.ExecContext(ctx, query, weight, reps, rpe, setID)
-	return err
+// UpdateSet changes a set's numbers and returns it with the records it holds
+// afterwards. Records elsewhere that depended on the old numbers are
+// recomputed along with it. Filling in a planned or skipped set completes it
+// as performed at performedAt; a completed set keeps its time.
+func (r *Repository) UpdateSet(ctx context.Context, userID, setID int, weight float64, reps int, rpe *float64, details SetDetailsUpdate, performedAt time.Time) (*WorkoutSet, error) {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE workout_sets
+        SET weight_kg = $1, reps = $2, rpe = $3,
+            performed_at = CASE WHEN status = $4 THEN performed_at ELSE $5 END,
+            status = $4, set_type = COALESCE($6, set_type),
+            superset_group = COALESCE($7, CASE WHEN $8 THEN NULL ELSE superset_group END),
+            rir = COALESCE($9, CASE WHEN $10 THEN NULL ELSE rir END),
+            tempo = COALESCE($11, CASE WHEN $12 THEN NULL ELSE tempo END)
+        WHERE id = $13 AND session_id IN (SELECT id FROM workout_sessions WHERE user_id = $14)
+    `
+	var setType *string
+	if details.SetType != "" {
+		setType = &details.SetType
+	}
+	if err := database.RequireAffected(tx.ExecContext(ctx, query, weight, reps, rpe, SetCompleted, performedAt, setType, details.SupersetGroup, details.ClearSupersetGroup, details.RIR, details.ClearRIR, details.Tempo, details.ClearTempo, setID, userID)); err != nil {
+		return nil, err
+	}
+
+	var s WorkoutSet
+	query = `
+        SELECT s.id, s.session_id, s.exercise_id, e.name, s.set_order, s.weight_kg, s.reps, s.rpe, s.performed_at, s.created_at, s.status, s.set_type, s.superset_group, s.rir, s.tempo
+        FROM workout_sets s
+        JOIN exercises e ON s.exercise_id = e.id
+        WHERE s.id = $1
+    `
+	if err := tx.QueryRowContext(ctx, query, setID).Scan(&s.ID, &s.SessionID, &s.ExerciseID, &s.ExerciseName, &s.SetOrder, &s.WeightKG, &s.Reps, &s.RPE, &s.PerformedAt, &s.CreatedAt, &s.Status, &s.SetType, &s.SupersetGroup, &s.RIR, &s.Tempo); err != nil {
+		return nil, err
+	}
+
+	if _, err := RecomputeRecords(ctx, tx, userID, s.ExerciseID); err != nil {
+		return nil, err
+	}
+	if s.PersonalRecords, err = recordsForSet(ctx, tx, userID, s.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// DeleteSet removes a set. Its records go with it, and later sets it was
+// holding back may take their place.
+func (r *Repository) DeleteSet(ctx context.Context, userID, setID int) error {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exerciseID int
+	query := `SELECT exercise_id FROM workout_sets WHERE id = $1 AND session_id IN (SELECT id FROM workout_sessions WHERE user_id = $2)`
+	err = tx.QueryRowContext(ctx, query, setID, userID).Scan(&exerciseID)
+	if err == sql.ErrNoRows {
+		return database.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM workout_sets WHERE id = $1`, setID); err != nil {
+		return err
+	}
+	if _, err := RecomputeRecords(ctx, tx, userID, exerciseID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *Repository) DeleteSet(ctx context.Context, setID int) error {
-	query := `DELETE FROM workout_sets WHERE id = $1`
-	_, err := r.db.Pool.ExecContext(ctx, query, setID)
-	return err
+func (r *Repository) DeleteRoutine(ctx context.Context, userID, id int) error {
+	return database.RequireAffected(r.db.Pool.ExecContext(ctx, "DELETE FROM routines WHERE id = $1 AND user_id = $2", id, userID))
 }
 
-func (r *Repository) DeleteRoutine(ctx context.Context, id int) error {
-	_, err := r.db.Pool.ExecContext(ctx, "DELETE FROM routines WHERE id = $1", id)
-	return err
+// DeleteSession removes a workout and its sets, and recomputes the records
+// of every exercise in it.
+func (r *Repository) DeleteSession(ctx context.Context, userID, id int) error {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT exercise_id FROM workout_sets WHERE session_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	exerciseIDs := []int{}
+	for rows.Next() {
+		var exerciseID int
+		if err := rows.Scan(&exerciseID); err != nil {
+			rows.Close()
+			return err
+		}
+		exerciseIDs = append(exerciseIDs, exerciseID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := database.RequireAffected(tx.ExecContext(ctx, "DELETE FROM workout_sessions WHERE id = $1 AND user_id = $2", id, userID)); err != nil {
+		return err
+	}
+	for _, exerciseID := range exerciseIDs {
+		if _, err := RecomputeRecords(ctx, tx, userID, exerciseID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func (r *Repository) DeleteSession(ctx context.Context, id int) error {
-	_, err := r.db.Pool.ExecContext(ctx, "DELETE FROM workout_sessions WHERE id = $1", id)
-	return err
+func (r *Repository) requireSession(ctx context.Context, userID, sessionID int) error {
+	var count int
+	if err := r.db.Pool.QueryRowContext(ctx, "SELECT COUNT(*) FROM workout_sessions WHERE id = $1 AND user_id = $2", sessionID, userID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+// PurgeUser deletes the user's workouts and routines inside tx, as part of
+// deleting the account. Sets and routine exercises cascade. Custom exercises
+// stay in the shared library but lose their owner.
+func (r *Repository) PurgeUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM workout_sessions WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM routines WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE exercises SET created_by = NULL WHERE created_by = $1`, userID); err != nil {
+		return err
+	}
+	return nil
 }
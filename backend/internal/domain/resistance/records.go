@@ -0,0 +1,280 @@
+package resistance
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+)
+
+// Kinds of personal record. RecordRepMax is kept per rep count: the heaviest
+// weight lifted for exactly that many reps.
+const (
+	RecordMaxWeight = "max_weight"
+	RecordE1RM      = "e1rm"
+	RecordVolume    = "volume"
+	RecordRepMax    = "rep_max"
+)
+
+// E1RM estimates a one-rep max from a set: the weight itself for a single,
+// Brzycki up to 10 reps and Epley beyond, where Brzycki starts to overshoot.
+// The two agree at 10 reps.
+func E1RM(weightKG float64, reps int) float64 {
+	switch {
+	case reps <= 0:
+		return 0
+	case reps == 1:
+		return weightKG
+	case reps <= 10:
+		return weightKG * 36 / float64(37-reps)
+	default:
+		return weightKG * (1 + float64(reps)/30)
+	}
+}
+
+// queryExecer is satisfied by both *sql.DB and *sql.Tx.
+type queryExecer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type recordKey struct {
+	setID int
+	kind  string
+	reps  int
+}
+
+// RecomputeRecords replays the user's sets of one exercise in the order they
+// were performed and brings personal_records in line: a record is a set that
+// strictly beat everything before it. Replaying the whole history, rather
+// than comparing a new set with the current best, is what lets an edited,
+// deleted or back-dated set revoke records that no longer stand and grant
+// ones that now do. Records that are unchanged keep their IDs.
+func RecomputeRecords(ctx context.Context, q queryExecer, userID, exerciseID int) ([]PersonalRecord, error) {
+	sets, err := loadRecordSets(ctx, q, userID, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+
+	want := []PersonalRecord{}
+	best := map[recordKey]float64{}
+	for _, s := range sets {
+		if s.WeightKG <= 0 || s.Reps <= 0 {
+			continue
+		}
+		candidates := []struct {
+			kind  string
+			reps  int
+			value float64
+		}{
+			{RecordMaxWeight, 0, s.WeightKG},
+			{RecordE1RM, 0, E1RM(s.WeightKG, s.Reps)},
+			{RecordVolume, 0, s.WeightKG * float64(s.Reps)},
+			{RecordRepMax, s.Reps, s.WeightKG},
+		}
+		for _, c := range candidates {
+			k := recordKey{kind: c.kind, reps: c.reps}
+			prev, seen := best[k]
+			if seen && c.value <= prev {
+				continue
+			}
+			pr := PersonalRecord{SetID: s.ID, ExerciseID: exerciseID, Kind: c.kind, Reps: c.reps, ValueKG: c.value, AchievedAt: s.PerformedAt}
+			if seen {
+				p := prev
+				pr.PreviousValueKG = &p
+			}
+			want = append(want, pr)
+			best[k] = c.value
+		}
+	}
+
+	have, err := loadRecords(ctx, q, `ws.exercise_id = $2`, userID, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[recordKey]PersonalRecord{}
+	for _, pr := range have {
+		existing[recordKey{pr.SetID, pr.Kind, pr.Reps}] = pr
+	}
+
+	for i, pr := range want {
+		k := recordKey{pr.SetID, pr.Kind, pr.Reps}
+		old, ok := existing[k]
+		delete(existing, k)
+		if ok {
+			want[i].ID = old.ID
+			want[i].CreatedAt = old.CreatedAt
+			if old.ValueKG == pr.ValueKG && equalPtr(old.PreviousValueKG, pr.PreviousValueKG) && old.AchievedAt.Equal(pr.AchievedAt) {
+				continue
+			}
+			query := `UPDATE personal_records SET value_kg = $1, previous_value_kg = $2, achieved_at = $3 WHERE id = $4`
+			if _, err := q.ExecContext(ctx, query, pr.ValueKG, pr.PreviousValueKG, pr.AchievedAt, old.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		query := `INSERT INTO personal_records (set_id, kind, reps, value_kg, previous_value_kg, achieved_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+		if err := q.QueryRowContext(ctx, query, pr.SetID, pr.Kind, pr.Reps, pr.ValueKG, pr.PreviousValueKG, pr.AchievedAt).Scan(&want[i].ID, &want[i].CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	for _, old := range existing {
+		if _, err := q.ExecContext(ctx, `DELETE FROM personal_records WHERE id = $1`, old.ID); err != nil {
+			return nil, err
+		}
+	}
+	return want, nil
+}
+
+// RecomputeAllRecords runs RecomputeRecords for every exercise the user has
+// logged, as after an import or an account merge.
+func RecomputeAllRecords(ctx context.Context, q queryExecer, userID int) error {
+	query := `SELECT DISTINCT ws.exercise_id FROM workout_sets ws JOIN workout_sessions s ON s.id = ws.session_id WHERE s.user_id = $1`
+	rows, err := q.QueryContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	exerciseIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		exerciseIDs = append(exerciseIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range exerciseIDs {
+		if _, err := RecomputeRecords(ctx, q, userID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecomputeAllRecords recomputes every exercise of the user, opening its own
+// transaction and committing it once all of them are done.
+func (r *Repository) RecomputeAllRecords(ctx context.Context, userID int) error {
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := RecomputeAllRecords(ctx, tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadRecordSets returns the completed sets that can hold records, which
+// leaves out warm-ups, in the order they were performed. Sorting happens here
+// because SQLite orders timestamps stored with different offsets as text.
+func loadRecordSets(ctx context.Context, q queryExecer, userID, exerciseID int) ([]WorkoutSet, error) {
+	query := `
+        SELECT ws.id, ws.weight_kg, ws.reps, ws.performed_at
+        FROM workout_sets ws
+        JOIN workout_sessions s ON s.id = ws.session_id
+        WHERE s.user_id = $1 AND ws.exercise_id = $2 AND ws.status = $3 AND ws.set_type <> $4
+    `
+	rows, err := q.QueryContext(ctx, query, userID, exerciseID, SetCompleted, SetTypeWarmup)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := []WorkoutSet{}
+	for rows.Next() {
+		var s WorkoutSet
+		if err := rows.Scan(&s.ID, &s.WeightKG, &s.Reps, &s.PerformedAt); err != nil {
+			return nil, err
+		}
+		sets = append(sets, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(sets, func(i, j int) bool {
+		if !sets[i].PerformedAt.Equal(sets[j].PerformedAt) {
+			return sets[i].PerformedAt.Before(sets[j].PerformedAt)
+		}
+		return sets[i].ID < sets[j].ID
+	})
+	return sets, nil
+}
+
+// loadRecords returns the user's records matching where, which may refer to
+// pr and ws and to arguments from $2 on, oldest first.
+func loadRecords(ctx context.Context, q queryExecer, where string, userID int, args ...any) ([]PersonalRecord, error) {
+	query := `
+        SELECT pr.id, pr.set_id, ws.exercise_id, e.name, pr.kind, pr.reps, pr.value_kg, pr.previous_value_kg, pr.achieved_at, pr.created_at
+        FROM personal_records pr
+        JOIN workout_sets ws ON ws.id = pr.set_id
+        JOIN workout_sessions s ON s.id = ws.session_id
+        JOIN exercises e ON e.id = ws.exercise_id
+        WHERE s.user_id = $1 AND ` + where + `
+    `
+	rows, err := q.QueryContext(ctx, query, append([]any{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []PersonalRecord{}
+	for rows.Next() {
+		var pr PersonalRecord
+		if err := rows.Scan(&pr.ID, &pr.SetID, &pr.ExerciseID, &pr.ExerciseName, &pr.Kind, &pr.Reps, &pr.ValueKG, &pr.PreviousValueKG, &pr.AchievedAt, &pr.CreatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, pr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].AchievedAt.Equal(records[j].AchievedAt) {
+			return records[i].AchievedAt.Before(records[j].AchievedAt)
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records, nil
+}
+
+// ListRecords returns the standing record of each kind for an exercise and,
+// for rep maxes, each rep count. Each record beat the one before it, so the
+// highest value is the current one.
+func (r *Repository) ListRecords(ctx context.Context, userID, exerciseID int) ([]PersonalRecord, error) {
+	history, err := loadRecords(ctx, r.db.Pool, `ws.exercise_id = $2`, userID, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+	index := map[recordKey]int{}
+	current := []PersonalRecord{}
+	for _, pr := range history {
+		k := recordKey{kind: pr.Kind, reps: pr.Reps}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(current)
+			current = append(current, pr)
+		} else if pr.ValueKG > current[i].ValueKG {
+			current[i] = pr
+		}
+	}
+	return current, nil
+}
+
+// recordsForSet returns the records a set holds.
+func recordsForSet(ctx context.Context, q queryExecer, userID, setID int) ([]PersonalRecord, error) {
+	return loadRecords(ctx, q, `pr.set_id = $2`, userID, setID)
+}
+
+func equalPtr(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,218 @@
+package resistance
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"fitness-buddy/internal/testdb"
+)
+
+// fixture is one user with one exercise on a fresh SQLite database.
+type fixture struct {
+	t          *testing.T
+	repo       *Repository
+	userID     int
+	exerciseID int
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	db := testdb.SQLite(t)
+	f := &fixture{t: t, repo: NewRepository(db)}
+	if err := db.Pool.QueryRow(`INSERT INTO users (name, email) VALUES ('Lifter', 'lifter@example.com') RETURNING id`).Scan(&f.userID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Pool.QueryRow(`INSERT INTO exercises (name, category) VALUES ('Test lift', 'Strength') RETURNING id`).Scan(&f.exerciseID); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fixture) session(start time.Time) int {
+	f.t.Helper()
+	s, err := f.repo.CreateSession(f.t.Context(), f.userID, start, nil)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return s.ID
+}
+
+func (f *fixture) addSet(sessionID int, weight float64, reps int, setType string, at time.Time) *WorkoutSet {
+	f.t.Helper()
+	s, err := f.repo.AddSet(f.t.Context(), f.userID, sessionID, f.exerciseID, weight, reps, nil, SetDetails{SetType: setType}, at)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return s
+}
+
+// standing returns the current records as "kind" or "rep_max:N" mapped to
+// the value and the set holding it.
+func (f *fixture) standing() map[string][2]float64 {
+	f.t.Helper()
+	prs, err := f.repo.ListRecords(f.t.Context(), f.userID, f.exerciseID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return recordMap(prs)
+}
+
+func recordMap(prs []PersonalRecord) map[string][2]float64 {
+	m := map[string][2]float64{}
+	for _, pr := range prs {
+		key := pr.Kind
+		if pr.Kind == RecordRepMax {
+			key = fmt.Sprintf("%s:%d", pr.Kind, pr.Reps)
+		}
+		m[key] = [2]float64{pr.ValueKG, float64(pr.SetID)}
+	}
+	return m
+}
+
+var day = time.Date(2026, time.March, 2, 18, 0, 0, 0, time.UTC)
+
+func TestAddSetReturnsNewRecords(t *testing.T) {
+	f := newFixture(t)
+	session := f.session(day)
+
+	first := f.addSet(session, 100, 5, SetTypeNormal, day)
+	id := float64(first.ID)
+	want := map[string][2]float64{
+		RecordMaxWeight:     {100, id},
+		RecordE1RM:          {112.5, id},
+		RecordVolume:        {500, id},
+		RecordRepMax + ":5": {100, id},
+	}
+	if got := recordMap(first.PersonalRecords); !reflect.DeepEqual(got, want) {
+		t.Errorf("first set records = %v, want %v", got, want)
+	}
+
+	if lighter := f.addSet(session, 90, 5, SetTypeNormal, day.Add(time.Minute)); len(lighter.PersonalRecords) != 0 {
+		t.Errorf("lighter set records = %v, want none", recordMap(lighter.PersonalRecords))
+	}
+
+	// Heavier, but fewer reps: a new max weight and 3-rep max only.
+	heavy := f.addSet(session, 105, 3, SetTypeNormal, day.Add(2*time.Minute))
+	id = float64(heavy.ID)
+	want = map[string][2]float64{
+		RecordMaxWeight:     {105, id},
+		RecordRepMax + ":3": {105, id},
+	}
+	if got := recordMap(heavy.PersonalRecords); !reflect.DeepEqual(got, want) {
+		t.Errorf("heavy set records = %v, want %v", got, want)
+	}
+	for _, pr := range heavy.PersonalRecords {
+		if pr.Kind == RecordMaxWeight && (pr.PreviousValueKG == nil || *pr.PreviousValueKG != 100) {
+			t.Errorf("max weight previous = %v, want 100", pr.PreviousValueKG)
+		}
+	}
+}
+
+func TestDeleteSetRevokesRecords(t *testing.T) {
+	f := newFixture(t)
+	session := f.session(day)
+	first := f.addSet(session, 100, 5, SetTypeNormal, day)
+	best := f.addSet(session, 110, 5, SetTypeNormal, day.Add(time.Minute))
+	if got := f.standing()[RecordMaxWeight]; got != [2]float64{110, float64(best.ID)} {
+		t.Fatalf("max weight = %v before delete", got)
+	}
+
+	if err := f.repo.DeleteSet(t.Context(), f.userID, best.ID); err != nil {
+		t.Fatal(err)
+	}
+	id := float64(first.ID)
+	want := map[string][2]float64{
+		RecordMaxWeight:     {100, id},
+		RecordE1RM:          {112.5, id},
+		RecordVolume:        {500, id},
+		RecordRepMax + ":5": {100, id},
+	}
+	if got := f.standing(); !reflect.DeepEqual(got, want) {
+		t.Errorf("records after delete = %v, want %v", got, want)
+	}
+}
+
+func TestEditSetDownMovesRecordToNextBest(t *testing.T) {
+	f := newFixture(t)
+	session := f.session(day)
+	f.addSet(session, 100, 5, SetTypeNormal, day)
+	edited := f.addSet(session, 120, 5, SetTypeNormal, day.Add(time.Minute))
+	next := f.addSet(session, 110, 5, SetTypeNormal, day.Add(2*time.Minute))
+	if len(next.PersonalRecords) != 0 {
+		t.Fatalf("110 after 120 set records %v", recordMap(next.PersonalRecords))
+	}
+
+	updated, err := f.repo.UpdateSet(t.Context(), f.userID, edited.ID, 90, 5, nil, SetDetailsUpdate{}, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.PersonalRecords) != 0 {
+		t.Errorf("edited set still holds %v", recordMap(updated.PersonalRecords))
+	}
+	got := f.standing()
+	if got[RecordMaxWeight] != [2]float64{110, float64(next.ID)} {
+		t.Errorf("max weight = %v, want 110 on set %d", got[RecordMaxWeight], next.ID)
+	}
+	if got[RecordRepMax+":5"] != [2]float64{110, float64(next.ID)} {
+		t.Errorf("5-rep max = %v, want 110 on set %d", got[RecordRepMax+":5"], next.ID)
+	}
+}
+
+func TestWarmupsNeverSetRecords(t *testing.T) {
+	f := newFixture(t)
+	session := f.session(day)
+
+	warmup := f.addSet(session, 200, 5, SetTypeWarmup, day)
+	if len(warmup.PersonalRecords) != 0 {
+		t.Errorf("warm-up set records %v", recordMap(warmup.PersonalRecords))
+	}
+	work := f.addSet(session, 100, 5, SetTypeNormal, day.Add(time.Minute))
+	if got := recordMap(work.PersonalRecords)[RecordMaxWeight]; got != [2]float64{100, float64(work.ID)} {
+		t.Errorf("work set max weight = %v, want 100", got)
+	}
+
+	// Turning a work set into a warm-up gives up its records.
+	if _, err := f.repo.UpdateSet(t.Context(), f.userID, work.ID, 100, 5, nil, SetDetailsUpdate{SetDetails: SetDetails{SetType: SetTypeWarmup}}, day); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.standing(); len(got) != 0 {
+		t.Errorf("records with only warm-ups = %v, want none", got)
+	}
+}
+
+func TestUnfinishedSetsNeverCount(t *testing.T) {
+	f := newFixture(t)
+	session := f.session(day)
+	work := f.addSet(session, 100, 5, SetTypeNormal, day)
+	f.addSet(session, 200, 5, SetTypeWarmup, day)
+	for order, status := range map[int]string{3: SetPlanned, 4: SetSkipped} {
+		if _, err := f.repo.db.Pool.Exec(`INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, performed_at, status) VALUES ($1, $2, $3, 300, 5, $4, $5)`, session, f.exerciseID, order, day, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := f.repo.RecomputeAllRecords(t.Context(), f.userID); err != nil {
+		t.Fatal(err)
+	}
+	id := float64(work.ID)
+	want := map[string][2]float64{
+		RecordMaxWeight:     {100, id},
+		RecordE1RM:          {112.5, id},
+		RecordVolume:        {500, id},
+		RecordRepMax + ":5": {100, id},
+	}
+	if got := f.standing(); !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want only the completed work set's %v", got, want)
+	}
+
+	midnight := day.Truncate(24 * time.Hour)
+	h, err := f.repo.ExerciseHistory(t.Context(), f.userID, f.exerciseID, midnight, midnight, time.UTC, 20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Sessions) != 1 || len(h.Sessions[0].Sets) != 2 || h.Sessions[0].VolumeKG != 500 || h.Sessions[0].TopSet.ID != work.ID {
+		t.Errorf("history = %+v, want the work set and warm-up with 500 kg of volume", h.Sessions)
+	}
+}
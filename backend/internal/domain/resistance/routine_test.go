@@ -0,0 +1,161 @@
+package resistance
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"fitness-buddy/internal/database"
+)
+
+// routineFixture is a routine of three exercises: the fixture's, with three
+// target sets of 8 to 12 reps; a second without targets done twice last
+// time; and a third never done before.
+type routineFixture struct {
+	*fixture
+	second, third int
+	routine       *Routine
+}
+
+func newRoutineFixture(t *testing.T) *routineFixture {
+	t.Helper()
+	f := &routineFixture{fixture: newFixture(t)}
+	for name, id := range map[string]*int{"Second lift": &f.second, "Third lift": &f.third} {
+		if err := f.repo.db.Pool.QueryRow(`INSERT INTO exercises (name, category) VALUES ($1, 'Strength') RETURNING id`, name).Scan(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last := f.session(day.AddDate(0, 0, -7))
+	f.addSet(last, 60, 5, SetTypeWarmup, day)
+	f.addSet(last, 100, 5, SetTypeNormal, day)
+	f.addSet(last, 105, 5, SetTypeNormal, day)
+	for _, reps := range []int{10, 9} {
+		if _, err := f.repo.AddSet(t.Context(), f.userID, last, f.second, 60, reps, nil, SetDetails{}, day); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rt, err := f.repo.CreateRoutine(t.Context(), f.userID, "Push", nil, []RoutineExercise{
+		{ExerciseID: f.exerciseID, TargetSets: ptr(3), RepRangeMin: ptr(8), RepRangeMax: ptr(12)},
+		{ExerciseID: f.second},
+		{ExerciseID: f.third},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.routine = rt
+	return f
+}
+
+type plannedSet struct {
+	exerciseID int
+	weight     float64
+	reps       int
+	status     string
+}
+
+func plan(sets []WorkoutSet) []plannedSet {
+	out := []plannedSet{}
+	for i, s := range sets {
+		if s.SetOrder != i+1 {
+			return nil
+		}
+		out = append(out, plannedSet{s.ExerciseID, s.WeightKG, s.Reps, s.Status})
+	}
+	return out
+}
+
+func TestStartRoutineLaysOutSets(t *testing.T) {
+	f := newRoutineFixture(t)
+	start, err := f.repo.StartRoutine(t.Context(), f.userID, f.routine.ID, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Target sets repeat the last set when last time had fewer, reps start
+	// at the bottom of the range, and the warm-up is left behind.
+	want := []plannedSet{
+		{f.exerciseID, 100, 8, SetPlanned},
+		{f.exerciseID, 105, 8, SetPlanned},
+		{f.exerciseID, 105, 8, SetPlanned},
+		{f.second, 60, 10, SetPlanned},
+		{f.second, 60, 9, SetPlanned},
+		{f.third, 0, 0, SetPlanned},
+	}
+	if got := plan(start.Session.Sets); !reflect.DeepEqual(got, want) {
+		t.Errorf("planned sets = %+v, want %+v", got, want)
+	}
+	if !start.Session.StartTime.Equal(day) || start.Routine.ID != f.routine.ID {
+		t.Errorf("session starts %v on routine %d", start.Session.StartTime, start.Routine.ID)
+	}
+
+	var recs []int
+	for _, rec := range start.Recommendations {
+		recs = append(recs, rec.ExerciseID)
+	}
+	if !reflect.DeepEqual(recs, []int{f.exerciseID, f.second, f.third}) {
+		t.Errorf("recommendations for %v, want one per exercise in routine order", recs)
+	}
+
+	if _, err := f.repo.StartRoutine(t.Context(), f.userID+1, f.routine.ID, day); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("starting another user's routine = %v, want not found", err)
+	}
+}
+
+func TestFinishSessionSkipsPlannedSets(t *testing.T) {
+	f := newRoutineFixture(t)
+	start, err := f.repo.StartRoutine(t.Context(), f.userID, f.routine.ID, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := start.Session.ID
+	planned := start.Session.Sets
+
+	// Two of three sets of the first exercise, none of the second, the
+	// third's planned set and one more.
+	for _, s := range []WorkoutSet{planned[0], planned[1], planned[5]} {
+		if _, err := f.repo.UpdateSet(t.Context(), f.userID, s.ID, 100, 8, nil, SetDetailsUpdate{}, day.Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.repo.AddSet(t.Context(), f.userID, session, f.third, 20, 10, nil, SetDetails{}, day.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	end := day.Add(time.Hour)
+	summary, err := f.repo.FinishSession(t.Context(), f.userID, session, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Completed != 4 || summary.Skipped != 3 || !summary.EndTime.Equal(end) {
+		t.Errorf("summary = %d completed, %d skipped, ended %v; want 4, 3", summary.Completed, summary.Skipped, summary.EndTime)
+	}
+	type counts struct{ id, completed, skipped int }
+	got := []counts{}
+	for _, es := range summary.Exercises {
+		got = append(got, counts{es.ExerciseID, es.Completed, es.Skipped})
+	}
+	want := []counts{{f.exerciseID, 2, 1}, {f.second, 0, 2}, {f.third, 2, 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exercises = %+v, want %+v", got, want)
+	}
+
+	sets, err := f.repo.GetSetsForSession(t.Context(), session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{}
+	for _, s := range sets {
+		statuses = append(statuses, s.Status)
+	}
+	wantStatuses := []string{SetCompleted, SetCompleted, SetSkipped, SetSkipped, SetSkipped, SetCompleted, SetCompleted}
+	if !reflect.DeepEqual(statuses, wantStatuses) {
+		t.Errorf("statuses after finishing = %v, want %v", statuses, wantStatuses)
+	}
+
+	if _, err := f.repo.FinishSession(t.Context(), f.userID+1, session, end); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("finishing another user's session = %v, want not found", err)
+	}
+}
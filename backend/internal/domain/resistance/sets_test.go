@@ -0,0 +1,76 @@
+package resistance
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateSetRequestNull(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want SetDetailsUpdate
+	}{
+		{"left out", `{"weight_kg": 100, "reps": 5}`, SetDetailsUpdate{}},
+		{"values", `{"weight_kg": 100, "reps": 5, "superset_group": 2, "rir": 1, "tempo": "31x0"}`,
+			SetDetailsUpdate{SetDetails: SetDetails{SupersetGroup: ptr(2), RIR: ptr(1), Tempo: ptr("31X0")}}},
+		{"nulls", `{"weight_kg": 100, "reps": 5, "superset_group": null, "rir": null, "tempo": null}`,
+			SetDetailsUpdate{ClearSupersetGroup: true, ClearRIR: true, ClearTempo: true}},
+		{"one null", `{"weight_kg": 100, "reps": 5, "set_type": "drop", "rir": null}`,
+			SetDetailsUpdate{SetDetails: SetDetails{SetType: SetTypeDrop}, ClearRIR: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req UpdateSetRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatal(err)
+			}
+			got, err := req.details()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("details = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var req UpdateSetRequest
+	if err := json.Unmarshal([]byte(`{"weight_kg": 100, "reps": 5, "rir": "two"}`), &req); err == nil {
+		t.Error("a string rir was accepted")
+	}
+}
+
+func TestUpdateSetClearsDetails(t *testing.T) {
+	f := newFixture(t)
+	session := f.session(day)
+	details := SetDetails{SetType: SetTypeDrop, SupersetGroup: ptr(1), RIR: ptr(2), Tempo: ptr("31X0")}
+	s, err := f.repo.AddSet(t.Context(), f.userID, session, f.exerciseID, 100, 5, nil, details, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name   string
+		update SetDetailsUpdate
+		want   SetDetails
+	}{
+		{"nothing sent keeps everything", SetDetailsUpdate{}, details},
+		{"a value replaces", SetDetailsUpdate{SetDetails: SetDetails{RIR: ptr(0)}},
+			SetDetails{SetType: SetTypeDrop, SupersetGroup: ptr(1), RIR: ptr(0), Tempo: ptr("31X0")}},
+		{"a clear empties only its field", SetDetailsUpdate{ClearSupersetGroup: true},
+			SetDetails{SetType: SetTypeDrop, RIR: ptr(0), Tempo: ptr("31X0")}},
+		{"clear the rest", SetDetailsUpdate{ClearRIR: true, ClearTempo: true}, SetDetails{SetType: SetTypeDrop}},
+		{"set again", SetDetailsUpdate{SetDetails: SetDetails{SupersetGroup: ptr(3)}}, SetDetails{SetType: SetTypeDrop, SupersetGroup: ptr(3)}},
+	}
+	for _, step := range steps {
+		updated, err := f.repo.UpdateSet(t.Context(), f.userID, s.ID, 100, 5, nil, step.update, day)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if !reflect.DeepEqual(updated.SetDetails, step.want) {
+			t.Errorf("%s: details = %+v, want %+v", step.name, updated.SetDetails, step.want)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package running
 
 import (
 	"encoding/json"
+	"errors"
 	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/database"
 	"net/http"
 	"strconv"
 	"time"
@@ -27,7 +29,10 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 }
 
 func (h *Handler) ListRuns(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	runs, err := h.repo.ListRuns(r.Context(), userID, 100)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -62,8 +67,15 @@ func (h *Handler) CreateRun(w http.ResponseWriter, r *http.Request) {
 		req.StartTime = time.Now()
 	}
 
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	run, err := h.repo.CreateRun(r.Context(), userID, req.StartTime, req.DurationSeconds, req.DistanceMeters, req.ElevationGain, req.AvgHeartRate, req.Cadence, req.RelativeEffort, req.ShoeID, req.Steps, req.RouteData, req.RunType, req.Notes)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Shoe not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -78,7 +90,16 @@ func (h *Handler) DeleteRun(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid run ID", http.StatusBadRequest)
 		return
 	}
-	if err := h.repo.DeleteRun(r.Context(), id); err != nil {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	err = h.repo.DeleteRun(r.Context(), userID, id)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -86,7 +107,10 @@ func (h *Handler) DeleteRun(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ListShoes(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	shoes, err := h.repo.ListShoes(r.Context(), userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -104,7 +128,10 @@ func (h *Handler) CreateShoe(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	userID := auth.GetUserID(r.Context())
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
 	shoe, err := h.repo.CreateShoe(r.Context(), userID, req.Brand, req.Model)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -2,6 +2,7 @@ package running
 
 import (
 	"context"
+	"database/sql"
 	"fitness-buddy/internal/database"
 	"time"
 )
@@ -15,6 +16,12 @@ func NewRepository(db *database.DB) *Repository {
 }
 
 func (r *Repository) CreateRun(ctx context.Context, userID int, startTime time.Time, duration int, distance, elevation float64, avgHR, cadence, effort, shoeID, steps *int, routeData, runType, notes *string) (*Run, error) {
+	if shoeID != nil {
+		if err := r.requireShoe(ctx, userID, *shoeID); err != nil {
+			return nil, err
+		}
+	}
+
 	query := `
         INSERT INTO runs (user_id, start_time, duration_seconds, distance_meters, elevation_gain_meters, avg_heart_rate, cadence, relative_effort, shoe_id, steps, route_data, run_type, notes)
         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
@@ -106,7 +113,29 @@ func (r *Repository) CreateShoe(ctx context.Context, userID int, brand, model st
 	return &Shoe{ID: newID, UserID: userID, Brand: brand, Model: model, IsActive: true}, nil
 }
 
-func (r *Repository) DeleteRun(ctx context.Context, id int) error {
-	_, err := r.db.Pool.ExecContext(ctx, "DELETE FROM runs WHERE id = $1", id)
-	return err
+func (r *Repository) DeleteRun(ctx context.Context, userID, id int) error {
+	return database.RequireAffected(r.db.Pool.ExecContext(ctx, "DELETE FROM runs WHERE id = $1 AND user_id = $2", id, userID))
+}
+
+func (r *Repository) requireShoe(ctx context.Context, userID, shoeID int) error {
+	var count int
+	if err := r.db.Pool.QueryRowContext(ctx, "SELECT COUNT(*) FROM shoes WHERE id = $1 AND user_id = $2", shoeID, userID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+// PurgeUser deletes the user's runs and shoes inside tx, as part of deleting
+// the account. Runs go first since they point at shoes.
+func (r *Repository) PurgeUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM runs WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM shoes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return nil
 }
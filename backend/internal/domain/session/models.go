@@ -0,0 +1,22 @@
+package session
+
+import (
+	"time"
+)
+
+// Session is one signed-in device. Its refresh tokens form a family: each
+// refresh swaps the presented token for a new one, and replaying a token
+// that was already swapped revokes the session.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"user_id"`
+	UserAgent  *string    `json:"user_agent"`
+	IPAddress  *string    `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Current marks the session the request was made from.
+	Current bool `json:"current"`
+}
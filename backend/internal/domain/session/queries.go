@@ -0,0 +1,284 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"fitness-buddy/internal/database"
+)
+
+var (
+	// ErrInvalidToken is returned for refresh tokens that are unknown,
+	// expired, or belong to a revoked session.
+	ErrInvalidToken = errors.New("invalid refresh token")
+	// ErrTokenReused is returned when an already rotated refresh token is
+	// presented again. The session has been revoked by the time it is seen.
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+// lastSeenGranularity keeps Touch from writing on every request.
+const lastSeenGranularity = time.Minute
+
+type Repository struct {
+	db *database.DB
+}
+
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is what gets stored; the plaintext refresh token only ever
+// lives in the client's cookie.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Create opens a session for the user and returns it together with its first
+// refresh token.
+func (r *Repository) Create(ctx context.Context, userID int, userAgent, ip string, ttl time.Duration) (*Session, string, error) {
+	id, err := randomString(16)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := randomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now().UTC()
+
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO sessions (id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at) VALUES ($1, $2, $3, $4, $5, $5, $6)`,
+		id, userID, nullable(userAgent), nullable(ip), now, now.Add(ttl))
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO refresh_tokens (token_hash, session_id, created_at) VALUES ($1, $2, $3)`, hashToken(token), id, now)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+
+	return &Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  nullable(userAgent),
+		IPAddress:  nullable(ip),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}, token, nil
+}
+
+// Rotate exchanges a refresh token for a new one and slides the session's
+// expiry forward. A token can be exchanged once; a second attempt means it
+// leaked, so the whole session is revoked and ErrTokenReused returned.
+func (r *Repository) Rotate(ctx context.Context, token, userAgent, ip string, ttl time.Duration) (*Session, string, error) {
+	hash := hashToken(token)
+	now := time.Now().UTC()
+
+	tx, err := r.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	var s Session
+	var usedAt *time.Time
+	query := `SELECT s.id, s.user_id, s.user_agent, s.ip_address, s.created_at, s.last_seen_at, s.expires_at, s.revoked_at, t.used_at
+		FROM refresh_tokens t JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1`
+	err = tx.QueryRowContext(ctx, query, hash).Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrInvalidToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if s.RevokedAt != nil || now.After(s.ExpiresAt) {
+		return nil, "", ErrInvalidToken
+	}
+
+	// A token that was already exchanged is being replayed: whoever holds
+	// it, the session can no longer be trusted. The conditional update also
+	// catches the case where a concurrent refresh claimed it first.
+	reused := usedAt != nil
+	if !reused {
+		res, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL`, now, hash)
+		err = database.RequireAffected(res, err)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return nil, "", err
+		}
+		reused = err != nil
+	}
+	if reused {
+		if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = $1 WHERE id = $2`, now, s.ID); err != nil {
+			return nil, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrTokenReused
+	}
+
+	next, err := randomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO refresh_tokens (token_hash, session_id, created_at) VALUES ($1, $2, $3)`, hashToken(next), s.ID, now); err != nil {
+		return nil, "", err
+	}
+	s.LastSeenAt = now
+	s.ExpiresAt = now.Add(ttl)
+	s.UserAgent = nullable(userAgent)
+	s.IPAddress = nullable(ip)
+	_, err = tx.ExecContext(ctx, `UPDATE sessions SET last_seen_at = $1, expires_at = $2, user_agent = $3, ip_address = $4 WHERE id = $5`,
+		s.LastSeenAt, s.ExpiresAt, s.UserAgent, s.IPAddress, s.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return &s, next, nil
+}
+
+// Lookup returns the live session a refresh token belongs to, without
+// exchanging the token. A used, revoked or expired token gives
+// ErrInvalidToken.
+func (r *Repository) Lookup(ctx context.Context, token string) (*Session, error) {
+	var s Session
+	query := `SELECT s.id, s.user_id, s.user_agent, s.ip_address, s.created_at, s.last_seen_at, s.expires_at, s.revoked_at
+		FROM refresh_tokens t JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1 AND t.used_at IS NULL AND s.revoked_at IS NULL`
+	err := r.db.Pool.QueryRowContext(ctx, query, hashToken(token)).Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(s.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return &s, nil
+}
+
+// Touch reports whether the session is still live for the user and records
+// the request as activity. Writes are throttled to lastSeenGranularity.
+func (r *Repository) Touch(ctx context.Context, userID int, sessionID string) (bool, error) {
+	var lastSeen, expires time.Time
+	var revoked *time.Time
+	query := `SELECT last_seen_at, expires_at, revoked_at FROM sessions WHERE id = $1 AND user_id = $2`
+	err := r.db.Pool.QueryRowContext(ctx, query, sessionID, userID).Scan(&lastSeen, &expires, &revoked)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().UTC()
+	if revoked != nil || now.After(expires) {
+		return false, nil
+	}
+	if now.Sub(lastSeen) >= lastSeenGranularity {
+		if _, err := r.db.Pool.ExecContext(ctx, `UPDATE sessions SET last_seen_at = $1 WHERE id = $2`, now, sessionID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ListActive returns the user's sessions that can still be refreshed, most
+// recently used first.
+func (r *Repository) ListActive(ctx context.Context, userID int) ([]Session, error) {
+	query := `SELECT id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_seen_at DESC`
+	rows, err := r.db.Pool.QueryContext(ctx, query, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke ends one of the user's sessions.
+func (r *Repository) Revoke(ctx context.Context, userID int, sessionID string) error {
+	res, err := r.db.Pool.ExecContext(ctx, `UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`, time.Now().UTC(), sessionID, userID)
+	return database.RequireAffected(res, err)
+}
+
+// RevokeByToken ends the session a refresh token belongs to. Unknown tokens
+// are ignored so that logging out never fails.
+func (r *Repository) RevokeByToken(ctx context.Context, token string) error {
+	query := `UPDATE sessions SET revoked_at = $1
+		WHERE revoked_at IS NULL AND id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $2)`
+	_, err := r.db.Pool.ExecContext(ctx, query, time.Now().UTC(), hashToken(token))
+	return err
+}
+
+// RevokeAll ends every session the user has, optionally sparing one (the
+// caller's own) by ID.
+func (r *Repository) RevokeAll(ctx context.Context, userID int, exceptID string) error {
+	_, err := r.db.Pool.ExecContext(ctx, `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL AND id <> $3`, time.Now().UTC(), userID, exceptID)
+	return err
+}
+
+// Get returns one of the user's sessions, revoked or not.
+func (r *Repository) Get(ctx context.Context, userID int, sessionID string) (*Session, error) {
+	var s Session
+	query := `SELECT id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at FROM sessions WHERE id = $1 AND user_id = $2`
+	err := r.db.Pool.QueryRowContext(ctx, query, sessionID, userID).Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// PurgeUser deletes the user's sessions inside tx, as part of deleting the
+// account. Refresh tokens cascade.
+func (r *Repository) PurgeUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID)
+	return err
+}
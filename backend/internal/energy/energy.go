@@ -0,0 +1,244 @@
+// Package energy estimates how much a user burns and should eat from their
+// profile and latest body metrics.
+//
+// BMR comes from Mifflin-St Jeor, or from Katch-McArdle when a body fat
+// reading exists, since lean mass predicts it better than height and age.
+// TDEE multiplies BMR by the profile's activity level, the calorie target
+// adjusts TDEE for the weight goal, and the macro split follows from the
+// target. Nothing is stored: targets are worked out on every request, so they
+// follow each weigh-in and profile edit as soon as it is saved.
+package energy
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Activity is a profile activity level and its TDEE multiplier.
+type Activity struct {
+	Level  string  `json:"level"`
+	Factor float64 `json:"factor"`
+}
+
+var Activities = []Activity{
+	{"Sedentary", 1.2},
+	{"Lightly Active", 1.375},
+	{"Moderately Active", 1.55},
+	{"Very Active", 1.725},
+	{"Extra Active", 1.9},
+}
+
+// Goal is a profile weight goal: the daily surplus or deficit it calls for
+// and how much protein to eat per kg of body weight while pursuing it.
+type Goal struct {
+	Name           string  `json:"name"`
+	AdjustmentKcal int     `json:"adjustment_kcal"`
+	ProteinGPerKg  float64 `json:"protein_g_per_kg"`
+}
+
+var Goals = []Goal{
+	{"Lose Weight", -500, 2.0},
+	{"Maintain", 0, 1.6},
+	{"Gain Weight", 500, 1.8},
+}
+
+const (
+	// fatShare of the calorie target goes to fat; carbs get the rest.
+	fatShare = 0.25
+
+	kcalPerGProtein = 4
+	kcalPerGCarbs   = 4
+	kcalPerGFat     = 9
+)
+
+func ParseActivity(level string) (Activity, error) {
+	for _, a := range Activities {
+		if strings.EqualFold(a.Level, strings.TrimSpace(level)) {
+			return a, nil
+		}
+	}
+	return Activity{}, fmt.Errorf("invalid activity level %q", level)
+}
+
+func ParseGoal(name string) (Goal, error) {
+	for _, g := range Goals {
+		if strings.EqualFold(g.Name, strings.TrimSpace(name)) {
+			return g, nil
+		}
+	}
+	return Goal{}, fmt.Errorf("invalid weight goal %q", name)
+}
+
+// MifflinStJeor returns BMR in kcal/day. sexOffset is +5 for men and -161
+// for women; see SexOffset.
+func MifflinStJeor(weightKG, heightCM float64, age int, sexOffset float64) float64 {
+	return 10*weightKG + 6.25*heightCM - 5*float64(age) + sexOffset
+}
+
+// SexOffset is the constant Mifflin-St Jeor adds for sex. Anything other
+// than M or F gets the midpoint of the two.
+func SexOffset(sex string) float64 {
+	switch strings.ToUpper(sex) {
+	case "M":
+		return 5
+	case "F":
+		return -161
+	}
+	return -78
+}
+
+// KatchMcArdle returns BMR in kcal/day from lean body mass.
+func KatchMcArdle(weightKG, bodyFatPercent float64) float64 {
+	return 370 + 21.6*LeanMassKG(weightKG, bodyFatPercent)
+}
+
+func LeanMassKG(weightKG, bodyFatPercent float64) float64 {
+	return weightKG * (1 - bodyFatPercent/100)
+}
+
+// Inputs are what targets are computed from. Pointers are nil when the
+// profile or body metrics don't have the value.
+type Inputs struct {
+	WeightKG       *float64 `json:"weight_kg"`
+	HeightCM       *float64 `json:"height_cm"`
+	Age            *int     `json:"age"`
+	Sex            *string  `json:"sex"`
+	BodyFatPercent *float64 `json:"body_fat_percent"`
+	ActivityLevel  string   `json:"activity_level"`
+	WeightGoal     string   `json:"weight_goal"`
+}
+
+// Estimate is one computed figure with the formula behind it, written out
+// with the user's numbers.
+type Estimate struct {
+	Formula    string `json:"formula"`
+	Expression string `json:"expression"`
+	Kcal       int    `json:"kcal"`
+}
+
+type Macros struct {
+	ProteinG   int    `json:"protein_g"`
+	FatG       int    `json:"fat_g"`
+	CarbsG     int    `json:"carbs_g"`
+	Expression string `json:"expression"`
+}
+
+type Targets struct {
+	Inputs Inputs `json:"inputs"`
+
+	// BMR is the estimate used for everything below. BMREstimates lists
+	// every formula the inputs allow.
+	BMR           Estimate   `json:"bmr"`
+	BMREstimates  []Estimate `json:"bmr_estimates"`
+	TDEE          Estimate   `json:"tdee"`
+	CalorieTarget Estimate   `json:"calorie_target"`
+	Macros        Macros     `json:"macros"`
+}
+
+// MissingInputsError lists what the profile needs before any BMR formula can
+// be applied.
+type MissingInputsError struct {
+	Fields []string
+}
+
+func (e *MissingInputsError) Error() string {
+	return "not enough data to estimate energy needs, missing " + strings.Join(e.Fields, ", ")
+}
+
+// Compute works out the targets for in.
+func Compute(in Inputs) (*Targets, error) {
+	activity, err := ParseActivity(in.ActivityLevel)
+	if err != nil {
+		return nil, err
+	}
+	goal, err := ParseGoal(in.WeightGoal)
+	if err != nil {
+		return nil, err
+	}
+	if in.WeightKG == nil {
+		return nil, &MissingInputsError{Fields: []string{"weight_kg"}}
+	}
+	weight := *in.WeightKG
+
+	t := &Targets{Inputs: in}
+	if in.BodyFatPercent != nil {
+		bf := *in.BodyFatPercent
+		t.BMREstimates = append(t.BMREstimates, Estimate{
+			Formula:    "katch_mcardle",
+			Expression: fmt.Sprintf("370 + 21.6 * %s kg lean mass (%s kg * (1 - %s%% body fat))", num(LeanMassKG(weight, bf)), num(weight), num(bf)),
+			Kcal:       kcal(KatchMcArdle(weight, bf)),
+		})
+	}
+	if in.HeightCM != nil && in.Age != nil {
+		sex := ""
+		if in.Sex != nil {
+			sex = *in.Sex
+		}
+		offset := SexOffset(sex)
+		t.BMREstimates = append(t.BMREstimates, Estimate{
+			Formula:    "mifflin_st_jeor",
+			Expression: fmt.Sprintf("10 * %s kg + 6.25 * %s cm - 5 * %d y %s %s", num(weight), num(*in.HeightCM), *in.Age, sign(offset), num(math.Abs(offset))),
+			Kcal:       kcal(MifflinStJeor(weight, *in.HeightCM, *in.Age, offset)),
+		})
+	}
+	if len(t.BMREstimates) == 0 {
+		missing := []string{}
+		if in.HeightCM == nil {
+			missing = append(missing, "height_cm")
+		}
+		if in.Age == nil {
+			missing = append(missing, "dob")
+		}
+		return nil, &MissingInputsError{Fields: append(missing, "or body_fat_percent")}
+	}
+	t.BMR = t.BMREstimates[0]
+
+	tdee := float64(t.BMR.Kcal) * activity.Factor
+	t.TDEE = Estimate{
+		Formula:    "bmr_times_activity",
+		Expression: fmt.Sprintf("%d kcal * %g (%s)", t.BMR.Kcal, activity.Factor, activity.Level),
+		Kcal:       kcal(tdee),
+	}
+
+	// A deficit never takes the target below BMR.
+	target := max(float64(t.TDEE.Kcal+goal.AdjustmentKcal), float64(t.BMR.Kcal))
+	t.CalorieTarget = Estimate{
+		Formula:    "tdee_plus_goal",
+		Expression: fmt.Sprintf("%d kcal %s %d (%s), not below BMR", t.TDEE.Kcal, sign(float64(goal.AdjustmentKcal)), abs(goal.AdjustmentKcal), goal.Name),
+		Kcal:       kcal(target),
+	}
+
+	protein := goal.ProteinGPerKg * weight
+	fat := float64(t.CalorieTarget.Kcal) * fatShare / kcalPerGFat
+	carbs := max(float64(t.CalorieTarget.Kcal)-protein*kcalPerGProtein-fat*kcalPerGFat, 0) / kcalPerGCarbs
+	t.Macros = Macros{
+		ProteinG:   int(math.Round(protein)),
+		FatG:       int(math.Round(fat)),
+		CarbsG:     int(math.Round(carbs)),
+		Expression: fmt.Sprintf("protein %s g/kg * %s kg, fat %d%% of calories, carbs the rest", num(goal.ProteinGPerKg), num(weight), int(fatShare*100)),
+	}
+	return t, nil
+}
+
+func kcal(f float64) int {
+	return int(math.Round(f))
+}
+
+func num(f float64) string {
+	return fmt.Sprintf("%g", math.Round(f*10)/10)
+}
+
+func sign(f float64) string {
+	if f < 0 {
+		return "-"
+	}
+	return "+"
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,51 @@
+package energy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"fitness-buddy/internal/auth"
+	"fitness-buddy/internal/calendar"
+	"fitness-buddy/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	repo *Repository
+}
+
+func NewHandler(repo *Repository) *Handler {
+	return &Handler{repo: repo}
+}
+
+func (h *Handler) RegisterRoutes(r chi.Router) {
+	r.Get("/user/targets", h.GetTargets)
+}
+
+func (h *Handler) GetTargets(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now().In(calendar.Location(r.Context()))
+	in, err := h.repo.Inputs(r.Context(), userID, now)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	targets, err := Compute(*in)
+	if err != nil {
+		// Missing or unrecognised profile data is for the user to fix.
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	json.NewEncoder(w).Encode(targets)
+}
@@ -0,0 +1,85 @@
+package energy
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"fitness-buddy/internal/calendar"
+	"fitness-buddy/internal/database"
+)
+
+type Repository struct {
+	db *database.DB
+}
+
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Inputs reads the profile and the latest weight and body fat readings.
+// Weight and body fat may come from different weigh-ins. now is the moment
+// age is taken at.
+func (r *Repository) Inputs(ctx context.Context, userID int, now time.Time) (*Inputs, error) {
+	var in Inputs
+	var dob, activity, goal sql.NullString
+	query := `SELECT height_cm, dob, sex, activity_level, weight_goal FROM users WHERE id = $1`
+	err := r.db.Pool.QueryRowContext(ctx, query, userID).Scan(&in.HeightCM, &dob, &in.Sex, &activity, &goal)
+	if err == sql.ErrNoRows {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	in.ActivityLevel = Activities[0].Level
+	if activity.Valid {
+		in.ActivityLevel = activity.String
+	}
+	in.WeightGoal = "Maintain"
+	if goal.Valid {
+		in.WeightGoal = goal.String
+	}
+	if dob.Valid {
+		if age, ok := ageAt(dob.String, now); ok {
+			in.Age = &age
+		}
+	}
+
+	if in.WeightKG, err = r.latest(ctx, userID, "weight_kg"); err != nil {
+		return nil, err
+	}
+	if in.BodyFatPercent, err = r.latest(ctx, userID, "body_fat_percent"); err != nil {
+		return nil, err
+	}
+	return &in, nil
+}
+
+func (r *Repository) latest(ctx context.Context, userID int, column string) (*float64, error) {
+	var v float64
+	query := `SELECT ` + column + ` FROM body_metrics WHERE user_id = $1 AND ` + column + ` IS NOT NULL ORDER BY recorded_at DESC, id DESC LIMIT 1`
+	err := r.db.Pool.QueryRowContext(ctx, query, userID).Scan(&v)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ageAt returns whole years between an ISO 8601 date of birth and now.
+// Anything after the date part of dob is ignored.
+func ageAt(dob string, now time.Time) (int, bool) {
+	if len(dob) > len(calendar.DateLayout) {
+		dob = dob[:len(calendar.DateLayout)]
+	}
+	born, err := time.Parse(calendar.DateLayout, dob)
+	if err != nil || born.After(now) {
+		return 0, false
+	}
+	age := now.Year() - born.Year()
+	if now.Month() < born.Month() || (now.Month() == born.Month() && now.Day() < born.Day()) {
+		age--
+	}
+	return age, true
+}
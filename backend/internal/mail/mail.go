@@ -0,0 +1,140 @@
+// Package mail sends the few transactional emails the app needs (address
+// verification, password resets). The transport is chosen by MAILER so that
+// development and self-hosted setups work without an SMTP server.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrNotConfigured means MAILER is unset, so no mail can be sent.
+var ErrNotConfigured = errors.New("MAILER is not set")
+
+// FromEnv picks a mailer:
+//
+//	MAILER=smtp  SMTP_ADDR (host:port), SMTP_USERNAME, SMTP_PASSWORD, MAIL_FROM
+//	MAILER=file  MAIL_DIR (default ./mail), one .eml file per message
+//	MAILER=log   messages are written to the server log; only with DEV_MODE=true
+//
+// The links in these messages sign people in, so writing them to a log is
+// refused outside development.
+func FromEnv() (Mailer, error) {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		from := os.Getenv("MAIL_FROM")
+		if addr == "" || from == "" {
+			return nil, fmt.Errorf("MAILER=smtp needs SMTP_ADDR and MAIL_FROM")
+		}
+		return &SMTPMailer{Addr: addr, Username: os.Getenv("SMTP_USERNAME"), Password: os.Getenv("SMTP_PASSWORD"), From: from}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return &FileMailer{Dir: dir}, nil
+	case "log":
+		if os.Getenv("DEV_MODE") != "true" {
+			return nil, errors.New("MAILER=log writes sign-in links to the server log and needs DEV_MODE=true")
+		}
+		return LogMailer{}, nil
+	case "":
+		return nil, ErrNotConfigured
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", os.Getenv("MAILER"))
+	}
+}
+
+// LogMailer prints messages to the server log instead of sending them.
+type LogMailer struct{}
+
+func (LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer drops each message into Dir as an .eml file, which most mail
+// clients can open.
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(m.Dir, name), render("fitness-buddy@localhost", msg), 0o600)
+}
+
+// SMTPMailer delivers through an SMTP server, authenticating with PLAIN when
+// a username is set. net/smtp upgrades to STARTTLS when the server offers it.
+type SMTPMailer struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(_ context.Context, msg Message) error {
+	var a smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		a = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	// MAIL_FROM may carry a display name; the envelope wants the bare address.
+	sender, err := netmail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("MAIL_FROM: %w", err)
+	}
+	return smtp.SendMail(m.Addr, a, sender.Address, []string{msg.To}, render(m.From, msg))
+}
+
+func render(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", stripNewlines(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", stripNewlines(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// stripNewlines keeps user-supplied values from injecting extra headers.
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
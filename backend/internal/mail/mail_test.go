@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "unset", wantErr: true},
+		{name: "log outside development", env: map[string]string{"MAILER": "log"}, wantErr: true},
+		{name: "log in development", env: map[string]string{"MAILER": "log", "DEV_MODE": "true"}, want: "mail.LogMailer"},
+		{name: "file", env: map[string]string{"MAILER": "file"}, want: "*mail.FileMailer"},
+		{name: "smtp", env: map[string]string{"MAILER": "smtp", "SMTP_ADDR": "smtp.example.com:587", "MAIL_FROM": "a@example.com"}, want: "*mail.SMTPMailer"},
+		{name: "smtp without an address", env: map[string]string{"MAILER": "smtp"}, wantErr: true},
+		{name: "unknown", env: map[string]string{"MAILER": "carrier-pigeon"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"MAILER", "DEV_MODE", "SMTP_ADDR", "MAIL_FROM", "MAIL_DIR"} {
+				t.Setenv(k, tt.env[k])
+			}
+			m, err := FromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("FromEnv() = %T, want an error", m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromEnv: %v", err)
+			}
+			if got := typeName(m); got != tt.want {
+				t.Errorf("FromEnv() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromEnvNotConfigured(t *testing.T) {
+	t.Setenv("MAILER", "")
+	if _, err := FromEnv(); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("err = %v, want ErrNotConfigured", err)
+	}
+}
+
+func typeName(m Mailer) string {
+	switch m.(type) {
+	case LogMailer:
+		return "mail.LogMailer"
+	case *FileMailer:
+		return "*mail.FileMailer"
+	case *SMTPMailer:
+		return "*mail.SMTPMailer"
+	}
+	return "unknown"
+}
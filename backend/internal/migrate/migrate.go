@@ -0,0 +1,516 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fitness-buddy/internal/database"
+)
+
+// legacyVersion is the last migration that the pre-ledger runner applied on
+// every boot. Databases created by that runner are baselined up to here.
+const legacyVersion = 15
+
+// advisoryLockID serialises concurrent migrators on Postgres.
+const advisoryLockID = 72616
+
+// Files are named NNN_name.sql (or NNN_name.up.sql) with an optional
+// NNN_name.down.sql alongside that reverses it.
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+?)(\.up|\.down)?\.sql$`)
+
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	DownSQL  string
+	Checksum string
+}
+
+// Reversible reports whether the migration ships a down file.
+func (m Migration) Reversible() bool {
+	return m.DownSQL != ""
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%03d_%s", m.Version, m.Name)
+}
+
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db   *database.DB
+	fsys fs.FS
+}
+
+func New(db *database.DB, fsys fs.FS) *Migrator {
+	return &Migrator{db: db, fsys: fsys}
+}
+
+// Load reads every migration file from the filesystem, ordered by version.
+func (m *Migrator) Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	upFiles := map[int]string{}
+	downFiles := map[int]string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		files := upFiles
+		if match[3] == ".down" {
+			files = downFiles
+		}
+		if other, ok := files[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, entry.Name())
+		}
+		files[version] = entry.Name()
+
+		content, err := fs.ReadFile(m.fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has mismatched names: %s and %s", version, mig.Name, match[2])
+		}
+		if match[3] == ".down" {
+			mig.DownSQL = string(content)
+		} else {
+			mig.SQL = string(content)
+			mig.Checksum = checksum(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for version, mig := range byVersion {
+		if _, ok := upFiles[version]; !ok {
+			return nil, fmt.Errorf("migration %s has a down file but no up file", downFiles[version])
+		}
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in order, each inside its own
+// transaction. It refuses to run if an already-applied file was edited.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	migrations, applied, err := m.prepare(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, mig := range pending(migrations, applied) {
+		log.Printf("Applying migration %s...", mig)
+		if err := m.apply(ctx, conn, mig); err != nil {
+			return done, fmt.Errorf("migration %s failed: %w", mig, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down reverts the n most recently applied migrations, newest first. Every
+// one of them must have a down file, otherwise nothing is reverted.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of migrations to revert must be positive")
+	}
+
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	migrations, applied, err := m.prepare(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := rollbackTargets(migrations, applied, n)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, mig := range targets {
+		log.Printf("Reverting migration %s...", mig)
+		if err := m.revert(ctx, conn, mig); err != nil {
+			return done, fmt.Errorf("revert %s failed: %w", mig, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Plan returns the migrations Up would apply, without touching the schema
+// beyond creating the ledger.
+func (m *Migrator) Plan(ctx context.Context) ([]Migration, error) {
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	migrations, applied, err := m.prepare(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return pending(migrations, applied), nil
+}
+
+// PlanDown returns the migrations Down(n) would revert.
+func (m *Migrator) PlanDown(ctx context.Context, n int) ([]Migration, error) {
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	migrations, applied, err := m.prepare(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return rollbackTargets(migrations, applied, n)
+}
+
+const (
+	StateApplied  = "applied"
+	StatePending  = "pending"
+	StateModified = "modified"
+	StateMissing  = "missing"
+)
+
+type Status struct {
+	Version    int
+	Name       string
+	State      string
+	Reversible bool
+	AppliedAt  *time.Time
+}
+
+// Status reports every known version, whether it comes from a file, the
+// ledger, or both.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := m.ensureLedger(ctx, conn, migrations); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	known := map[int]bool{}
+	for _, mig := range migrations {
+		known[mig.Version] = true
+		st := Status{Version: mig.Version, Name: mig.Name, State: StatePending, Reversible: mig.Reversible()}
+		if a, ok := applied[mig.Version]; ok {
+			st.State = StateApplied
+			if a.Checksum != mig.Checksum {
+				st.State = StateModified
+			}
+			appliedAt := a.AppliedAt
+			st.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, st)
+	}
+	for version, a := range applied {
+		if known[version] {
+			continue
+		}
+		appliedAt := a.AppliedAt
+		statuses = append(statuses, Status{Version: a.Version, Name: a.Name, State: StateMissing, AppliedAt: &appliedAt})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// SQL returns the statement text that will actually be executed for the
+// migration on this database.
+func (m *Migrator) SQL(mig Migration, down bool) string {
+	if down {
+		return m.db.Dialect.Rewrite(mig.DownSQL)
+	}
+	return m.db.Dialect.Rewrite(mig.SQL)
+}
+
+func (m *Migrator) prepare(ctx context.Context, conn *sql.Conn) ([]Migration, map[int]AppliedMigration, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := m.ensureLedger(ctx, conn, migrations); err != nil {
+		return nil, nil, err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verify(migrations, applied); err != nil {
+		return nil, nil, err
+	}
+	return migrations, applied, nil
+}
+
+func pending(migrations []Migration, applied map[int]AppliedMigration) []Migration {
+	todo := []Migration{}
+	for _, mig := range migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			todo = append(todo, mig)
+		}
+	}
+	return todo
+}
+
+func rollbackTargets(migrations []Migration, applied map[int]AppliedMigration, n int) ([]Migration, error) {
+	byVersion := map[int]Migration{}
+	for _, mig := range migrations {
+		byVersion[mig.Version] = mig
+	}
+
+	versions := []int{}
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	if n > len(versions) {
+		return nil, fmt.Errorf("cannot revert %d migrations, only %d applied", n, len(versions))
+	}
+
+	targets := []Migration{}
+	for _, version := range versions[:n] {
+		mig, ok := byVersion[version]
+		if !ok {
+			a := applied[version]
+			return nil, fmt.Errorf("migration %03d_%s is not in this build and cannot be reverted", a.Version, a.Name)
+		}
+		if !mig.Reversible() {
+			return nil, fmt.Errorf("migration %s has no down file", mig)
+		}
+		targets = append(targets, mig)
+	}
+	return targets, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.db.Dialect.Rewrite(mig.SQL)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)`, mig.Version, mig.Name, mig.Checksum, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.db.Dialect.Rewrite(mig.DownSQL)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lock reserves a dedicated connection for the run. On Postgres it also takes
+// an advisory lock so that two instances booting together don't race; SQLite
+// only ever has one open connection, which serialises access already.
+func (m *Migrator) lock(ctx context.Context) (*sql.Conn, func(), error) {
+	conn, err := m.db.Pool.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if m.db.Driver != database.DriverPostgres {
+		return conn, func() { conn.Close() }, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockID); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	return conn, func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockID)
+		conn.Close()
+	}, nil
+}
+
+// ensureLedger creates schema_migrations. A database that already has tables
+// but no ledger was built by the old run-everything-on-boot loop, so the
+// legacy migrations are recorded as applied rather than re-executed.
+func (m *Migrator) ensureLedger(ctx context.Context, conn *sql.Conn, migrations []Migration) error {
+	exists, err := m.tableExists(ctx, conn, "schema_migrations")
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	legacy, err := m.tableExists(ctx, conn, "users")
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ddl := `
+        CREATE TABLE schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            checksum TEXT NOT NULL,
+            applied_at TIMESTAMPTZ NOT NULL
+        )
+    `
+	if _, err := tx.ExecContext(ctx, m.db.Dialect.Rewrite(ddl)); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	if legacy {
+		log.Printf("Existing schema without migration ledger found, baselining up to version %d", legacyVersion)
+		now := time.Now().UTC()
+		for _, mig := range migrations {
+			if mig.Version > legacyVersion {
+				break
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)`, mig.Version, mig.Name, mig.Checksum, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) tableExists(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = $1`
+	if m.db.Driver == database.DriverPostgres {
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1`
+	}
+	var count int
+	if err := conn.QueryRowContext(ctx, query, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]AppliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]AppliedMigration{}
+	for rows.Next() {
+		var a AppliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[a.Version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// verify fails when a file that has already been applied no longer matches
+// the checksum recorded at the time.
+func verify(migrations []Migration, applied map[int]AppliedMigration) error {
+	known := map[int]bool{}
+	modified := []string{}
+	for _, mig := range migrations {
+		known[mig.Version] = true
+		if a, ok := applied[mig.Version]; ok && a.Checksum != mig.Checksum {
+			modified = append(modified, mig.String())
+		}
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("migrations modified after being applied: %s", strings.Join(modified, ", "))
+	}
+
+	for version, a := range applied {
+		if !known[version] {
+			log.Printf("Migration %03d_%s is recorded as applied but missing from this build", a.Version, a.Name)
+		}
+	}
+	return nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,223 @@
+package migrate_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/migrate"
+	"fitness-buddy/internal/testdb"
+	"fitness-buddy/migrations"
+)
+
+func forEachBackend(t *testing.T, fn func(t *testing.T, db *database.DB)) {
+	t.Run("sqlite", func(t *testing.T) { fn(t, testdb.EmptySQLite(t)) })
+	t.Run("postgres", func(t *testing.T) { fn(t, testdb.EmptyPostgres(t)) })
+}
+
+func file(sql string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(sql)}
+}
+
+func versions(migs []migrate.Migration) []int {
+	out := []int{}
+	for _, mig := range migs {
+		out = append(out, mig.Version)
+	}
+	return out
+}
+
+func hasTable(db *database.DB, table string) bool {
+	_, err := db.Pool.Exec(`SELECT COUNT(*) FROM ` + table)
+	return err == nil
+}
+
+// ledger returns the recorded checksum of every applied version.
+func ledger(t *testing.T, db *database.DB) map[int]string {
+	t.Helper()
+	rows, err := db.Pool.Query(`SELECT version, checksum FROM schema_migrations`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	out := map[int]string{}
+	for rows.Next() {
+		var version int
+		var sum string
+		if err := rows.Scan(&version, &sum); err != nil {
+			t.Fatal(err)
+		}
+		out[version] = sum
+	}
+	return out
+}
+
+func TestEditedMigrationIsRefused(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		fsys := fstest.MapFS{"001_widgets.sql": file(`CREATE TABLE widgets (id SERIAL PRIMARY KEY)`)}
+		if _, err := migrate.New(db, fsys).Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		fsys["001_widgets.sql"] = file(`CREATE TABLE widgets (id SERIAL PRIMARY KEY, name TEXT)`)
+		fsys["002_gadgets.sql"] = file(`CREATE TABLE gadgets (id SERIAL PRIMARY KEY)`)
+		m := migrate.New(db, fsys)
+		if _, err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "001_widgets") {
+			t.Fatalf("Up after editing 001 = %v, want a checksum error naming it", err)
+		}
+		if hasTable(db, "gadgets") {
+			t.Error("002 was applied despite the checksum mismatch")
+		}
+		if _, err := m.Plan(ctx); err == nil {
+			t.Error("Plan succeeded despite the checksum mismatch")
+		}
+
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(statuses) != 2 || statuses[0].State != migrate.StateModified || statuses[1].State != migrate.StatePending {
+			t.Errorf("statuses = %+v, want 001 modified and 002 pending", statuses)
+		}
+	})
+}
+
+// TestLegacyBaseline builds the schema the way the runner before the ledger
+// did, executing every file up to version 15, and checks that Up records
+// those as applied and only runs what came after.
+func TestLegacyBaseline(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		m := migrate.New(db, migrations.FS)
+		all, err := m.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mig := range all {
+			if mig.Version > 15 {
+				break
+			}
+			if _, err := db.Pool.Exec(m.SQL(mig, false)); err != nil {
+				t.Fatalf("legacy %s: %v", mig, err)
+			}
+		}
+
+		applied, err := m.Up(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(applied) == 0 || applied[0].Version != 16 {
+			t.Fatalf("Up applied %v, want everything from 16", versions(applied))
+		}
+
+		recorded := ledger(t, db)
+		for _, mig := range all {
+			if recorded[mig.Version] != mig.Checksum {
+				t.Errorf("%s recorded with checksum %q, want %q", mig, recorded[mig.Version], mig.Checksum)
+			}
+		}
+		if pending, err := m.Plan(ctx); err != nil || len(pending) != 0 {
+			t.Errorf("Plan after baseline = %v, %v; want nothing", versions(pending), err)
+		}
+	})
+}
+
+func TestUpDownRoundTrip(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		m := migrate.New(db, migrations.FS)
+		all, err := m.Up(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reversible := 0
+		for i := len(all) - 1; i >= 0 && all[i].Reversible(); i-- {
+			reversible++
+		}
+		if reversible == 0 {
+			t.Fatal("the newest migration has no down file")
+		}
+
+		planned, err := m.PlanDown(ctx, reversible)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reverted, err := m.Down(ctx, reversible)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(versions(planned), versions(reverted)) {
+			t.Errorf("PlanDown = %v, Down reverted %v", versions(planned), versions(reverted))
+		}
+		if got := versions(reverted); got[0] != all[len(all)-1].Version || len(got) != reversible {
+			t.Errorf("Down reverted %v, want the %d newest, newest first", got, reversible)
+		}
+		if len(ledger(t, db)) != len(all)-reversible {
+			t.Errorf("ledger has %d entries after reverting %d of %d", len(ledger(t, db)), reversible, len(all))
+		}
+
+		again, err := m.Up(ctx)
+		if err != nil {
+			t.Fatalf("re-applying after Down: %v", err)
+		}
+		if len(again) != reversible {
+			t.Errorf("Up re-applied %v, want the %d reverted", versions(again), reversible)
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, st := range statuses {
+			if st.State != migrate.StateApplied {
+				t.Errorf("%03d_%s is %s after the round trip", st.Version, st.Name, st.State)
+			}
+		}
+	})
+}
+
+func TestDownWithoutDownFile(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		fsys := fstest.MapFS{
+			"001_widgets.sql":      file(`CREATE TABLE widgets (id SERIAL PRIMARY KEY)`),
+			"002_gadgets.up.sql":   file(`CREATE TABLE gadgets (id SERIAL PRIMARY KEY)`),
+			"002_gadgets.down.sql": file(`DROP TABLE gadgets`),
+		}
+		m := migrate.New(db, fsys)
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := m.PlanDown(ctx, 2); err == nil || !strings.Contains(err.Error(), "001_widgets has no down file") {
+			t.Errorf("PlanDown(2) = %v, want a missing down file error", err)
+		}
+		if _, err := m.Down(ctx, 2); err == nil || !strings.Contains(err.Error(), "001_widgets has no down file") {
+			t.Errorf("Down(2) = %v, want a missing down file error", err)
+		}
+		if !hasTable(db, "gadgets") || len(ledger(t, db)) != 2 {
+			t.Error("Down reverted 002 although 001 cannot be reverted")
+		}
+		if _, err := m.Down(ctx, 3); err == nil {
+			t.Error("Down(3) succeeded with 2 migrations applied")
+		}
+
+		reverted, err := m.Down(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := versions(reverted); !reflect.DeepEqual(got, []int{2}) || hasTable(db, "gadgets") {
+			t.Errorf("Down(1) reverted %v, want 002 and its table gone", got)
+		}
+	})
+}
+
+func TestLoadRejectsDownWithoutUp(t *testing.T) {
+	fsys := fstest.MapFS{"001_widgets.down.sql": file(`DROP TABLE widgets`)}
+	if _, err := migrate.New(nil, fsys).Load(); err == nil {
+		t.Error("Load accepted a down file without an up file")
+	}
+}
@@ -0,0 +1,219 @@
+package takeout
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"fitness-buddy/internal/database"
+)
+
+type Exporter struct {
+	db *database.DB
+}
+
+func NewExporter(db *database.DB) *Exporter {
+	return &Exporter{db: db}
+}
+
+// Export writes the user's archive to w. Rows go from the database into the
+// archive one at a time, so memory use doesn't grow with the history. A ZIP
+// is written one file at a time, so while an entity's JSON streams out its
+// CSV is spooled to a temporary file and copied in afterwards.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, userID int) (*Manifest, error) {
+	version, err := e.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Format:        Format,
+		FormatVersion: FormatVersion,
+		SchemaVersion: version,
+		ExportedAt:    time.Now().UTC(),
+		UserID:        userID,
+		Entities:      []EntityInfo{},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, ent := range entities {
+		info, err := e.exportEntity(ctx, zw, ent, userID, m.ExportedAt)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", ent.name, err)
+		}
+		m.Entities = append(m.Entities, *info)
+	}
+
+	f, err := createFile(zw, ManifestFile, m.ExportedAt)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return m, zw.Close()
+}
+
+func (e *Exporter) schemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := e.db.Pool.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+func (e *Exporter) exportEntity(ctx context.Context, zw *zip.Writer, ent entity, userID int, modified time.Time) (*EntityInfo, error) {
+	rows, err := e.db.Pool.QueryContext(ctx, ent.query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	spool, err := os.CreateTemp("", "takeout-*.csv")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	cw := csv.NewWriter(spool)
+	if err := cw.Write(columns); err != nil {
+		return nil, err
+	}
+
+	jw, err := createFile(zw, ent.name+".json", modified)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(jw, "["); err != nil {
+		return nil, err
+	}
+
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		// Objects are assembled by hand to keep the columns in query order.
+		buf := []byte("\n  {")
+		if n > 0 {
+			buf = []byte(",\n  {")
+		}
+		for i, col := range columns {
+			v := exportValue(values[i], types[i])
+			val, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", col, err)
+			}
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = strconv.AppendQuote(buf, col)
+			buf = append(buf, ':')
+			buf = append(buf, val...)
+			record[i] = csvValue(v)
+		}
+		buf = append(buf, '}')
+
+		if _, err := jw.Write(buf); err != nil {
+			return nil, err
+		}
+		if err := cw.Write(record); err != nil {
+			return nil, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(jw, "\n]\n"); err != nil {
+		return nil, err
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	csvFile, err := createFile(zw, ent.name+".csv", modified)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(csvFile, spool); err != nil {
+		return nil, err
+	}
+
+	return &EntityInfo{
+		Name:    ent.name,
+		Files:   []string{ent.name + ".json", ent.name + ".csv"},
+		Columns: columns,
+		Rows:    n,
+	}, nil
+}
+
+func createFile(zw *zip.Writer, name string, modified time.Time) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+}
+
+// exportValue turns a value scanned from either driver into what goes in the
+// archive. Timestamps are written in UTC. Postgres REAL columns come back
+// widened to float64 and are trimmed back to float32 precision so that 72.6
+// doesn't come out as 72.5999984741211.
+func exportValue(v any, t *sql.ColumnType) any {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC()
+	case float64:
+		if t.DatabaseTypeName() == "FLOAT4" {
+			return json.Number(strconv.FormatFloat(v, 'f', -1, 32))
+		}
+	}
+	return v
+}
+
+// csvValue formats v for a CSV cell. NULL becomes an empty cell, so the JSON
+// files are the ones to read when the difference matters.
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
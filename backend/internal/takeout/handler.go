@@ -0,0 +1,108 @@
+package takeout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"fitness-buddy/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxArchiveSize caps uploads to the import endpoint.
+const maxArchiveSize = 512 << 20
+
+type Handler struct {
+	exporter *Exporter
+	importer *Importer
+}
+
+func NewHandler(exporter *Exporter, importer *Importer) *Handler {
+	return &Handler{exporter: exporter, importer: importer}
+}
+
+func (h *Handler) RegisterRoutes(r chi.Router) {
+	r.Get("/user/export", h.Export)
+	r.Post("/user/import", h.Import)
+}
+
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("fitness-buddy-export-%s.zip", time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	sw := &startedWriter{w: w}
+	if _, err := h.exporter.Export(r.Context(), sw, userID); err != nil {
+		if !sw.started {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Too late to change the status; the client is left with a
+		// truncated archive that won't open.
+		log.Printf("export for user %d failed: %v", userID, err)
+	}
+}
+
+// startedWriter records whether any of the response body has been sent.
+type startedWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (sw *startedWriter) Write(p []byte) (int, error) {
+	sw.started = true
+	return sw.w.Write(p)
+}
+
+// Import takes an archive from Export as the raw request body. With
+// ?dry_run=true nothing is written and the report says what would be.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.RequireUserID(w, r)
+	if !ok {
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	// A ZIP is read from the end, so the upload goes to disk first.
+	spool, err := os.CreateTemp("", "takeout-*.zip")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, http.MaxBytesReader(w, r.Body, maxArchiveSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Archive too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.importer.Import(r.Context(), spool, size, userID, dryRun)
+	if errors.Is(err, ErrInvalidArchive) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
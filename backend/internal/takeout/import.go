@@ -0,0 +1,772 @@
+package takeout
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/domain/resistance"
+)
+
+// ErrInvalidArchive is returned for uploads that aren't a takeout archive
+// this version can read.
+var ErrInvalidArchive = errors.New("invalid takeout archive")
+
+type Importer struct {
+	db *database.DB
+}
+
+func NewImporter(db *database.DB) *Importer {
+	return &Importer{db: db}
+}
+
+// Report says what an import did, or with DryRun what it would do.
+type Report struct {
+	DryRun bool `json:"dry_run"`
+
+	// SchemaVersion is the schema the archive was exported from.
+	SchemaVersion int            `json:"schema_version"`
+	Entities      []EntityReport `json:"entities"`
+	Warnings      []string       `json:"warnings"`
+}
+
+type EntityReport struct {
+	Name    string `json:"name"`
+	Rows    int    `json:"rows"`
+	Created int    `json:"created"`
+	Updated int    `json:"updated,omitempty"`
+
+	// Duplicates already existed for this user. Children of a duplicate
+	// workout, routine or meal count as duplicates too.
+	Duplicates int `json:"duplicates"`
+
+	// Skipped rows refer to something that isn't in the archive.
+	Skipped int `json:"skipped"`
+}
+
+// parent is where an archived workout, routine or meal ended up.
+type parent struct {
+	id int
+	// existed is set when the row was a duplicate, in which case its
+	// children are assumed to be there already.
+	existed bool
+}
+
+// importRun is the state of one import. The maps translate IDs in the archive
+// into IDs in this database.
+type importRun struct {
+	ctx     context.Context
+	tx      *sql.Tx
+	dialect database.Dialect
+	userID  int
+	report  *Report
+
+	// exercises maps exercise names to IDs; the library is shared, so
+	// names are what carry over between instances.
+	exercises          map[string]int
+	exerciseCategories map[string]exerciseRow
+	shoes              map[int]int
+	sessions           map[int]parent
+	routines           map[int]parent
+	meals              map[int]parent
+}
+
+// importers run in dependency order: the libraries first, then parents before
+// their children.
+var importers = []struct {
+	name string
+	run  func(*importRun, *zip.File, *EntityReport) error
+}{
+	{"profile", (*importRun).importProfile},
+	{"custom_exercises", (*importRun).importCustomExercises},
+	{"custom_foods", (*importRun).importCustomFoods},
+	{"shoes", (*importRun).importShoes},
+	{"runs", (*importRun).importRuns},
+	{"workout_sessions", (*importRun).importWorkoutSessions},
+	{"workout_sets", (*importRun).importWorkoutSets},
+	{"routines", (*importRun).importRoutines},
+	{"routine_exercises", (*importRun).importRoutineExercises},
+	{"meals", (*importRun).importMeals},
+	{"food_entries", (*importRun).importFoodEntries},
+	{"water_logs", (*importRun).importWaterLogs},
+	{"nutrition_goals", (*importRun).importNutritionGoals},
+	{"body_metrics", (*importRun).importBodyMetrics},
+}
+
+// Import recreates the archive's contents under userID in one transaction.
+// Rows that already exist are matched on their natural keys (a workout's
+// start time, a shoe's brand and model, ...) and left alone, so importing the
+// same archive twice is harmless. With dryRun the transaction is rolled back
+// and the report describes what would have happened.
+//
+// Logins are never imported: an archive must not be able to add a way into
+// the account. Comments and coaching grants involve other users and are
+// exported for reference only, and personal records are worked out again
+// from the sets.
+func (im *Importer) Import(ctx context.Context, r io.ReaderAt, size int64, userID int, dryRun bool) (*Report, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	m, err := readManifest(files[ManifestFile])
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: dryRun, SchemaVersion: m.SchemaVersion, Entities: []EntityReport{}, Warnings: []string{}}
+	local, err := NewExporter(im.db).schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if m.SchemaVersion > local {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("archive is from schema %d, this server is on %d; columns it doesn't know are ignored", m.SchemaVersion, local))
+	}
+
+	tx, err := im.db.Pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	run := &importRun{
+		ctx:                ctx,
+		tx:                 tx,
+		dialect:            im.db.Dialect,
+		userID:             userID,
+		report:             report,
+		exerciseCategories: map[string]exerciseRow{},
+		shoes:              map[int]int{},
+		sessions:           map[int]parent{},
+		routines:           map[int]parent{},
+		meals:              map[int]parent{},
+	}
+	if run.exercises, err = run.loadKeys(`SELECT id, name FROM exercises`); err != nil {
+		return nil, err
+	}
+
+	for _, imp := range importers {
+		f := files[imp.name+".json"]
+		if f == nil {
+			report.Warnings = append(report.Warnings, imp.name+".json is missing from the archive")
+			continue
+		}
+		er := EntityReport{Name: imp.name}
+		if err := imp.run(run, f, &er); err != nil {
+			return nil, fmt.Errorf("import %s: %w", imp.name, err)
+		}
+		report.Entities = append(report.Entities, er)
+	}
+	// Imported sets may set or break records either way.
+	if err := resistance.RecomputeAllRecords(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	return report, tx.Commit()
+}
+
+func readManifest(f *zip.File) (*Manifest, error) {
+	if f == nil {
+		return nil, fmt.Errorf("%w: no %s", ErrInvalidArchive, ManifestFile)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer rc.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(rc).Decode(&m); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, ManifestFile, err)
+	}
+	if m.Format != Format {
+		return nil, fmt.Errorf("%w: format %q", ErrInvalidArchive, m.Format)
+	}
+	if m.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("%w: format version %d is newer than this server supports (%d)", ErrInvalidArchive, m.FormatVersion, FormatVersion)
+	}
+	return &m, nil
+}
+
+// eachRow decodes the JSON array in f one element at a time.
+func eachRow[T any](f *zip.File, er *EntityReport, fn func(T) error) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer rc.Close()
+
+	dec := json.NewDecoder(rc)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return fmt.Errorf("%w: %s is not a JSON array", ErrInvalidArchive, f.Name)
+	}
+	for dec.More() {
+		var row T
+		if err := dec.Decode(&row); err != nil {
+			return fmt.Errorf("%w: %s row %d: %v", ErrInvalidArchive, f.Name, er.Rows+1, err)
+		}
+		er.Rows++
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadKeys reads (id, key) pairs into a map from key to ID.
+func (run *importRun) loadKeys(query string, args ...any) (map[string]int, error) {
+	rows, err := run.tx.QueryContext(run.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := map[string]int{}
+	for rows.Next() {
+		var id int
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			return nil, err
+		}
+		keys[key] = id
+	}
+	return keys, rows.Err()
+}
+
+// loadTimeKeys is loadKeys for rows keyed on a timestamp and, optionally, a
+// second column.
+func (run *importRun) loadTimeKeys(query string, args ...any) (map[string]int, error) {
+	rows, err := run.tx.QueryContext(run.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]int{}
+	for rows.Next() {
+		var id int
+		var t time.Time
+		var extra sql.NullString
+		dest := []any{&id, &t}
+		if len(cols) > 2 {
+			dest = append(dest, &extra)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		keys[timeKey(t, extra.String)] = id
+	}
+	return keys, rows.Err()
+}
+
+// timeKey identifies a row by when it happened. Postgres keeps microseconds
+// and SQLite nanoseconds, so times are compared at the coarser of the two.
+func timeKey(t time.Time, extra string) string {
+	return t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano) + "|" + extra
+}
+
+// insert runs an INSERT ending in "RETURNING id" and returns the new row's
+// ID. Where the backend has no RETURNING, the clause is cut and the driver's
+// LastInsertId is used instead.
+func (run *importRun) insert(query string, args ...any) (int, error) {
+	if !run.dialect.SupportsReturning() {
+		res, err := run.tx.ExecContext(run.ctx, strings.TrimSuffix(query, " RETURNING id"), args...)
+		if err != nil {
+			return 0, err
+		}
+		id, err := res.LastInsertId()
+		return int(id), err
+	}
+	var id int
+	err := run.tx.QueryRowContext(run.ctx, query, args...).Scan(&id)
+	return id, err
+}
+
+// exerciseID finds an exercise by name, adding it to the library if this
+// instance doesn't have it.
+func (run *importRun) exerciseID(name string) (int, error) {
+	if id, ok := run.exercises[name]; ok {
+		return id, nil
+	}
+	category, equipment := "Other", (*string)(nil)
+	if ex, ok := run.exerciseCategories[name]; ok {
+		category, equipment = ex.Category, ex.Equipment
+	} else {
+		run.report.Warnings = append(run.report.Warnings, fmt.Sprintf("exercise %q was not in the library and was added as %q", name, category))
+	}
+	id, err := run.insert(`INSERT INTO exercises (name, category, equipment, created_by) VALUES ($1, $2, $3, $4) RETURNING id`, name, category, equipment, run.userID)
+	if err != nil {
+		return 0, err
+	}
+	run.exercises[name] = id
+	return id, nil
+}
+
+type profileRow struct {
+	HeightCM *float64 `json:"height_cm"`
+	DOB      *string  `json:"dob"`
+	Sex      *string  `json:"sex"`
+}
+
+// importProfile fills in profile fields the account doesn't have yet. Name
+// and email belong to the account being imported into and are left alone.
+func (run *importRun) importProfile(f *zip.File, er *EntityReport) error {
+	return eachRow(f, er, func(p profileRow) error {
+		var cur profileRow
+		err := run.tx.QueryRowContext(run.ctx, `SELECT height_cm, dob, sex FROM users WHERE id = $1`, run.userID).Scan(&cur.HeightCM, &cur.DOB, &cur.Sex)
+		if err != nil {
+			return err
+		}
+		if (cur.HeightCM != nil || p.HeightCM == nil) && (cur.DOB != nil || p.DOB == nil) && (cur.Sex != nil || p.Sex == nil) {
+			er.Duplicates++
+			return nil
+		}
+		query := `UPDATE users SET height_cm = COALESCE(height_cm, $1), dob = COALESCE(dob, $2), sex = COALESCE(sex, $3), updated_at = CURRENT_TIMESTAMP WHERE id = $4`
+		if _, err := run.tx.ExecContext(run.ctx, query, p.HeightCM, p.DOB, p.Sex, run.userID); err != nil {
+			return err
+		}
+		er.Updated++
+		return nil
+	})
+}
+
+type exerciseRow struct {
+	Name      string  `json:"name"`
+	Category  string  `json:"category"`
+	Equipment *string `json:"equipment"`
+}
+
+func (run *importRun) importCustomExercises(f *zip.File, er *EntityReport) error {
+	return eachRow(f, er, func(e exerciseRow) error {
+		if _, ok := run.exercises[e.Name]; ok {
+			er.Duplicates++
+			return nil
+		}
+		run.exerciseCategories[e.Name] = e
+		if _, err := run.exerciseID(e.Name); err != nil {
+			return err
+		}
+		er.Created++
+		return nil
+	})
+}
+
+type foodRow struct {
+	Name            string   `json:"name"`
+	CaloriesPer100g float64  `json:"calories_per_100g"`
+	ProteinPer100g  *float64 `json:"protein_per_100g"`
+	CarbsPer100g    *float64 `json:"carbs_per_100g"`
+	FatPer100g      *float64 `json:"fat_per_100g"`
+}
+
+func (run *importRun) importCustomFoods(f *zip.File, er *EntityReport) error {
+	foods, err := run.loadKeys(`SELECT id, name FROM food_library`)
+	if err != nil {
+		return err
+	}
+	return eachRow(f, er, func(fr foodRow) error {
+		if _, ok := foods[fr.Name]; ok {
+			er.Duplicates++
+			return nil
+		}
+		id, err := run.insert(`INSERT INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g, created_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			fr.Name, fr.CaloriesPer100g, fr.ProteinPer100g, fr.CarbsPer100g, fr.FatPer100g, run.userID)
+		if err != nil {
+			return err
+		}
+		foods[fr.Name] = id
+		er.Created++
+		return nil
+	})
+}
+
+type shoeRow struct {
+	ID       int    `json:"id"`
+	Brand    string `json:"brand"`
+	Model    string `json:"model"`
+	IsActive *bool  `json:"is_active"`
+}
+
+func (run *importRun) importShoes(f *zip.File, er *EntityReport) error {
+	existing, err := run.loadKeys(`SELECT id, brand || '|' || model FROM shoes WHERE user_id = $1`, run.userID)
+	if err != nil {
+		return err
+	}
+	return eachRow(f, er, func(s shoeRow) error {
+		key := s.Brand + "|" + s.Model
+		if id, ok := existing[key]; ok {
+			run.shoes[s.ID] = id
+			er.Duplicates++
+			return nil
+		}
+		active := s.IsActive == nil || *s.IsActive
+		id, err := run.insert(`INSERT INTO shoes (user_id, brand, model, is_active) VALUES ($1, $2, $3, $4) RETURNING id`, run.userID, s.Brand, s.Model, active)
+		if err != nil {
+			return err
+		}
+		existing[key] = id
+		run.shoes[s.ID] = id
+		er.Created++
+		return nil
+	})
+}
+
+type runRow struct {
+	StartTime           time.Time `json:"start_time"`
+	DurationSeconds     int       `json:"duration_seconds"`
+	DistanceMeters      float64   `json:"distance_meters"`
+	ElevationGainMeters *float64  `json:"elevation_gain_meters"`
+	AvgHeartRate        *int      `json:"avg_heart_rate"`
+	Cadence             *int      `json:"cadence"`
+	RelativeEffort      *int      `json:"relative_effort"`
+	Steps               *int      `json:"steps"`
+	ShoeID              *int      `json:"shoe_id"`
+	RunType             *string   `json:"run_type"`
+	Notes               *string   `json:"notes"`
+	ExternalID          *string   `json:"external_id"`
+	RouteData           *string   `json:"route_data"`
+}
+
+func (run *importRun) importRuns(f *zip.File, er *EntityReport) error {
+	existing, err := run.loadTimeKeys(`SELECT id, start_time, duration_seconds FROM runs WHERE user_id = $1`, run.userID)
+	if err != nil {
+		return err
+	}
+	return eachRow(f, er, func(rr runRow) error {
+		key := timeKey(rr.StartTime, fmt.Sprint(rr.DurationSeconds))
+		if _, ok := existing[key]; ok {
+			er.Duplicates++
+			return nil
+		}
+		var shoeID *int
+		if rr.ShoeID != nil {
+			if id, ok := run.shoes[*rr.ShoeID]; ok {
+				shoeID = &id
+			}
+		}
+		id, err := run.insert(`INSERT INTO runs (user_id, start_time, duration_seconds, distance_meters, elevation_gain_meters, avg_heart_rate, cadence, relative_effort, steps, shoe_id, run_type, notes, external_id, route_data)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`,
+			run.userID, rr.StartTime, rr.DurationSeconds, rr.DistanceMeters, rr.ElevationGainMeters, rr.AvgHeartRate, rr.Cadence, rr.RelativeEffort, rr.Steps, shoeID, rr.RunType, rr.Notes, rr.ExternalID, rr.RouteData)
+		if err != nil {
+			return err
+		}
+		existing[key] = id
+		er.Created++
+		return nil
+	})
+}
+
+type sessionRow struct {
+	ID        int        `json:"id"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	Notes     *string    `json:"notes"`
+}
+
+func (run *importRun) importWorkoutSessions(f *zip.File, er *EntityReport) error {
+	existing, err := run.loadTimeKeys(`SELECT id, start_time FROM workout_sessions WHERE user_id = $1`, run.userID)
+	if err != nil {
+		return err
+	}
+	return eachRow(f, er, func(s sessionRow) error {
+		key := timeKey(s.StartTime, "")
+		if id, ok := existing[key]; ok {
+			run.sessions[s.ID] = parent{id: id, existed: true}
+			er.Duplicates++
+			return nil
+		}
+		id, err := run.insert(`INSERT INTO workout_sessions (user_id, start_time, end_time, notes) VALUES ($1, $2, $3, $4) RETURNING id`, run.userID, s.StartTime, s.EndTime, s.Notes)
+		if err != nil {
+			return err
+		}
+		existing[key] = id
+		run.sessions[s.ID] = parent{id: id}
+		er.Created++
+		return nil
+	})
+}
+
+type setRow struct {
+	SessionID     int       `json:"session_id"`
+	ExerciseName  string    `json:"exercise_name"`
+	SetOrder      int       `json:"set_order"`
+	WeightKG      float64   `json:"weight_kg"`
+	Reps          int       `json:"reps"`
+	RPE           *float64  `json:"rpe"`
+	PerformedAt   time.Time `json:"performed_at"`
+	Status        string    `json:"status"`
+	SetType       string    `json:"set_type"`
+	SupersetGroup *int      `json:"superset_group"`
+	RIR           *int      `json:"rir"`
+	Tempo         *string   `json:"tempo"`
+}
+
+func (run *importRun) importWorkoutSets(f *zip.File, er *EntityReport) error {
+	return eachRow(f, er, func(s setRow) error {
+		p, ok := run.sessions[s.SessionID]
+		if !ok {
+			er.Skipped++
+			return nil
+		}
+		if p.existed {
+			er.Duplicates++
+			return nil
+		}
+		exerciseID, err := run.exerciseID(s.ExerciseName)
+		if err != nil {
+			return err
+		}
+		// Archives from before set statuses and types only have completed,
+		// normal sets.
+		status := resistance.SetCompleted
+		switch s.Status {
+		case resistance.SetPlanned, resistance.SetSkipped:
+			status = s.Status
+		}
+		setType := s.SetType
+		if !resistance.ValidSetType(setType) {
+			setType = resistance.SetTypeNormal
+		}
+		if _, err := run.insert(`INSERT INTO workout_sets (session_id, exercise_id, set_order, weight_kg, reps, rpe, performed_at, status, set_type, superset_group, rir, tempo) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+			p.id, exerciseID, s.SetOrder, s.WeightKG, s.Reps, s.RPE, s.PerformedAt, status, setType, s.SupersetGroup, s.RIR, s.Tempo); err != nil {
+			return err
+		}
+		er.Created++
+		return nil
+	})
+}
+
+type routineRow struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Notes *string `json:"notes"`
+}
+
+func (run *importRun) importRoutines(f *zip.File, er *EntityReport) error {
+	existing, err := run.loadKeys(`SELECT id, name FROM routines WHERE user_id = $1`, run.userID)
+	if err != nil {
+		return err
+	}
+	return eachRow(f, er, func(rt routineRow) error {
+		if id, ok := existing[rt.Name]; ok {
+			run.routines[rt.ID] = parent{id: id, existed: true}
+			er.Duplicates++
+			return nil
+		}
+		id, err := run.insert(`INSERT INTO routines (user_id, name, notes) VALUES ($1, $2, $3) RETURNING id`, run.userID, rt.Name, rt.Notes)
+		if err != nil {
+			return err
+		}
+		existing[rt.Name] = id
+		run.routines[rt.ID] = parent{id: id}
+		er.Created++
+		return nil
+	})
+}
+
+type routineExerciseRow struct {
+	RoutineID     int      `json:"routine_id"`
+	ExerciseName  string   `json:"exercise_name"`
+	ExerciseOrder int      `json:"exercise_order"`
+	TargetSets    *int     `json:"target_sets"`
+	RepRangeMin   *int     `json:"rep_range_min"`
+	RepRangeMax   *int     `json:"rep_range_max"`
+	TargetRPE     *float64 `json:"target_rpe"`
+	RestSeconds   *int     `json:"rest_seconds"`
+	Progression   *string  `json:"progression"`
+}
+
+func (run *importRun) importRoutineExercises(f *zip.File, er *EntityReport) error {
+	return eachRow(f, er, func(re routineExerciseRow) error {
+		p, ok := run.routines[re.RoutineID]
+		if !ok {
+			er.Skipped++
+			return nil
+		}
+		if p.existed {
+			er.Duplicates++
+			return nil
+		}
+		exerciseID, err := run.exerciseID(re.ExerciseName)
+		if err != nil {
+			return err
+		}
+		if re.Progression != nil && !resistance.ValidProgression(*re.Progression) {
+			re.Progression = nil
+		}
+		if _, err := run.insert(`INSERT INTO routine_exercises (routine_id, exercise_id, exercise_order, target_sets, rep_range_min, rep_range_max, target_rpe, rest_seconds, progression) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+			p.id, exerciseID, re.ExerciseOrder, re.TargetSets, re.RepRangeMin, re.RepRangeMax, re.TargetRPE, re.RestSeconds, re.Progression); err != nil {
+			return err
+		}
+		er.Created++
+		return nil
+	})
+}
+
+type mealRow struct {
+	ID      int       `json:"id"`
+	Name    *string   `json:"name"`
+	EatenAt time.Time `json:"eaten_at"`
+}
+
+func (run *importRun) importMeals(f *zip.File, er *EntityReport) error {
+	existing, err := run.loadTimeKeys(`SELECT id, eaten_at, name FROM meals WHERE user_id = $1`, run.userID)
+	if err != nil {
+		return err
+	}
+	return eachRow(f, er, func(m mealRow) error {
+		var name string
+		if m.Name != nil {
+			name = *m.Name
+		}
+		key := timeKey(m.EatenAt, name)
+		if id, ok := existing[key]; ok {
+			run.meals[m.ID] = parent{id: id, existed: true}
+			er.Duplicates++
+			return nil
+		}
+		id, err := run.insert(`INSERT INTO meals (user_id, name, eaten_at) VALUES ($1, $2, $3) RETURNING id`, run.userID, m.Name, m.EatenAt)
+		if err != nil {
+			return err
+		}
+		existing[key] = id
+		run.meals[m.ID] = parent{id: id}
+		er.Created++
+		return nil
+	})
+}
+
+type foodEntryRow struct {
+	MealID   int     `json:"meal_id"`
+	Name     string  `json:"name"`
+	Calories int     `json:"calories"`
+	ProteinG float64 `json:"protein_g"`
+	CarbsG   float64 `json:"carbs_g"`
+	FatG     float64 `json:"fat_g"`
+	FiberG   float64 `json:"fiber_g"`
+	Quantity *string `json:"quantity"`
+}
+
+func (run *importRun) importFoodEntries(f *zip.File, er *EntityReport) error {
+	return eachRow(f, er, func(fe foodEntryRow) error {
+		p, ok := run.meals[fe.MealID]
+		if !ok {
+			er.Skipped++
+			return nil
+		}
+		if p.existed {
+			er.Duplicates++
+			return nil
+		}
+		if _, err := run.insert(`INSERT INTO food_entries (meal_id, name, calories, protein_g, carbs_g, fat_g, fiber_g, quantity) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			p.id, fe.Name, fe.Calories, fe.ProteinG, fe.CarbsG, fe.FatG, fe.FiberG, fe.Quantity); err != nil {
+			return err
+		}
+		er.Created++
+		return nil
+	})
+}
+
+type waterRow struct {
+	AmountML   int       `json:"amount_ml"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+func (run *importRun) importWaterLogs(f *zip.File, er *EntityReport) error {
+	existing, err := run.loadTimeKeys(`SELECT id, recorded_at, amount_ml FROM water_logs WHERE user_id = $1`, run.userID)
+	if err != nil {
+		return err
+	}
+	return eachRow(f, er, func(wr waterRow) error {
+		key := timeKey(wr.RecordedAt, fmt.Sprint(wr.AmountML))
+		if _, ok := existing[key]; ok {
+			er.Duplicates++
+			return nil
+		}
+		id, err := run.insert(`INSERT INTO water_logs (user_id, amount_ml, recorded_at) VALUES ($1, $2, $3) RETURNING id`, run.userID, wr.AmountML, wr.RecordedAt)
+		if err != nil {
+			return err
+		}
+		existing[key] = id
+		er.Created++
+		return nil
+	})
+}
+
+type nutritionGoalRow struct {
+	EffectiveFrom string   `json:"effective_from"`
+	Calories      *int     `json:"calories"`
+	ProteinG      *float64 `json:"protein_g"`
+	CarbsG        *float64 `json:"carbs_g"`
+	FatG          *float64 `json:"fat_g"`
+	FiberG        *float64 `json:"fiber_g"`
+	WaterML       *int     `json:"water_ml"`
+}
+
+// importNutritionGoals keeps the account's own goal wherever both start on
+// the same day.
+func (run *importRun) importNutritionGoals(f *zip.File, er *EntityReport) error {
+	query := `INSERT INTO nutrition_goals (user_id, effective_from, calories, protein_g, carbs_g, fat_g, fiber_g, water_ml) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ` +
+		run.dialect.InsertIgnore("user_id", "effective_from")
+	return eachRow(f, er, func(g nutritionGoalRow) error {
+		res, err := run.tx.ExecContext(run.ctx, query, run.userID, g.EffectiveFrom, g.Calories, g.ProteinG, g.CarbsG, g.FatG, g.FiberG, g.WaterML)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			er.Duplicates++
+			return nil
+		}
+		er.Created++
+		return nil
+	})
+}
+
+type bodyMetricRow struct {
+	RecordedAt     time.Time `json:"recorded_at"`
+	WeightKG       *float64  `json:"weight_kg"`
+	BodyFatPercent *float64  `json:"body_fat_percent"`
+}
+
+func (run *importRun) importBodyMetrics(f *zip.File, er *EntityReport) error {
+	existing, err := run.loadTimeKeys(`SELECT id, recorded_at FROM body_metrics WHERE user_id = $1`, run.userID)
+	if err != nil {
+		return err
+	}
+	return eachRow(f, er, func(b bodyMetricRow) error {
+		key := timeKey(b.RecordedAt, "")
+		if _, ok := existing[key]; ok {
+			er.Duplicates++
+			return nil
+		}
+		id, err := run.insert(`INSERT INTO body_metrics (user_id, recorded_at, weight_kg, body_fat_percent) VALUES ($1, $2, $3, $4) RETURNING id`, run.userID, b.RecordedAt, b.WeightKG, b.BodyFatPercent)
+		if err != nil {
+			return err
+		}
+		existing[key] = id
+		er.Created++
+		return nil
+	})
+}
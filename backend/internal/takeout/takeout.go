@@ -0,0 +1,101 @@
+// Package takeout exports everything a user has stored as a ZIP archive. Each
+// entity is written as both <name>.json and <name>.csv, and manifest.json
+// describes the archive.
+package takeout
+
+import "time"
+
+const (
+	// Format identifies a takeout archive in its manifest.
+	Format = "fitness-buddy-takeout"
+	// FormatVersion is bumped when the layout of the archive changes, as
+	// opposed to the columns inside it, which follow SchemaVersion.
+	FormatVersion = 1
+
+	ManifestFile = "manifest.json"
+)
+
+type Manifest struct {
+	Format        string `json:"format"`
+	FormatVersion int    `json:"format_version"`
+
+	// SchemaVersion is the latest migration applied to the exporting
+	// database; the columns of every entity are those of that schema.
+	SchemaVersion int          `json:"schema_version"`
+	ExportedAt    time.Time    `json:"exported_at"`
+	UserID        int          `json:"user_id"`
+	Entities      []EntityInfo `json:"entities"`
+}
+
+type EntityInfo struct {
+	Name    string   `json:"name"`
+	Files   []string `json:"files"`
+	Columns []string `json:"columns"`
+	Rows    int      `json:"rows"`
+}
+
+// entity is one file pair in the archive. query selects the user's rows with
+// $1 bound to the user ID, in a stable order. Child rows carry their parent's
+// ID (session_id, meal_id, routine_id) and exercises are named as well as
+// numbered, so an archive can be read without the database it came from.
+type entity struct {
+	name  string
+	query string
+}
+
+var entities = []entity{
+	{"profile", `SELECT id, name, email, phone_number, height_cm, dob, sex, activity_level, weight_goal, units, timezone, email_verified_at, created_at, updated_at
+		FROM users WHERE id = $1`},
+	{"logins", `SELECT id, provider, subject, display, created_at
+		FROM user_identities WHERE user_id = $1 ORDER BY id`},
+	{"custom_exercises", `SELECT id, name, category, equipment, created_at
+		FROM exercises WHERE created_by = $1 ORDER BY id`},
+	{"custom_foods", `SELECT id, name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g, created_at
+		FROM food_library WHERE created_by = $1 ORDER BY id`},
+	{"workout_sessions", `SELECT id, start_time, end_time, notes, created_at
+		FROM workout_sessions WHERE user_id = $1 ORDER BY id`},
+	{"workout_sets", `SELECT ws.id, ws.session_id, ws.exercise_id, e.name AS exercise_name, ws.set_order, ws.weight_kg, ws.reps, ws.rpe, ws.performed_at, ws.status, ws.set_type, ws.superset_group, ws.rir, ws.tempo, ws.created_at
+		FROM workout_sets ws
+		JOIN workout_sessions s ON s.id = ws.session_id
+		JOIN exercises e ON e.id = ws.exercise_id
+		WHERE s.user_id = $1 ORDER BY ws.session_id, ws.id`},
+	{"personal_records", `SELECT pr.id, pr.set_id, ws.exercise_id, e.name AS exercise_name, pr.kind, pr.reps, pr.value_kg, pr.previous_value_kg, pr.achieved_at, pr.created_at
+		FROM personal_records pr
+		JOIN workout_sets ws ON ws.id = pr.set_id
+		JOIN workout_sessions s ON s.id = ws.session_id
+		JOIN exercises e ON e.id = ws.exercise_id
+		WHERE s.user_id = $1 ORDER BY pr.id`},
+	{"routines", `SELECT id, name, notes, created_at
+		FROM routines WHERE user_id = $1 ORDER BY id`},
+	{"routine_exercises", `SELECT re.id, re.routine_id, re.exercise_id, e.name AS exercise_name, re.exercise_order, re.target_sets, re.rep_range_min, re.rep_range_max, re.target_rpe, re.rest_seconds, re.progression, re.created_at
+		FROM routine_exercises re
+		JOIN routines rt ON rt.id = re.routine_id
+		JOIN exercises e ON e.id = re.exercise_id
+		WHERE rt.user_id = $1 ORDER BY re.routine_id, re.id`},
+	{"shoes", `SELECT id, brand, model, is_active, created_at
+		FROM shoes WHERE user_id = $1 ORDER BY id`},
+	{"runs", `SELECT id, start_time, duration_seconds, distance_meters, elevation_gain_meters, avg_heart_rate, cadence, relative_effort, steps, shoe_id, run_type, notes, external_id, route_data, created_at
+		FROM runs WHERE user_id = $1 ORDER BY id`},
+	{"meals", `SELECT id, name, eaten_at, created_at
+		FROM meals WHERE user_id = $1 ORDER BY id`},
+	{"food_entries", `SELECT fe.id, fe.meal_id, fe.name, fe.calories, fe.protein_g, fe.carbs_g, fe.fat_g, fe.fiber_g, fe.quantity, fe.created_at
+		FROM food_entries fe
+		JOIN meals m ON m.id = fe.meal_id
+		WHERE m.user_id = $1 ORDER BY fe.meal_id, fe.id`},
+	{"water_logs", `SELECT id, amount_ml, recorded_at
+		FROM water_logs WHERE user_id = $1 ORDER BY id`},
+	{"nutrition_goals", `SELECT id, effective_from, calories, protein_g, carbs_g, fat_g, fiber_g, water_ml, created_at
+		FROM nutrition_goals WHERE user_id = $1 ORDER BY effective_from`},
+	{"body_metrics", `SELECT id, recorded_at, weight_kg, body_fat_percent, created_at
+		FROM body_metrics WHERE user_id = $1 ORDER BY id`},
+	{"comments", `SELECT cm.id, cm.user_id, u.name AS author_name, cm.session_id, cm.run_id, cm.meal_id, cm.body, cm.created_at
+		FROM comments cm
+		JOIN users u ON u.id = cm.user_id
+		WHERE cm.user_id = $1
+			OR cm.session_id IN (SELECT id FROM workout_sessions WHERE user_id = $1)
+			OR cm.run_id IN (SELECT id FROM runs WHERE user_id = $1)
+			OR cm.meal_id IN (SELECT id FROM meals WHERE user_id = $1)
+		ORDER BY cm.id`},
+	{"coach_grants", `SELECT id, user_id AS athlete_id, coach_id, scopes, created_at, accepted_at, revoked_at
+		FROM coach_grants WHERE (user_id = $1 OR coach_id = $1) AND accepted_at IS NOT NULL ORDER BY id`},
+}
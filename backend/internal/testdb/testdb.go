@@ -0,0 +1,86 @@
+// Package testdb gives tests a freshly migrated database: SQLite in a
+// temporary file, or Postgres when TEST_POSTGRES_DSN points at a server.
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fitness-buddy/internal/database"
+	"fitness-buddy/internal/migrate"
+	"fitness-buddy/migrations"
+)
+
+// SQLite returns a migrated database in a file under t.TempDir, closed when
+// the test ends.
+func SQLite(t testing.TB) *database.DB {
+	t.Helper()
+	db := EmptySQLite(t)
+	up(t, db)
+	return db
+}
+
+// EmptySQLite is SQLite without the migrations applied.
+func EmptySQLite(t testing.TB) *database.DB {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// Postgres returns a migrated database in a schema of its own on the server
+// at TEST_POSTGRES_DSN, dropped when the test ends. The test is skipped when
+// the variable is unset.
+func Postgres(t testing.TB) *database.DB {
+	t.Helper()
+	db := EmptyPostgres(t)
+	up(t, db)
+	return db
+}
+
+// EmptyPostgres is Postgres without the migrations applied.
+func EmptyPostgres(t testing.TB) *database.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, err := sql.Open(database.DriverPostgres, dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	db, err := database.New(dsn + sep + "search_path=" + schema)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func up(t testing.TB, db *database.DB) {
+	t.Helper()
+	if _, err := migrate.New(db, migrations.FS).Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+}
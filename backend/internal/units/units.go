@@ -0,0 +1,195 @@
+// Package units converts measurements between the metric units everything is
+// stored in and the imperial units some users prefer.
+//
+// Conversion works on JSON field names. Metric fields carry their unit as a
+// suffix (weight_kg, height_cm, distance_meters); Render renames them to the
+// imperial equivalent (weight_lb, height_inches, distance_miles) and converts
+// the value, and Normalize does the reverse for request bodies. Handlers and
+// repositories never see anything but metric.
+//
+// Both directions round, and each rule's imperial precision is finer than
+// its metric one. A value a client was given and sends back, such as 100 kg
+// rendered as 220.46 lb, therefore normalizes to what it was rendered from
+// (to the metric precision) instead of drifting to 99.9998 kg.
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type System string
+
+const (
+	Metric   System = "metric"
+	Imperial System = "imperial"
+)
+
+func Parse(s string) (System, error) {
+	switch System(strings.ToLower(strings.TrimSpace(s))) {
+	case Metric:
+		return Metric, nil
+	case Imperial:
+		return Imperial, nil
+	}
+	return "", fmt.Errorf("invalid units %q, expected metric or imperial", s)
+}
+
+const (
+	lbPerKg     = 2.20462262185
+	inchesPerCm = 1 / 2.54
+	feetPerM    = 1 / 0.3048
+	milesPerM   = 1 / 1609.344
+)
+
+func KgToLb(kg float64) float64        { return kg * lbPerKg }
+func LbToKg(lb float64) float64        { return lb / lbPerKg }
+func CmToInches(cm float64) float64    { return cm * inchesPerCm }
+func InchesToCm(in float64) float64    { return in / inchesPerCm }
+func MetersToFeet(m float64) float64   { return m * feetPerM }
+func FeetToMeters(ft float64) float64  { return ft / feetPerM }
+func MetersToMiles(m float64) float64  { return m * milesPerM }
+func MilesToMeters(mi float64) float64 { return mi / milesPerM }
+
+// rule maps a metric field to its imperial counterpart. A metric name that
+// starts with "_" is a suffix and matches any field ending in it.
+type rule struct {
+	metric, imperial string
+	toImperial       func(float64) float64
+	toMetric         func(float64) float64
+	// Decimals values are rounded to in each system. One step of the
+	// imperial precision must be smaller than one of the metric, so that
+	// rendering a metric value and normalizing it again lands back on it.
+	imperialDecimals, metricDecimals int
+}
+
+// rules are tried in order, so exact names go before the suffixes they would
+// also match.
+var rules = []rule{
+	{"elevation_gain_meters", "elevation_gain_feet", MetersToFeet, FeetToMeters, 0, 0},
+	// Analytics distances are in meters without saying so.
+	{"run_distance", "run_distance_miles", MetersToMiles, MilesToMeters, 4, 0},
+	{"total_distance", "total_distance_miles", MetersToMiles, MilesToMeters, 4, 0},
+	{"_meters", "_miles", MetersToMiles, MilesToMeters, 4, 0},
+	{"_kg", "_lb", KgToLb, LbToKg, 2, 2},
+	{"_cm", "_inches", CmToInches, InchesToCm, 2, 1},
+}
+
+// match returns the renamed key for key, the conversion to apply, going from
+// metric to imperial or back, and the decimals to round the result to.
+func match(key string, toImperial bool) (string, func(float64) float64, int, bool) {
+	for _, r := range rules {
+		from, to, convert, decimals := r.metric, r.imperial, r.toImperial, r.imperialDecimals
+		if !toImperial {
+			from, to, convert, decimals = r.imperial, r.metric, r.toMetric, r.metricDecimals
+		}
+		if strings.HasPrefix(from, "_") {
+			if stem, ok := strings.CutSuffix(key, from); ok && stem != "" {
+				return stem + to, convert, decimals, true
+			}
+		} else if key == from {
+			return to, convert, decimals, true
+		}
+	}
+	return "", nil, 0, false
+}
+
+// Render rewrites a JSON document of metric values into sys. Documents that
+// are already in sys come back unchanged.
+func Render(doc []byte, sys System) ([]byte, error) {
+	if sys != Imperial {
+		return doc, nil
+	}
+	return rewrite(doc, true)
+}
+
+// Normalize rewrites any imperial fields in a JSON request body to metric.
+// Metric fields pass through, so clients can send either.
+func Normalize(doc []byte) ([]byte, error) {
+	return rewrite(doc, false)
+}
+
+func rewrite(doc []byte, toImperial bool) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	v, changed, err := walk(v, toImperial)
+	if err != nil || !changed {
+		return doc, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func walk(v any, toImperial bool) (any, bool, error) {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		renamed := map[string]any{}
+		for key, val := range v {
+			if newKey, convert, decimals, ok := match(key, toImperial); ok {
+				converted, ok, err := convertValue(val, convert, decimals)
+				if err != nil {
+					return nil, false, fmt.Errorf("%s: %w", key, err)
+				}
+				if ok {
+					delete(v, key)
+					renamed[newKey] = converted
+					continue
+				}
+			}
+			val, c, err := walk(val, toImperial)
+			if err != nil {
+				return nil, false, err
+			}
+			v[key] = val
+			changed = changed || c
+		}
+		for key, val := range renamed {
+			v[key] = val
+			changed = true
+		}
+	case []any:
+		for i, val := range v {
+			val, c, err := walk(val, toImperial)
+			if err != nil {
+				return nil, false, err
+			}
+			v[i] = val
+			changed = changed || c
+		}
+	}
+	return v, changed, nil
+}
+
+// convertValue converts a number and rounds it to decimals, and passes null
+// through so the field is still renamed. Anything else means the name matched
+// by accident and the field is left alone.
+func convertValue(v any, convert func(float64) float64, decimals int) (any, bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, true, nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, false, err
+		}
+		p := math.Pow(10, float64(decimals))
+		f = math.Round(convert(f)*p) / p
+		return json.Number(strconv.FormatFloat(f, 'f', -1, 64)), true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,161 @@
+package units
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, doc []byte) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(doc, &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", doc, err)
+	}
+	return v
+}
+
+func TestKeyRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		metric   string
+		imperial string
+	}{
+		{"kg to lb", `{"weight_kg": 100}`, `{"weight_lb": 220.46}`},
+		{"any _kg", `{"value_kg": 2.5}`, `{"value_lb": 5.51}`},
+		{"cm to inches", `{"height_cm": 180}`, `{"height_inches": 70.87}`},
+		{"meters to miles", `{"distance_meters": 5000}`, `{"distance_miles": 3.1069}`},
+		{"elevation to feet", `{"elevation_gain_meters": 100}`, `{"elevation_gain_feet": 328}`},
+		{"analytics run distance", `{"run_distance": 10000}`, `{"run_distance_miles": 6.2137}`},
+		{"analytics total distance", `{"total_distance": 1609.344}`, `{"total_distance_miles": 1}`},
+		{"null is renamed", `{"weight_kg": null}`, `{"weight_lb": null}`},
+		{"strings are left alone", `{"weight_kg": "heavy"}`, `{"weight_kg": "heavy"}`},
+		{"bare suffix is left alone", `{"_kg": 1}`, `{"_kg": 1}`},
+		{"other fields pass through", `{"reps": 5, "name": "Squat"}`, `{"reps": 5, "name": "Squat"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render([]byte(tt.metric), Imperial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decode(t, got), decode(t, []byte(tt.imperial))) {
+				t.Errorf("Render(%s) = %s, want %s", tt.metric, got, tt.imperial)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"lb to kg", `{"weight_lb": 225}`, `{"weight_kg": 102.06}`},
+		{"inches to cm", `{"height_inches": 70}`, `{"height_cm": 177.8}`},
+		{"miles to meters", `{"distance_miles": 3.1}`, `{"distance_meters": 4989}`},
+		{"feet to meters", `{"elevation_gain_feet": 330}`, `{"elevation_gain_meters": 101}`},
+		{"metric passes through", `{"weight_kg": 100.123, "reps": 5}`, `{"weight_kg": 100.123, "reps": 5}`},
+		{"mixed", `{"weight_lb": 100, "height_cm": 180}`, `{"weight_kg": 45.36, "height_cm": 180}`},
+		{"nested", `{"set": {"weight_lb": 135}, "sets": [{"weight_lb": 45}, {"reps": 5}]}`, `{"set": {"weight_kg": 61.23}, "sets": [{"weight_kg": 20.41}, {"reps": 5}]}`},
+		{"null", `{"weight_lb": null}`, `{"weight_kg": null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decode(t, got), decode(t, []byte(tt.want))) {
+				t.Errorf("Normalize(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Normalize([]byte(`{"weight_lb": `)); err == nil {
+		t.Error("invalid JSON normalized without an error")
+	}
+}
+
+func TestRenderNested(t *testing.T) {
+	in := `{"session": {"sets": [{"weight_kg": 100, "reps": 5}, {"weight_kg": 60}]}, "runs": [{"distance_meters": 1609.344, "shoe": {"total_distance_meters": 0}}], "values": [1, "kg", null]}`
+	want := `{"session": {"sets": [{"weight_lb": 220.46, "reps": 5}, {"weight_lb": 132.28}]}, "runs": [{"distance_miles": 1, "shoe": {"total_distance_miles": 0}}], "values": [1, "kg", null]}`
+	got, err := Render([]byte(in), Imperial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decode(t, got), decode(t, []byte(want))) {
+		t.Errorf("Render = %s, want %s", got, want)
+	}
+
+	top := `[{"weight_kg": 1}, [{"height_cm": 2.54}]]`
+	got, err = Render([]byte(top), Imperial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decode(t, got), decode(t, []byte(`[{"weight_lb": 2.2}, [{"height_inches": 1}]]`))) {
+		t.Errorf("Render(%s) = %s", top, got)
+	}
+}
+
+func TestRenderMetricIsUnchanged(t *testing.T) {
+	doc := []byte(`{"weight_kg": 100.123456}`)
+	got, err := Render(doc, Metric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(doc) {
+		t.Errorf("Render in metric = %s, want the document as is", got)
+	}
+}
+
+// TestRoundTrip renders metric values and normalizes them again, as when a
+// client edits a form it was given and saves it unchanged. Every value at
+// the metric precision must come back exactly.
+func TestRoundTrip(t *testing.T) {
+	for _, r := range rules {
+		t.Run(r.metric, func(t *testing.T) {
+			key := r.metric
+			if strings.HasPrefix(key, "_") {
+				key = "value" + key
+			}
+			step := math.Pow(10, -float64(r.metricDecimals))
+			if got := r.toMetric(math.Pow(10, -float64(r.imperialDecimals))); got >= step {
+				t.Fatalf("imperial step is %g metric, not finer than the metric step %g", got, step)
+			}
+			for i := 0; i <= 20000; i += 7 {
+				want := float64(i) * step
+				doc, _ := json.Marshal(map[string]float64{key: want})
+				rendered, err := Render(doc, Imperial)
+				if err != nil {
+					t.Fatal(err)
+				}
+				back, err := Normalize(rendered)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got map[string]float64
+				if err := json.Unmarshal(back, &got); err != nil {
+					t.Fatal(err)
+				}
+				if math.Abs(got[key]-want) > step/1e6 {
+					t.Fatalf("%g went to %s and back to %g", want, rendered, got[key])
+				}
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]System{"metric": Metric, " Imperial ": Imperial, "IMPERIAL": Imperial} {
+		if got, err := Parse(in); err != nil || got != want {
+			t.Errorf("Parse(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := Parse("furlongs"); err == nil {
+		t.Error("Parse accepted an unknown system")
+	}
+}
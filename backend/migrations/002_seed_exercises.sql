@@ -1,51 +1,51 @@
 -- Push
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Barbell Bench Press', 'Push', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Dumbbell Bench Press', 'Push', 'Dumbbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Incline Barbell Bench Press', 'Push', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Incline Dumbbell Bench Press', 'Push', 'Dumbbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Overhead Press', 'Push', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Dumbbell Shoulder Press', 'Push', 'Dumbbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Lateral Raises', 'Push', 'Dumbbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Cable Lateral Raises', 'Push', 'Cable');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Tricep Pushdowns', 'Push', 'Cable');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Overhead Tricep Extension', 'Push', 'Cable');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Skullcrushers', 'Push', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Dips', 'Push', 'Bodyweight');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Machine Chest Fly', 'Push', 'Machine');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Cable Fly', 'Push', 'Cable');
+INSERT INTO exercises (name, category, equipment) VALUES ('Barbell Bench Press', 'Push', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Dumbbell Bench Press', 'Push', 'Dumbbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Incline Barbell Bench Press', 'Push', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Incline Dumbbell Bench Press', 'Push', 'Dumbbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Overhead Press', 'Push', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Dumbbell Shoulder Press', 'Push', 'Dumbbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Lateral Raises', 'Push', 'Dumbbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Cable Lateral Raises', 'Push', 'Cable') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Tricep Pushdowns', 'Push', 'Cable') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Overhead Tricep Extension', 'Push', 'Cable') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Skullcrushers', 'Push', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Dips', 'Push', 'Bodyweight') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Machine Chest Fly', 'Push', 'Machine') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Cable Fly', 'Push', 'Cable') ON CONFLICT DO NOTHING;
 
 -- Pull
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Deadlift', 'Pull', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Pull Ups', 'Pull', 'Bodyweight');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Chin Ups', 'Pull', 'Bodyweight');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Lat Pulldown', 'Pull', 'Machine');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Barbell Row', 'Pull', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Dumbbell Row', 'Pull', 'Dumbbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Seated Cable Row', 'Pull', 'Cable');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Face Pulls', 'Pull', 'Cable');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Barbell Curl', 'Pull', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Dumbbell Curl', 'Pull', 'Dumbbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Hammer Curl', 'Pull', 'Dumbbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Cable Bicep Curl', 'Pull', 'Cable');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Shrugs', 'Pull', 'Barbell');
+INSERT INTO exercises (name, category, equipment) VALUES ('Deadlift', 'Pull', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Pull Ups', 'Pull', 'Bodyweight') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Chin Ups', 'Pull', 'Bodyweight') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Lat Pulldown', 'Pull', 'Machine') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Barbell Row', 'Pull', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Dumbbell Row', 'Pull', 'Dumbbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Seated Cable Row', 'Pull', 'Cable') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Face Pulls', 'Pull', 'Cable') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Barbell Curl', 'Pull', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Dumbbell Curl', 'Pull', 'Dumbbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Hammer Curl', 'Pull', 'Dumbbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Cable Bicep Curl', 'Pull', 'Cable') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Shrugs', 'Pull', 'Barbell') ON CONFLICT DO NOTHING;
 
 -- Legs
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Barbell Squat', 'Legs', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Front Squat', 'Legs', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Leg Press', 'Legs', 'Machine');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Romanian Deadlift', 'Legs', 'Barbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Bulgarian Split Squat', 'Legs', 'Dumbbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Walking Lunges', 'Legs', 'Dumbbell');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Leg Extensions', 'Legs', 'Machine');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Hamstring Curls', 'Legs', 'Machine');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Standing Calf Raises', 'Legs', 'Machine');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Seated Calf Raises', 'Legs', 'Machine');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Goblet Squat', 'Legs', 'Dumbbell');
+INSERT INTO exercises (name, category, equipment) VALUES ('Barbell Squat', 'Legs', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Front Squat', 'Legs', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Leg Press', 'Legs', 'Machine') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Romanian Deadlift', 'Legs', 'Barbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Bulgarian Split Squat', 'Legs', 'Dumbbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Walking Lunges', 'Legs', 'Dumbbell') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Leg Extensions', 'Legs', 'Machine') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Hamstring Curls', 'Legs', 'Machine') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Standing Calf Raises', 'Legs', 'Machine') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Seated Calf Raises', 'Legs', 'Machine') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Goblet Squat', 'Legs', 'Dumbbell') ON CONFLICT DO NOTHING;
 
 -- Abs
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Hanging Leg Raises', 'Core', 'Bodyweight');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Cable Crunches', 'Core', 'Cable');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Plank', 'Core', 'Bodyweight');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Ab Wheel Rollout', 'Core', 'Bodyweight');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Russian Twists', 'Core', 'Bodyweight');
-INSERT OR IGNORE INTO exercises (name, category, equipment) VALUES ('Woodchoppers', 'Core', 'Cable');
+INSERT INTO exercises (name, category, equipment) VALUES ('Hanging Leg Raises', 'Core', 'Bodyweight') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Cable Crunches', 'Core', 'Cable') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Plank', 'Core', 'Bodyweight') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Ab Wheel Rollout', 'Core', 'Bodyweight') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Russian Twists', 'Core', 'Bodyweight') ON CONFLICT DO NOTHING;
+INSERT INTO exercises (name, category, equipment) VALUES ('Woodchoppers', 'Core', 'Cable') ON CONFLICT DO NOTHING;
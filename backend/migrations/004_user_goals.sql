@@ -1,2 +1,3 @@
-ALTER TABLE users ADD COLUMN activity_level TEXT DEFAULT 'Sedentary';
-ALTER TABLE users ADD COLUMN weight_goal TEXT DEFAULT 'Maintain';
+-- activity_level and weight_goal are part of 001_initial_schema.sql.
+-- This version is kept so the numbering stays contiguous.
+SELECT 1;
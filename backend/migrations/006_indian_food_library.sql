@@ -1,5 +1,5 @@
 -- Indian Food Library Seed
-INSERT OR IGNORE INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
+INSERT INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
 ('Paneer (Cottage Cheese)', 265, 18.3, 1.2, 20.8),
 ('Chapati (Whole Wheat)', 264, 9.4, 53.0, 3.2),
 ('Basmati Rice (Cooked)', 121, 3.5, 25.2, 0.4),
@@ -21,4 +21,4 @@ INSERT OR IGNORE INTO food_library (name, calories_per_100g, protein_per_100g, c
 ('Masala Omelette', 175, 11.5, 2.5, 13.0),
 ('Tofu (Soy Paneer)', 76, 8.1, 1.9, 4.8),
 ('Almonds (Badam)', 579, 21.2, 21.7, 49.9),
-('Peanuts (Moongfali)', 567, 25.8, 16.1, 49.2);
+('Peanuts (Moongfali)', 567, 25.8, 16.1, 49.2) ON CONFLICT DO NOTHING;
@@ -1,5 +1,5 @@
 -- Fruits Expansion (per 100g)
-INSERT OR IGNORE INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
+INSERT INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
 ('Mango (Alphonso)', 60, 0.8, 15.0, 0.4),
 ('Apple (with skin)', 52, 0.3, 13.8, 0.2),
 ('Guava (Amrood)', 68, 2.6, 14.3, 1.0),
@@ -12,26 +12,26 @@ INSERT OR IGNORE INTO food_library (name, calories_per_100g, protein_per_100g, c
 ('Strawberries', 32, 0.7, 7.7, 0.3),
 ('Kiwi', 61, 1.1, 14.7, 0.5),
 ('Pineapple (Ananas)', 50, 0.5, 13.1, 0.1),
-('Dates (Khajur)', 282, 2.5, 75.0, 0.4);
+('Dates (Khajur)', 282, 2.5, 75.0, 0.4) ON CONFLICT DO NOTHING;
 
 -- Millets & Ancient Grains (Dry - per 100g)
-INSERT OR IGNORE INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
+INSERT INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
 ('Ragi (Finger Millet)', 328, 7.3, 72.0, 1.3),
 ('Bajra (Pearl Millet)', 361, 11.6, 67.5, 4.8),
 ('Jowar (Sorghum)', 349, 10.4, 72.6, 3.3),
 ('Quinoa (Dry)', 368, 14.1, 64.2, 6.1),
 ('Brown Rice (Dry)', 362, 7.5, 76.2, 2.7),
-('Sweet Potato (Shakarkandi)', 86, 1.6, 20.1, 0.1);
+('Sweet Potato (Shakarkandi)', 86, 1.6, 20.1, 0.1) ON CONFLICT DO NOTHING;
 
 -- Healthy Fats & Oils (per 100g/100ml)
-INSERT OR IGNORE INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
+INSERT INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
 ('Ghee (Cow Ghee)', 883, 0, 0, 99.8),
 ('Olive Oil (Extra Virgin)', 884, 0, 0, 100),
 ('Coconut Oil (Cold Pressed)', 862, 0, 0, 100),
-('Peanut Oil', 884, 0, 0, 100);
+('Peanut Oil', 884, 0, 0, 100) ON CONFLICT DO NOTHING;
 
 -- Super Veggies (Raw - per 100g)
-INSERT OR IGNORE INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
+INSERT INTO food_library (name, calories_per_100g, protein_per_100g, carbs_per_100g, fat_per_100g) VALUES 
 ('Spinach (Palak)', 23, 2.9, 3.6, 0.4),
 ('Broccoli', 34, 2.8, 6.6, 0.4),
 ('Cauliflower (Gobi)', 25, 1.9, 5.0, 0.3),
@@ -39,4 +39,4 @@ INSERT OR IGNORE INTO food_library (name, calories_per_100g, protein_per_100g, c
 ('Mushrooms (Button)', 22, 3.1, 3.3, 0.3),
 ('Cucumber (Kheera)', 15, 0.7, 3.6, 0.1),
 ('Carrot (Gajar)', 41, 0.9, 9.6, 0.2),
-('Beetroot (Chukandar)', 43, 1.6, 9.6, 0.2);
+('Beetroot (Chukandar)', 43, 1.6, 9.6, 0.2) ON CONFLICT DO NOTHING;
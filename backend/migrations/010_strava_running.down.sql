@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS shoes;
+ALTER TABLE runs DROP COLUMN shoe_id;
+ALTER TABLE runs DROP COLUMN relative_effort;
+ALTER TABLE runs DROP COLUMN cadence;
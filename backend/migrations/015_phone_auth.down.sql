@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_firebase_uid;
+DROP INDEX IF EXISTS idx_users_phone_number;
+ALTER TABLE users DROP COLUMN firebase_uid;
+ALTER TABLE users DROP COLUMN phone_number;
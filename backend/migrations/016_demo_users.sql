@@ -0,0 +1,2 @@
+-- Throwaway accounts handed out when DEMO_MODE is enabled
+ALTER TABLE users ADD COLUMN is_demo BOOLEAN NOT NULL DEFAULT FALSE;
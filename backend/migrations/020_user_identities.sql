@@ -0,0 +1,26 @@
+-- Every way of signing in, linked to one user. provider is google, phone or
+-- email; subject is the Google account ID, the Firebase UID or the lower-cased
+-- address respectively. display is what to show the user (email, phone).
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    display TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+
+INSERT INTO user_identities (user_id, provider, subject, display)
+SELECT id, 'google', google_id, email FROM users WHERE google_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_identities (user_id, provider, subject, display)
+SELECT id, 'phone', firebase_uid, phone_number FROM users WHERE firebase_uid IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_identities (user_id, provider, subject, display)
+SELECT id, 'email', lower(email), email FROM users WHERE password_hash IS NOT NULL AND email IS NOT NULL
+ON CONFLICT DO NOTHING;
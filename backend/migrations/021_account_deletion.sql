@@ -0,0 +1,3 @@
+-- Set when the user asks for their account to be deleted; the account and
+-- everything in it is purged once this time has passed.
+ALTER TABLE users ADD COLUMN delete_after TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+ALTER TABLE food_library DROP COLUMN created_by;
+ALTER TABLE exercises DROP COLUMN created_by;
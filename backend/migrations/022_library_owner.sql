@@ -0,0 +1,4 @@
+-- Who added a custom exercise or food, so it can be included in their data
+-- export. NULL for the seeded library and for entries added before this.
+ALTER TABLE exercises ADD COLUMN created_by INTEGER;
+ALTER TABLE food_library ADD COLUMN created_by INTEGER;
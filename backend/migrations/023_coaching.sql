@@ -0,0 +1,36 @@
+-- An athlete (user_id) lets a coach read parts of their data. The grant
+-- starts as an invite that anyone holding the token can accept once; only a
+-- hash of the token is kept. scopes lists scope groups, space-separated.
+CREATE TABLE IF NOT EXISTS coach_grants (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    coach_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    scopes TEXT NOT NULL,
+    invite_hash TEXT NOT NULL UNIQUE,
+    invite_expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    accepted_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_coach_grants_user ON coach_grants(user_id);
+CREATE INDEX IF NOT EXISTS idx_coach_grants_coach ON coach_grants(coach_id);
+
+-- Comments on a workout, run or meal; exactly one of the targets is set.
+-- user_id is the author, who may be the owner of the target or their coach.
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id INTEGER REFERENCES workout_sessions(id) ON DELETE CASCADE,
+    run_id INTEGER REFERENCES runs(id) ON DELETE CASCADE,
+    meal_id INTEGER REFERENCES meals(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((CASE WHEN session_id IS NULL THEN 0 ELSE 1 END)
+         + (CASE WHEN run_id IS NULL THEN 0 ELSE 1 END)
+         + (CASE WHEN meal_id IS NULL THEN 0 ELSE 1 END) = 1)
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_session ON comments(session_id);
+CREATE INDEX IF NOT EXISTS idx_comments_run ON comments(run_id);
+CREATE INDEX IF NOT EXISTS idx_comments_meal ON comments(meal_id);
@@ -0,0 +1,3 @@
+-- Unit system the API renders measurements in for this user: 'metric' or
+-- 'imperial'. Storage is always metric.
+ALTER TABLE users ADD COLUMN units TEXT NOT NULL DEFAULT 'metric';
@@ -0,0 +1,3 @@
+-- IANA timezone the user's days are counted in, e.g. 'Asia/Kolkata'. Every
+-- per-day figure runs from local midnight to local midnight in this zone.
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
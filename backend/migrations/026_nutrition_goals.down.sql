@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS nutrition_goals;
+ALTER TABLE food_entries DROP COLUMN fiber_g;
@@ -0,0 +1,21 @@
+-- Fiber per food entry, so it can be tracked against a goal like the other
+-- macros.
+ALTER TABLE food_entries ADD COLUMN fiber_g REAL NOT NULL DEFAULT 0;
+
+-- Daily nutrition targets. A goal applies from effective_from (a YYYY-MM-DD
+-- day in the user's timezone) until the next one starts, so changing targets
+-- never rewrites the progress of earlier days. NULL means no target for that
+-- nutrient.
+CREATE TABLE IF NOT EXISTS nutrition_goals (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    effective_from TEXT NOT NULL,
+    calories INTEGER,
+    protein_g REAL,
+    carbs_g REAL,
+    fat_g REAL,
+    fiber_g REAL,
+    water_ml INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, effective_from)
+);
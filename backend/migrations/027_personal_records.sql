@@ -0,0 +1,15 @@
+-- Personal records: each row is a set that beat every earlier set of its
+-- exercise on one measure (kind). rep_max is tracked per rep count, with reps
+-- 0 for the other kinds. Rows are derived from workout_sets and rewritten
+-- whenever the sets they depend on change, so they go with their set.
+CREATE TABLE IF NOT EXISTS personal_records (
+    id SERIAL PRIMARY KEY,
+    set_id INTEGER NOT NULL REFERENCES workout_sets(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('max_weight', 'e1rm', 'volume', 'rep_max')),
+    reps INTEGER NOT NULL DEFAULT 0,
+    value_kg REAL NOT NULL,
+    previous_value_kg REAL,
+    achieved_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (set_id, kind, reps)
+);
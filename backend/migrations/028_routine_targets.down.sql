@@ -0,0 +1,6 @@
+ALTER TABLE workout_sets DROP COLUMN status;
+ALTER TABLE routine_exercises DROP COLUMN rest_seconds;
+ALTER TABLE routine_exercises DROP COLUMN target_rpe;
+ALTER TABLE routine_exercises DROP COLUMN rep_range_max;
+ALTER TABLE routine_exercises DROP COLUMN rep_range_min;
+ALTER TABLE routine_exercises DROP COLUMN target_sets;
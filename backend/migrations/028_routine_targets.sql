@@ -0,0 +1,12 @@
+-- Targets for each exercise in a routine. All optional: a routine can still
+-- be just an ordered list of exercises.
+ALTER TABLE routine_exercises ADD COLUMN target_sets INTEGER;
+ALTER TABLE routine_exercises ADD COLUMN rep_range_min INTEGER;
+ALTER TABLE routine_exercises ADD COLUMN rep_range_max INTEGER;
+ALTER TABLE routine_exercises ADD COLUMN target_rpe REAL;
+ALTER TABLE routine_exercises ADD COLUMN rest_seconds INTEGER;
+
+-- Whether a set was done: 'planned' when a session started from a routine
+-- lays it out, 'completed' once it is performed, 'skipped' if the session was
+-- finished without it. Sets logged directly are completed.
+ALTER TABLE workout_sets ADD COLUMN status TEXT NOT NULL DEFAULT 'completed';
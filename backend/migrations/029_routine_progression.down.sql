@@ -0,0 +1 @@
+ALTER TABLE routine_exercises DROP COLUMN progression;
@@ -0,0 +1,3 @@
+-- How recommendations for a routine exercise progress: 'double_progression',
+-- 'rpe' or 'fixed_increment'. NULL picks one from the targets and history.
+ALTER TABLE routine_exercises ADD COLUMN progression TEXT;
@@ -0,0 +1,4 @@
+ALTER TABLE workout_sets DROP COLUMN tempo;
+ALTER TABLE workout_sets DROP COLUMN rir;
+ALTER TABLE workout_sets DROP COLUMN superset_group;
+ALTER TABLE workout_sets DROP COLUMN set_type;
@@ -0,0 +1,12 @@
+-- What kind of set this was: 'normal', 'warmup', 'drop', 'failure' or
+-- 'amrap'. Warm-ups are logged but left out of volume and records.
+ALTER TABLE workout_sets ADD COLUMN set_type TEXT NOT NULL DEFAULT 'normal';
+
+-- Sets in the same session with the same group number were done back to
+-- back, as a superset or circuit.
+ALTER TABLE workout_sets ADD COLUMN superset_group INTEGER;
+
+-- Reps in reserve, as an alternative to RPE, and tempo written as four
+-- digits or X for eccentric, pause, concentric and pause, e.g. '31X0'.
+ALTER TABLE workout_sets ADD COLUMN rir INTEGER;
+ALTER TABLE workout_sets ADD COLUMN tempo TEXT;
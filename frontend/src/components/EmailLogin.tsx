@@ -0,0 +1,144 @@
+import { useState, useEffect } from 'react';
+import { ArrowRight, Loader2, Mail } from 'lucide-react';
+
+type Mode = 'login' | 'register' | 'forgot' | 'reset' | 'verifying' | 'sent';
+
+// Auth endpoints are called with plain fetch rather than the API fetcher: a
+// 401 here means a wrong password, not an expired session to refresh.
+async function postAuth(path: string, body: object): Promise<void> {
+    const response = await fetch(`/api/auth/password/${path}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body),
+        credentials: 'include'
+    });
+    if (!response.ok) {
+        const error = await response.text();
+        throw new Error(error || 'Request failed');
+    }
+}
+
+export default function EmailLogin() {
+    const params = new URLSearchParams(window.location.search);
+    const verifyToken = params.get('verify');
+    const resetToken = params.get('reset');
+
+    const [mode, setMode] = useState<Mode>(verifyToken ? 'verifying' : resetToken ? 'reset' : 'login');
+    const [name, setName] = useState('');
+    const [email, setEmail] = useState('');
+    const [password, setPassword] = useState('');
+    const [error, setError] = useState('');
+    const [loading, setLoading] = useState(false);
+
+    useEffect(() => {
+        if (!verifyToken) return;
+        postAuth('verify', { token: verifyToken })
+            .then(() => { window.location.href = '/'; })
+            .catch(err => {
+                setMode('login');
+                setError(err instanceof Error ? err.message : 'Verification failed');
+            });
+    }, [verifyToken]);
+
+    const submit = async (e: React.FormEvent) => {
+        e.preventDefault();
+        setLoading(true);
+        setError('');
+        try {
+            switch (mode) {
+                case 'login':
+                    await postAuth('login', { email, password });
+                    window.location.href = '/';
+                    break;
+                case 'register':
+                    await postAuth('register', { name, email, password });
+                    setMode('sent');
+                    break;
+                case 'forgot':
+                    await postAuth('forgot', { email });
+                    setMode('sent');
+                    break;
+                case 'reset':
+                    await postAuth('reset', { token: resetToken, password });
+                    window.location.href = '/';
+                    break;
+            }
+        } catch (err) {
+            setError(err instanceof Error ? err.message : 'Something went wrong');
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    if (mode === 'verifying') {
+        return (
+            <div className="flex flex-col items-center justify-center gap-4 py-8">
+                <Loader2 className="w-8 h-8 animate-spin text-emerald-500" />
+                <p className="text-neutral-400 text-sm">Confirming your email...</p>
+            </div>
+        );
+    }
+
+    if (mode === 'sent') {
+        return (
+            <div className="flex flex-col items-center gap-3 py-6 text-center">
+                <Mail className="w-8 h-8 text-emerald-500" />
+                <p className="text-neutral-300 text-sm">Check your inbox for a link to continue.</p>
+                <button onClick={() => setMode('login')} className="text-xs font-bold text-neutral-500 uppercase tracking-wider hover:text-white">
+                    Back to sign in
+                </button>
+            </div>
+        );
+    }
+
+    const inputClass = "w-full bg-white/5 border border-white/10 rounded-xl px-4 py-3 text-white placeholder-neutral-500 focus:outline-none focus:border-emerald-500 transition-colors";
+
+    return (
+        <form onSubmit={submit} className="space-y-3 text-left">
+            {mode === 'register' && (
+                <input className={inputClass} placeholder="Name" value={name} onChange={e => setName(e.target.value)} />
+            )}
+            {mode !== 'reset' && (
+                <input className={inputClass} type="email" placeholder="Email" autoComplete="email" required value={email} onChange={e => setEmail(e.target.value)} />
+            )}
+            {mode !== 'forgot' && (
+                <input
+                    className={inputClass}
+                    type="password"
+                    placeholder={mode === 'reset' ? 'New password' : 'Password'}
+                    autoComplete={mode === 'login' ? 'current-password' : 'new-password'}
+                    minLength={8}
+                    required
+                    value={password}
+                    onChange={e => setPassword(e.target.value)}
+                />
+            )}
+
+            {error && <p className="text-red-400 text-sm">{error}</p>}
+
+            <button
+                type="submit"
+                disabled={loading}
+                className="w-full bg-gradient-to-r from-emerald-500 to-teal-500 text-white py-4 px-8 rounded-xl font-bold text-lg hover:from-emerald-600 hover:to-teal-600 transition-all active:scale-[0.98] flex items-center justify-center gap-3 disabled:opacity-50 disabled:cursor-not-allowed"
+            >
+                {loading ? <Loader2 className="w-5 h-5 animate-spin" /> : (
+                    <>
+                        {{ login: 'Sign in', register: 'Create account', forgot: 'Send reset link', reset: 'Set password' }[mode]}
+                        <ArrowRight size={20} />
+                    </>
+                )}
+            </button>
+
+            <div className="flex justify-between text-xs font-bold text-neutral-500 uppercase tracking-wider">
+                {mode === 'login' ? (
+                    <>
+                        <button type="button" onClick={() => setMode('register')} className="hover:text-white">Create account</button>
+                        <button type="button" onClick={() => setMode('forgot')} className="hover:text-white">Forgot password?</button>
+                    </>
+                ) : (
+                    <button type="button" onClick={() => setMode('login')} className="hover:text-white">Back to sign in</button>
+                )}
+            </div>
+        </form>
+    );
+}
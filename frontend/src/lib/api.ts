@@ -1,15 +1,33 @@
 const API_URL = "/api";
 
+// Access tokens are short lived. Concurrent requests that hit a 401 share one
+// refresh, since each refresh token can only be exchanged once.
+let refreshing: Promise<boolean> | null = null;
+
+function refreshSession(): Promise<boolean> {
+  if (!refreshing) {
+    refreshing = fetch(`${API_URL}/auth/refresh`, { method: "POST" })
+      .then(res => res.ok)
+      .catch(() => false)
+      .finally(() => { refreshing = null; });
+  }
+  return refreshing;
+}
+
 export async function fetcher<T>(url: string, options?: RequestInit): Promise<T> {
   const headers: HeadersInit = {
     'Content-Type': 'application/json',
     ...(options?.headers || {}),
   };
 
-  const res = await fetch(`${API_URL}${url}`, {
+  const request = () => fetch(`${API_URL}${url}`, {
     ...options,
     headers,
   });
+  let res = await request();
+  if (res.status === 401 && await refreshSession()) {
+    res = await request();
+  }
   const text = await res.text();
 
   if (!res.ok) {
@@ -29,6 +47,7 @@ export const api = {
     get: () => fetcher<User>("/user"),
     create: (data: Partial<User>) => fetcher<User>("/user", { method: "POST", body: JSON.stringify(data) }),
     update: (data: Partial<User>) => fetcher<User>("/user", { method: "PUT", body: JSON.stringify(data) }),
+    linkGoogle: (data: { redirect?: string, merge?: boolean }) => fetcher<{ auth_url: string }>("/user/identities/google/link", { method: "POST", body: JSON.stringify(data) }),
   },
   resistance: {
     listExercises: () => fetcher<Exercise[]>("/exercises"),
@@ -1,4 +1,5 @@
 import { Dumbbell, Activity, Flame, Target } from 'lucide-react';
+import EmailLogin from '../components/EmailLogin';
 
 export default function Login() {
   const handleLogin = () => {
@@ -66,6 +67,14 @@ export default function Login() {
           <p className="text-[10px] text-neutral-600 font-bold uppercase tracking-[0.2em]">
             Secure login powered by Google
           </p>
+
+          <div className="flex items-center gap-3 text-neutral-700">
+            <div className="flex-1 h-px bg-white/10" />
+            <span className="text-[10px] font-bold uppercase tracking-widest">or use email</span>
+            <div className="flex-1 h-px bg-white/10" />
+          </div>
+
+          <EmailLogin />
         </div>
 
         {/* Goals Hint */}
@@ -89,6 +89,15 @@ export default function Profile() {
 
   const tdee = calculateTDEE();
 
+  async function handleConnectGoogle() {
+    try {
+      const { auth_url } = await api.identity.linkGoogle({ redirect: "/profile" });
+      window.location.href = auth_url;
+    } catch (e) {
+      alert("Error: Could not start Google sign-in.");
+    }
+  }
+
   async function handleSave(e: React.FormEvent) {
     e.preventDefault();
     setSaving(true);
@@ -139,12 +148,13 @@ export default function Profile() {
                 <div className="flex flex-col md:flex-row items-center gap-4">
                     <h2 className="text-5xl font-black text-white tracking-tighter italic uppercase">{user?.name || "Anonymous"}</h2>
                     {!user?.google_id ? (
-                        <a 
-                            href="/api/auth/google/login" 
+                        <button
+                            type="button"
+                            onClick={handleConnectGoogle}
                             className="bg-white text-black px-4 py-2 rounded-full text-[10px] font-black uppercase tracking-widest hover:bg-neutral-200 transition-colors flex items-center gap-2"
                         >
                             <Zap size={12} fill="currentColor" /> Connect Account
-                        </a>
+                        </button>
                     ) : (
                         <a 
                             href="/api/auth/logout"